@@ -6,18 +6,37 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers/collection"
+	"url_shortener/httpServer/handlers/debug"
 	"url_shortener/httpServer/handlers/deleteURL"
+	"url_shortener/httpServer/handlers/domain"
 	"url_shortener/httpServer/handlers/login"
+	"url_shortener/httpServer/handlers/qr"
 	"url_shortener/httpServer/handlers/redirect"
 	"url_shortener/httpServer/handlers/register"
+	"url_shortener/httpServer/handlers/url/alias"
+	"url_shortener/httpServer/handlers/url/batch"
+	"url_shortener/httpServer/handlers/url/list"
+	"url_shortener/httpServer/handlers/url/random"
 	"url_shortener/httpServer/handlers/url/save"
+	"url_shortener/httpServer/handlers/url/stats"
+	"url_shortener/httpServer/handlers/url/transfer"
+	"url_shortener/httpServer/handlers/url/update"
+	"url_shortener/internal/clicks"
 	"url_shortener/internal/config"
 	"url_shortener/internal/lib/logger/handlers/slogpretty"
 	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage/cache"
+	"url_shortener/internal/storage/memory"
 	"url_shortener/internal/storage/postgres"
+	"url_shortener/internal/storage/sqlite"
+	"url_shortener/internal/sweeper"
 )
 
 const (
@@ -26,11 +45,55 @@ const (
 	envProd  = "prod"
 )
 
+const (
+	storagePostgres = "postgres"
+	storageSQLite   = "sqlite"
+	storageMemory   = "memory"
+)
+
+// Storage is everything the handlers need from a storage backend.
+type Storage interface {
+	batch.BatchSaver
+	redirect.URLGetter
+	redirect.LinkGetter
+	redirect.ClickLimiter
+	deleteURL.URLRemover
+	deleteURL.URLRestorer
+	login.LoginHandler
+	register.RegistrationHandler
+	sweeper.Purger
+	clicks.ClickSaver
+	stats.StatsGetter
+	list.URLLister
+	update.URLUpdater
+	random.Sequencer
+	collection.Creator
+	collection.Lister
+	collection.Updater
+	collection.Remover
+	collection.StatsGetter
+	domain.Creator
+	domain.Lister
+	domain.Verifier
+	domain.Remover
+	redirect.HostSource
+	Close() error
+}
+
+// linkStore serves redirects together with the writes that must invalidate cached redirects.
+type linkStore interface {
+	redirect.URLGetter
+	deleteURL.URLRemover
+	deleteURL.URLRestorer
+	update.URLUpdater
+}
+
 func main() {
 	// TODO: init config - library - cleanenv
 	// Create dir "config" in the root with local.yaml and store parameters of config, create dir "internal" and within it dir "config" with config.go file and create structs fitting for storage of local.yaml parameters, use library cleanenv to read config and put it in the created structs, use export CONFIG_PATH=/Users/dangolutvo/Documents/GitHub/url_shortener/config/local.yaml
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	cfg := config.MustLoad()
 
 	// TODO: init logger - library - sl (import log/sl)
@@ -46,13 +109,51 @@ func main() {
 
 	// TODO: init storage - library - postgres or SQLite
 	// create dir "storage" within dir "internal" with storage.go and dir "postgres" with postgres.go
-	storage, err := postgres.NewStorage(ctx, cfg.Dsn)
+	storage, err := setupStorage(ctx, cfg)
 	if err != nil {
 		log.Error("failed to init storage", sl.Err(err))
 		os.Exit(1)
 	}
-	defer storage.DB.Close()
-	log.Info("Database initialized successfully")
+	defer storage.Close()
+	log.Info("Database initialized successfully", slog.String("storage", cfg.Storage))
+
+	// "url_shortener migrate ..." only runs migrations and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, log, storage, os.Args[2:]); err != nil {
+			log.Error("failed to migrate", sl.Err(err))
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.AutoMigrate {
+		if err := runMigrate(ctx, log, storage, []string{"up"}); err != nil {
+			log.Error("failed to migrate", sl.Err(err))
+			os.Exit(1)
+		}
+	}
+
+	go sweeper.New(log, storage, cfg.Expiration, cfg.Trash).Run(ctx)
+
+	clickRecorder := clicks.NewRecorder(log, storage, cfg.Clicks)
+	go clickRecorder.Run(ctx)
+
+	var links linkStore = storage
+	var cacheStats debug.CacheStats // nil without the cache
+	if cfg.Cache.Enabled {
+		urlCache := cache.New(storage, cfg.Cache)
+		cacheStats = urlCache
+		links = urlCache
+	}
+
+	hosts := redirect.NewHosts(log, storage, cfg.Domains)
+
+	aliases, err := setupAliases(cfg, storage)
+	if err != nil {
+		log.Error("failed to init alias generators", sl.Err(err))
+		os.Exit(1)
+	}
+
+	reserved := reservedHosts(cfg)
 
 	// TODO: init router - library - chi, chi"render" or gorilla
 	router := mux.NewRouter()
@@ -60,21 +161,65 @@ func main() {
 	// middleware that attaches uniq id to a request
 	router.Use(middleware.RequestID)
 	router.Use(middleware.LoggingMiddleware)
-	router.Handle("/{alias}", redirect.New(log, storage)).Methods(http.MethodGet)
-	router.Handle("/login", login.HandleLogin(log, storage)).Methods(http.MethodPost)
-	router.Handle("/register", register.HandleRegistration(log, storage)).Methods(http.MethodPost)
-
+	// private routes go first so that GET /url is not taken for an alias by /{alias}
 	privateRouter := router.PathPrefix("/").Subrouter()
 	privateRouter.Use(middleware.Auth)
 
-	privateRouter.Handle("/url", save.New(log, storage)).Methods(http.MethodPost)
-	privateRouter.Handle("/url/{alias}", deleteURL.New(log, storage)).Methods(http.MethodDelete)
+	privateRouter.Handle("/url", list.New(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/url", save.New(log, storage, aliases, cfg.DedupURLs)).Methods(http.MethodPost)
+	privateRouter.Handle("/url/batch", batch.New(log, storage, aliases, cfg.DedupURLs, cfg.BatchLimit)).Methods(http.MethodPost)
+	privateRouter.Handle("/url/trash", list.NewTrash(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/url/export", transfer.NewExport(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/url/import", transfer.NewImport(log, batch.NewSaver(storage, aliases), cfg.ImportLimit)).Methods(http.MethodPost)
+	privateRouter.Handle("/url/bulk", deleteURL.NewBulk(log, storage, links, cfg.BatchLimit)).Methods(http.MethodPost)
+	privateRouter.Handle("/url/{alias}", update.New(log, links)).Methods(http.MethodPatch)
+	privateRouter.Handle("/url/{alias}", deleteURL.New(log, links)).Methods(http.MethodDelete)
+	privateRouter.Handle("/url/{alias}/restore", deleteURL.NewRestore(log, links)).Methods(http.MethodPost)
+	privateRouter.Handle("/url/{alias}/stats", stats.New(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/collections", collection.NewList(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/collections", collection.NewCreate(log, storage)).Methods(http.MethodPost)
+	privateRouter.Handle("/collections/{id}", collection.NewGet(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/collections/{id}", collection.NewUpdate(log, storage)).Methods(http.MethodPatch)
+	privateRouter.Handle("/collections/{id}", collection.NewDelete(log, storage)).Methods(http.MethodDelete)
+	privateRouter.Handle("/collections/{id}/stats", collection.NewStats(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/domains", domain.NewList(log, storage)).Methods(http.MethodGet)
+	privateRouter.Handle("/domains", domain.NewCreate(log, storage, reserved)).Methods(http.MethodPost)
+	privateRouter.Handle("/domains/{host}/verify", domain.NewVerify(log, storage, net.DefaultResolver, hosts, reserved)).Methods(http.MethodPost)
+	privateRouter.Handle("/domains/{host}", domain.NewDelete(log, storage, hosts)).Methods(http.MethodDelete)
+	privateRouter.Handle("/debug/vars", debug.NewVars(cacheStats)).Methods(http.MethodGet) // redirect cache counters
+
+	comingSoon, err := redirect.NewComingSoon(cfg.Activation)
+	if err != nil {
+		log.Error("failed to load coming soon page", sl.Err(err))
+		os.Exit(1)
+	}
+	redirectHandler := redirect.New(log, links, clickRecorder, redirect.NewGate(storage, cfg.Protection), storage, comingSoon, hosts)
+	// previews go first, /{alias} would take docs+ for an alias
+	previewHandler := redirect.NewPreview(log, storage, hosts)
+	router.Handle("/{alias:[^/]+\\+}", previewHandler).Methods(http.MethodGet)
+	router.Handle("/{alias}", previewHandler).Queries("preview", "1").Methods(http.MethodGet)
+	router.Handle("/{alias}", redirectHandler).Methods(http.MethodGet)
+	router.Handle("/{alias}/qr", qr.New(log, storage, hosts, cfg.QR)).Methods(http.MethodGet)
+	router.Handle("/login", login.HandleLogin(log, storage)).Methods(http.MethodPost)
+	router.Handle("/register", register.HandleRegistration(log, storage)).Methods(http.MethodPost)
+	// after /login and /register, posting a password to a protected link must not shadow them
+	router.Handle("/{alias}", redirectHandler).Methods(http.MethodPost)
+	// subpages of links that forward their path, /{alias}/qr above is the qr code of any link
+	router.Handle("/{alias}/{path:.+}", redirectHandler).Methods(http.MethodGet, http.MethodPost)
 
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Retrieve the request ID from the context
 		reqID := middleware.GetReqID(r.Context())
 		fmt.Fprintf(w, "Hello, your request ID is: %s\n", reqID)
 	})
+
+	// aliases named like a route would never be reachable
+	routeWords, err := alias.RouteWords(router)
+	if err != nil {
+		log.Error("failed to collect route words", sl.Err(err))
+		os.Exit(1)
+	}
+	aliases.Policy.Reserve(routeWords...)
 	recoveryHandler := handlers.RecoveryHandler(
 		handlers.PrintRecoveryStack(true), // Print stack trace to logs
 		handlers.RecoveryLogger(slog.NewLogLogger(
@@ -102,6 +247,61 @@ func main() {
 	// TODO: run server
 }
 
+func setupStorage(ctx context.Context, cfg *config.Config) (Storage, error) {
+	switch cfg.Storage {
+	case storagePostgres:
+		if cfg.Dsn == "" {
+			return nil, fmt.Errorf("dsn is required for %s storage", storagePostgres)
+		}
+		return postgres.NewStorage(ctx, cfg.Dsn)
+	case storageSQLite:
+		return sqlite.NewStorage(ctx, cfg.StoragePath)
+	case storageMemory:
+		return memory.NewStorage(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q", cfg.Storage)
+	}
+}
+
+// reservedHosts are the hosts serving the default domain, registering one as a custom domain
+// would take its aliases away: the configured ones, the public host of short links and the listen host.
+func reservedHosts(cfg *config.Config) []string {
+	reserved := slices.Clone(cfg.Domains.Reserved)
+	if base, err := url.Parse(cfg.QR.BaseURL); err == nil && base.Hostname() != "" {
+		reserved = append(reserved, base.Hostname())
+	}
+	if host, _, err := net.SplitHostPort(cfg.HTTPServer.Address); err == nil && host != "" {
+		reserved = append(reserved, host)
+	}
+	return reserved
+}
+
+func setupAliases(cfg *config.Config, seq random.Sequencer) (save.AliasOptions, error) {
+	policy, err := alias.NewPolicy(cfg.AliasPolicy)
+	if err != nil {
+		return save.AliasOptions{}, err
+	}
+	crypto, err := random.NewCrypto(cfg.AliasAlphabet, cfg.AliasNoLookalikes)
+	if err != nil {
+		return save.AliasOptions{}, err
+	}
+	aliases := save.AliasOptions{
+		Policy: policy,
+		Generators: map[string]save.AliasGenerator{
+			random.GeneratorRandom:   crypto,
+			random.GeneratorSequence: random.NewSequence(seq),
+			random.GeneratorWords:    random.NewWords(),
+		},
+		Default:  cfg.AliasGenerator,
+		Length:   cfg.AliasLength,
+		Attempts: cfg.AliasAttempts,
+	}
+	if _, ok := aliases.Generators[cfg.AliasGenerator]; !ok {
+		return save.AliasOptions{}, fmt.Errorf("unknown alias generator %q", cfg.AliasGenerator)
+	}
+	return aliases, nil
+}
+
 func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 
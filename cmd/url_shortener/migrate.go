@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"url_shortener/internal/storage/migrate"
+)
+
+const migrateUsage = "usage: url_shortener migrate up | down [steps] | version"
+
+// migratable is implemented by storages that keep a versioned schema (postgres, sqlite).
+// The returned func releases what the migrator holds, the storage itself stays open.
+type migratable interface {
+	Migrator() (*migrate.Migrator, func() error, error)
+}
+
+// runMigrate handles the "migrate" subcommand, args are the arguments after it.
+func runMigrate(ctx context.Context, log *slog.Logger, storage Storage, args []string) error {
+	s, ok := storage.(migratable)
+	if !ok {
+		log.Info("storage has no schema, nothing to migrate")
+		return nil
+	}
+	migrator, closeMigrator, err := s.Migrator()
+	if err != nil {
+		return err
+	}
+	defer closeMigrator()
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations applied", slog.Int("count", applied))
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps %q, %s", args[1], migrateUsage)
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Info("migrations rolled back", slog.Int("count", rolledBack))
+	case "version":
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	log.Info("schema version", slog.Int64("version", version))
+	return nil
+}
@@ -0,0 +1,296 @@
+package collection
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator"
+	"github.com/gorilla/mux"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// Request creates a collection. On update omitted fields are left as they are.
+type Request struct {
+	Name        *string `json:"name,omitempty" validate:"omitempty,min=1,max=64"`
+	Description *string `json:"description,omitempty" validate:"omitempty,max=500"`
+}
+
+type Collection struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Links       *int64    `json:"links,omitempty"` // only in lists
+}
+
+type Response struct {
+	resp.Response
+	Collection
+}
+
+type ListResponse struct {
+	resp.Response
+	Collections []Collection `json:"collections"`
+}
+
+type Creator interface {
+	CreateCollection(c storage.Collection) (storage.Collection, error)
+}
+
+type Lister interface {
+	ListCollections(owner string) ([]storage.CollectionSummary, error)
+}
+
+type Getter interface {
+	GetCollection(id, owner string) (storage.Collection, error)
+}
+
+type Updater interface {
+	UpdateCollection(id, owner string, upd storage.CollectionUpdate) (storage.Collection, error)
+}
+
+type Remover interface {
+	DeleteCollection(id, owner string) (bool, error)
+}
+
+// NewCreate adds a collection of the caller, names are unique per user.
+func NewCreate(log *slog.Logger, creator Creator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.collection.NewCreate"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, ok := caller(w, r, log)
+		if !ok {
+			return
+		}
+		req, ok := decode(w, r, log)
+		if !ok {
+			return
+		}
+		if req.Name == nil {
+			log.Info("collection name is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("field Name is a required field"))
+			return
+		}
+
+		c := storage.Collection{Owner: owner, Name: *req.Name}
+		if req.Description != nil {
+			c.Description = *req.Description
+		}
+		c, err := creator.CreateCollection(c)
+		if errors.Is(err, storage.ErrCollectionExists) {
+			log.Info("collection already exists", slog.String("name", *req.Name))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("collection already exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to create collection", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("collection created", slog.String("id", c.ID))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{Response: resp.OK(), Collection: collectionOf(c)})
+	}
+}
+
+// NewList lists the collections of the caller by name, with the number of their links.
+func NewList(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.collection.NewList"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, ok := caller(w, r, log)
+		if !ok {
+			return
+		}
+
+		summaries, err := lister.ListCollections(owner)
+		if err != nil {
+			log.Error("failed to list collections", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		response := ListResponse{Response: resp.OK(), Collections: make([]Collection, 0, len(summaries))}
+		for _, s := range summaries {
+			c := collectionOf(s.Collection)
+			c.Links = &s.Links
+			response.Collections = append(response.Collections, c)
+		}
+		render.JSON(w, r, response)
+	}
+}
+
+// NewGet returns a collection of the caller.
+func NewGet(log *slog.Logger, getter Getter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.collection.NewGet"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, ok := caller(w, r, log)
+		if !ok {
+			return
+		}
+		id := mux.Vars(r)["id"]
+
+		c, err := getter.GetCollection(id, owner)
+		if !found(w, r, log, id, err) {
+			return
+		}
+		render.JSON(w, r, Response{Response: resp.OK(), Collection: collectionOf(c)})
+	}
+}
+
+// NewUpdate renames a collection of the caller or changes its description.
+func NewUpdate(log *slog.Logger, updater Updater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.collection.NewUpdate"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, ok := caller(w, r, log)
+		if !ok {
+			return
+		}
+		id := mux.Vars(r)["id"]
+		req, ok := decode(w, r, log)
+		if !ok {
+			return
+		}
+		if req.Name == nil && req.Description == nil {
+			log.Info("nothing to update")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("nothing to update"))
+			return
+		}
+
+		c, err := updater.UpdateCollection(id, owner, storage.CollectionUpdate{Name: req.Name, Description: req.Description})
+		if errors.Is(err, storage.ErrCollectionExists) {
+			log.Info("collection already exists", slog.String("name", *req.Name))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("collection already exists"))
+			return
+		}
+		if !found(w, r, log, id, err) {
+			return
+		}
+
+		log.Info("collection updated", slog.String("id", id))
+
+		render.JSON(w, r, Response{Response: resp.OK(), Collection: collectionOf(c)})
+	}
+}
+
+// NewDelete deletes a collection of the caller. Its links are kept, they just leave the collection.
+func NewDelete(log *slog.Logger, remover Remover) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.collection.NewDelete"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, ok := caller(w, r, log)
+		if !ok {
+			return
+		}
+		id := mux.Vars(r)["id"]
+
+		_, err := remover.DeleteCollection(id, owner)
+		if !found(w, r, log, id, err) {
+			return
+		}
+
+		log.Info("collection deleted", slog.String("id", id))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+func collectionOf(c storage.Collection) Collection {
+	return Collection{ID: c.ID, Name: c.Name, Description: c.Description, CreatedAt: c.CreatedAt}
+}
+
+// caller returns the id of the user making the request, answering 401 if there is none.
+func caller(w http.ResponseWriter, r *http.Request, log *slog.Logger) (string, bool) {
+	owner, err := handlers.GetUserIDFromContext(r.Context())
+	if err != nil {
+		log.Error("could not get user id from context, unauthorized", sl.Err(err))
+		render.Status(r, http.StatusUnauthorized)
+		render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+		return "", false
+	}
+	return owner, true
+}
+
+// decode reads and validates the request body, answering 400 if it is not a valid Request.
+func decode(w http.ResponseWriter, r *http.Request, log *slog.Logger) (Request, bool) {
+	var req Request
+	err := render.DecodeJSON(r.Body, &req)
+	if errors.Is(err, io.EOF) {
+		log.Error("request body is empty")
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("empty request"))
+		return req, false
+	}
+	if err != nil {
+		log.Error("failed to decode request body", sl.Err(err))
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.Error("failed to decode request"))
+		return req, false
+	}
+
+	log.Info("request body decoded", slog.Any("request", req))
+
+	if err := validator.New().Struct(req); err != nil {
+		validateErr := err.(validator.ValidationErrors)
+		log.Error("invalid request", sl.Err(err))
+		render.Status(r, http.StatusBadRequest)
+		render.JSON(w, r, resp.ErrorValidator(validateErr))
+		return req, false
+	}
+	return req, true
+}
+
+// found answers 404 for storage.ErrCollectionNotFound and 500 for other errors. It reports whether
+// err is nil and the handler can go on.
+func found(w http.ResponseWriter, r *http.Request, log *slog.Logger, id string, err error) bool {
+	if errors.Is(err, storage.ErrCollectionNotFound) {
+		log.Info("collection not found", slog.String("id", id))
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("collection not found"))
+		return false
+	}
+	if err != nil {
+		log.Error("failed to get collection", sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, resp.Error("internal error"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(s *memory.Storage) *mux2.Router {
+	log := slogdiscard.NewDiscardLogger()
+	router := mux2.NewRouter()
+	router.Handle("/collections", NewList(log, s)).Methods(http.MethodGet)
+	router.Handle("/collections", NewCreate(log, s)).Methods(http.MethodPost)
+	router.Handle("/collections/{id}", NewGet(log, s)).Methods(http.MethodGet)
+	router.Handle("/collections/{id}", NewUpdate(log, s)).Methods(http.MethodPatch)
+	router.Handle("/collections/{id}", NewDelete(log, s)).Methods(http.MethodDelete)
+	router.Handle("/collections/{id}/stats", NewStats(log, s)).Methods(http.MethodGet)
+	return router
+}
+
+func TestCollections(t *testing.T) {
+	s := memory.NewStorage()
+	router := newRouter(s)
+
+	serve := func(method, target, body, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", user))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/collections", `{"name": "spring", "description": "campaign"}`, "owner")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	require.NotEmpty(t, created.ID)
+	id := created.ID
+
+	rr = serve(http.MethodPost, "/collections", `{"name": "spring"}`, "owner")
+	require.Equal(t, http.StatusConflict, rr.Code)
+	rr = serve(http.MethodPost, "/collections", `{"description": "no name"}`, "owner")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	_, err := s.SaveURL(storage.Link{Alias: "a", URL: "https://example.com", Creator: "owner", CollectionID: id})
+	require.NoError(t, err)
+	require.NoError(t, s.SaveClicks(context.Background(), []storage.Click{{Alias: "a", ClickedAt: time.Now()}}))
+
+	rr = serve(http.MethodGet, "/collections", "", "owner")
+	var list ListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list.Collections, 1)
+	require.EqualValues(t, 1, *list.Collections[0].Links)
+
+	rr = serve(http.MethodGet, "/collections/"+id+"/stats?bucket=hour&from="+time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), "", "owner")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var stats StatsResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &stats))
+	require.EqualValues(t, 1, stats.Links)
+	require.EqualValues(t, 1, stats.TotalClicks)
+	require.Equal(t, "a", stats.TopLinks[0].Value)
+
+	// collections of other users are not found
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/collections/"+id, "", "someone else").Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/collections/"+id+"/stats", "", "someone else").Code)
+
+	rr = serve(http.MethodPatch, "/collections/"+id, `{"name": "summer"}`, "owner")
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Contains(t, rr.Body.String(), `"name":"summer"`)
+
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete, "/collections/"+id, "", "owner").Code)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodGet, "/collections/"+id, "", "owner").Code)
+	link, err := s.GetLink("a")
+	require.NoError(t, err)
+	require.Empty(t, link.CollectionID)
+}
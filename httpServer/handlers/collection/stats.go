@@ -0,0 +1,92 @@
+package collection
+
+import (
+	"context"
+	"github.com/go-chi/render"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers/url/stats"
+	"url_shortener/internal/clicks"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+const topLinks = 10
+
+type StatsResponse struct {
+	resp.Response
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	From        time.Time             `json:"from"`
+	To          time.Time             `json:"to"`
+	Bucket      string                `json:"bucket"`
+	Links       int64                 `json:"links"`
+	TotalClicks int64                 `json:"total_clicks"`
+	TopLinks    []storage.ValueCount  `json:"top_links"`
+	Series      []storage.ClickBucket `json:"series"`
+}
+
+type StatsGetter interface {
+	Getter
+	CollectionStats(ctx context.Context, id string, from, to time.Time, top int) (storage.CollectionStats, error)
+}
+
+// NewStats serves click statistics summed over the links of a collection of the caller, with the
+// query parameters of the link stats.
+func NewStats(log *slog.Logger, statsGetter StatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.collection.NewStats"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, ok := caller(w, r, log)
+		if !ok {
+			return
+		}
+		id := mux.Vars(r)["id"]
+
+		from, to, bucket, err := stats.ParseRange(r, time.Now())
+		if err != nil {
+			log.Info("invalid stats range", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		c, err := statsGetter.GetCollection(id, owner)
+		if !found(w, r, log, id, err) {
+			return
+		}
+
+		collectionStats, err := statsGetter.CollectionStats(r.Context(), c.ID, from, to, topLinks)
+		if err != nil {
+			log.Error("failed to get collection stats", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		topLinks := collectionStats.TopLinks
+		if topLinks == nil {
+			topLinks = []storage.ValueCount{}
+		}
+		render.JSON(w, r, StatsResponse{
+			Response:    resp.OK(),
+			ID:          c.ID,
+			Name:        c.Name,
+			From:        from,
+			To:          to,
+			Bucket:      bucket,
+			Links:       collectionStats.Links,
+			TotalClicks: collectionStats.Total,
+			TopLinks:    topLinks,
+			Series:      clicks.Series(collectionStats.Hourly, from, to, bucket),
+		})
+	}
+}
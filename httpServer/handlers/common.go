@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
+	"url_shortener/internal/storage"
 )
 
 func GetUserIDFromContext(ctx context.Context) (string, error) {
@@ -12,3 +16,29 @@ func GetUserIDFromContext(ctx context.Context) (string, error) {
 	}
 	return userID, nil
 }
+
+// LinkKey returns the storage key of alias on the custom domain named by the host query parameter,
+// on the default domain without one. Endpoints that take an alias in the path use it.
+func LinkKey(r *http.Request, alias string) string {
+	return storage.Key(storage.NormalizeHost(r.URL.Query().Get("host")), alias)
+}
+
+// ResolveExpiration turns the expires_at or ttl request fields into the moment a link expires,
+// nil if it never does. ttl is a duration like "72h" counted from now.
+func ResolveExpiration(expiresAt *time.Time, ttl string, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && ttl != "" {
+		return nil, errors.New("only one of expires_at and ttl can be set")
+	}
+	if ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, errors.New("field TTL is not a valid positive duration")
+		}
+		at := now.Add(d)
+		return &at, nil
+	}
+	if expiresAt != nil && !expiresAt.After(now) {
+		return nil, errors.New("field ExpiresAt must be in the future")
+	}
+	return expiresAt, nil
+}
@@ -0,0 +1,37 @@
+package debug
+
+import (
+	"github.com/go-chi/render"
+	"net/http"
+)
+
+// CacheStats reports the counters of the redirect cache, see cache.Cache.
+type CacheStats interface {
+	Stats() (hits, misses uint64)
+	Len() int
+}
+
+type CacheVars struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Size   int    `json:"size"`
+}
+
+// Vars keeps the layout of expvar, so scrapers of /debug/vars keep working.
+type Vars struct {
+	RedirectCache *CacheVars `json:"redirect_cache,omitempty"`
+}
+
+// NewVars serves the redirect cache counters, and nothing else: the expvar handler would hand the
+// command line and memory stats of the process to every logged in user. cache is nil when the
+// cache is disabled.
+func NewVars(cache CacheStats) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var vars Vars
+		if cache != nil {
+			hits, misses := cache.Stats()
+			vars.RedirectCache = &CacheVars{Hits: hits, Misses: misses, Size: cache.Len()}
+		}
+		render.JSON(w, r, vars)
+	}
+}
@@ -0,0 +1,25 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCache struct{}
+
+func (fakeCache) Stats() (hits, misses uint64) { return 7, 3 }
+func (fakeCache) Len() int                     { return 2 }
+
+func TestVars(t *testing.T) {
+	rr := httptest.NewRecorder()
+	NewVars(fakeCache{}).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"redirect_cache": {"hits": 7, "misses": 3, "size": 2}}`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	NewVars(nil).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.JSONEq(t, `{}`, rr.Body.String())
+}
@@ -0,0 +1,256 @@
+package deleteURL
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	"url_shortener/httpServer/handlers/url/update"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+const (
+	ActionDelete = "delete"
+	ActionUpdate = "update"
+)
+
+// BulkRequest selects links by Aliases or by Filter, not both, and deletes or updates them.
+// With DryRun nothing changes and the results tell what would.
+type BulkRequest struct {
+	Action  string          `json:"action" validate:"required,oneof=delete update"`
+	Aliases []string        `json:"aliases,omitempty"` // "host/alias" for links of a custom domain
+	Filter  *Filter         `json:"filter,omitempty"`
+	Update  *update.Request `json:"update,omitempty"` // required for ActionUpdate
+	DryRun  bool            `json:"dry_run,omitempty"`
+}
+
+// Filter matches the caller's links, all set fields must match.
+type Filter struct {
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"` // inclusive
+	Domain        string     `json:"domain,omitempty"`        // exact target host, case-insensitive
+}
+
+func (f Filter) empty() bool {
+	return f.CreatedBefore == nil && f.CreatedAfter == nil && f.Domain == ""
+}
+
+// BulkResult is the outcome for one alias. URL and ExpiresAt are the link after the change.
+type BulkResult struct {
+	Alias     string     `json:"alias"`
+	OK        bool       `json:"ok"`
+	URL       string     `json:"url,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type BulkResponse struct {
+	resp.Response
+	DryRun  bool         `json:"dry_run,omitempty"`
+	Results []BulkResult `json:"results,omitempty"`
+}
+
+// LinkFinder reads the links a bulk request selects.
+type LinkFinder interface {
+	GetLink(alias string) (storage.Link, error)
+	ListURLs(ctx context.Context, q storage.ListQuery) ([]storage.LinkSummary, error)
+}
+
+// LinkWriter changes links, it is the redirect cache when one is enabled.
+type LinkWriter interface {
+	URLRemover
+	update.URLUpdater
+}
+
+// NewBulk deletes or updates up to limit links of the caller in one request. Every alias gets
+// its own result, with the same errors as the single alias endpoints.
+func NewBulk(log *slog.Logger, finder LinkFinder, writer LinkWriter, limit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.deleteURL.NewBulk"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not get user id from context, unauthorized"))
+			return
+		}
+
+		var req BulkRequest
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ErrorValidator(validateErr))
+			return
+		}
+
+		var upd storage.LinkUpdate
+		if req.Action == ActionUpdate {
+			if req.Update == nil {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error("update is required for the update action"))
+				return
+			}
+			upd, err = req.Update.LinkUpdate(time.Now())
+			if err != nil {
+				log.Error("invalid update", sl.Err(err))
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error(err.Error()))
+				return
+			}
+		}
+
+		aliases := req.Aliases
+		switch {
+		case len(req.Aliases) > 0 && req.Filter != nil:
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("aliases and filter can't be combined"))
+			return
+		case len(req.Aliases) > limit:
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("at most %d aliases in one request", limit)))
+			return
+		case req.Filter != nil && !req.Filter.empty():
+			aliases, err = match(r.Context(), finder, creator, *req.Filter, limit)
+			if errors.Is(err, errTooMany) {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, resp.Error(fmt.Sprintf("filter matches more than %d links, narrow it", limit)))
+				return
+			}
+			if err != nil {
+				log.Error("failed to match links", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("internal error"))
+				return
+			}
+		case len(req.Aliases) == 0:
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("aliases or a non-empty filter are required"))
+			return
+		}
+
+		results := make([]BulkResult, len(aliases))
+		for i, alias := range aliases {
+			var link storage.Link
+			if req.DryRun {
+				link, err = preview(finder, alias, creator, req.Action, upd)
+			} else if req.Action == ActionDelete {
+				link, err = deleteOne(writer, alias, creator)
+			} else {
+				link, err = writer.UpdateURL(alias, creator, upd)
+			}
+			results[i] = result(log, alias, link, err)
+		}
+
+		log.Info("bulk request done", slog.String("action", req.Action), slog.Int("aliases", len(aliases)), slog.Bool("dry_run", req.DryRun))
+
+		render.JSON(w, r, BulkResponse{Response: resp.OK(), DryRun: req.DryRun, Results: results})
+	}
+}
+
+var errTooMany = errors.New("too many links")
+
+// match returns the aliases of the creator's links the filter selects, errTooMany past limit.
+func match(ctx context.Context, finder LinkFinder, creator string, f Filter, limit int) ([]string, error) {
+	q := storage.ListQuery{
+		Creator:     creator,
+		Domain:      f.Domain,
+		CreatedFrom: f.CreatedAfter,
+		CreatedTo:   f.CreatedBefore,
+		Limit:       limit + 1,
+	}
+	var aliases []string
+	for {
+		page, err := finder.ListURLs(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		for _, link := range page {
+			// the storage matches domains as substrings, a bulk change wants the exact host
+			if f.Domain != "" && !strings.EqualFold(storage.TargetHost(link.URL), f.Domain) {
+				continue
+			}
+			aliases = append(aliases, link.Key())
+		}
+		if len(aliases) > limit {
+			return nil, errTooMany
+		}
+		if len(page) < q.Limit {
+			return aliases, nil
+		}
+		cursor := page[len(page)-1].Cursor()
+		q.After = &cursor
+	}
+}
+
+func deleteOne(writer LinkWriter, alias, creator string) (storage.Link, error) {
+	ok, err := writer.DeleteURL(alias, creator)
+	if err == nil && !ok {
+		err = storage.ErrCaseMismatch
+	}
+	return storage.Link{}, err
+}
+
+// preview reports what deleting or updating alias would do, with the errors DeleteURL and UpdateURL
+// would return.
+func preview(finder LinkFinder, alias, creator, action string, upd storage.LinkUpdate) (storage.Link, error) {
+	link, err := finder.GetLink(alias)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		return storage.Link{}, storage.ErrCaseMismatch
+	}
+	if err != nil {
+		return storage.Link{}, err
+	}
+	if link.Creator != creator {
+		return storage.Link{}, storage.ErrAliasNotFound
+	}
+	if action == ActionDelete {
+		return storage.Link{}, nil
+	}
+	return upd.Apply(link), nil
+}
+
+func result(log *slog.Logger, alias string, link storage.Link, err error) BulkResult {
+	switch {
+	case err == nil:
+		return BulkResult{Alias: alias, OK: true, URL: link.URL, ExpiresAt: link.ExpiresAt}
+	case errors.Is(err, storage.ErrAliasNotFound):
+		return BulkResult{Alias: alias, Error: "alias not found"}
+	case errors.Is(err, storage.ErrCaseMismatch):
+		return BulkResult{Alias: alias, Error: "case sensitivity problem"}
+	default:
+		log.Error("failed to change url", slog.String("alias", alias), sl.Err(err))
+		return BulkResult{Alias: alias, Error: "internal error"}
+	}
+}
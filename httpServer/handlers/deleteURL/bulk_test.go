@@ -0,0 +1,110 @@
+package deleteURL
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulk(t *testing.T) {
+	cases := []struct {
+		name        string
+		body        string
+		wantStatus  int
+		wantResults map[string]string // alias -> error, "" for success
+		wantLeft    []string
+		wantURL     string // of "a" afterwards
+	}{
+		{
+			name:        "Delete aliases",
+			body:        `{"action": "delete", "aliases": ["a", "c", "A", "missing"]}`,
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"a": "", "c": "alias not found", "A": "case sensitivity problem", "missing": "case sensitivity problem"},
+			wantLeft:    []string{"b", "c"},
+		},
+		{
+			name:        "Delete by domain",
+			body:        `{"action": "delete", "filter": {"domain": "EXAMPLE.com"}}`,
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"a": ""},
+			wantLeft:    []string{"b", "c"},
+		},
+		{
+			name:        "Dry run",
+			body:        `{"action": "update", "filter": {"domain": "example.com"}, "update": {"url": "https://new.com"}, "dry_run": true}`,
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"a": ""},
+			wantLeft:    []string{"a", "b", "c"},
+			wantURL:     "https://example.com/a",
+		},
+		{
+			name:        "Update aliases",
+			body:        `{"action": "update", "aliases": ["a", "c"], "update": {"url": "https://new.com"}}`,
+			wantStatus:  http.StatusOK,
+			wantResults: map[string]string{"a": "", "c": "alias not found"},
+			wantLeft:    []string{"a", "b", "c"},
+			wantURL:     "https://new.com",
+		},
+		{
+			name:       "No selection",
+			body:       `{"action": "delete", "filter": {}}`,
+			wantStatus: http.StatusBadRequest,
+			wantLeft:   []string{"a", "b", "c"},
+		},
+		{
+			name:       "Update without changes",
+			body:       `{"action": "update", "aliases": ["a"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantLeft:   []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := memory.NewStorage()
+			for alias, link := range map[string]storage.Link{
+				"a": {URL: "https://example.com/a", Creator: "owner"},
+				"b": {URL: "https://sub.example.com/b", Creator: "owner"},
+				"c": {URL: "https://example.com/c", Creator: "someone else"},
+			} {
+				link.Alias = alias
+				_, err := s.SaveURL(link)
+				require.NoError(t, err)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/url/bulk", bytes.NewBufferString(tc.body))
+			req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+			rr := httptest.NewRecorder()
+			NewBulk(slogdiscard.NewDiscardLogger(), s, s, 10).ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			var resp BulkResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Len(t, resp.Results, len(tc.wantResults))
+			for _, res := range resp.Results {
+				wantErr, ok := tc.wantResults[res.Alias]
+				require.True(t, ok, res.Alias)
+				require.Equal(t, wantErr, res.Error, res.Alias)
+				require.Equal(t, wantErr == "", res.OK, res.Alias)
+			}
+
+			for _, alias := range []string{"a", "b", "c"} {
+				link, err := s.GetLink(alias)
+				left := err == nil
+				require.Equal(t, slices.Contains(tc.wantLeft, alias), left, alias)
+				if alias == "a" && tc.wantURL != "" {
+					require.Equal(t, tc.wantURL, link.URL)
+				}
+			}
+		})
+	}
+}
@@ -45,7 +45,7 @@ func New(log *slog.Logger, urlRemover URLRemover) http.HandlerFunc {
 			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
 			return
 		}
-		ok, err := urlRemover.DeleteURL(alias, creator)
+		ok, err := urlRemover.DeleteURL(handlers.LinkKey(r, alias), creator)
 		if err != nil {
 			// Check for specific error types or sentinel errors
 			if errors.Is(err, storage.ErrAliasNotFound) {
@@ -0,0 +1,69 @@
+package deleteURL
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	"url_shortener/httpServer/handlers/url/update"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+type URLRestorer interface {
+	RestoreURL(alias, creator string) (storage.Link, error)
+}
+
+// NewRestore takes a deleted link of the caller out of the trash, it redirects again right away.
+func NewRestore(log *slog.Logger, urlRestorer URLRestorer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.deleteURL.NewRestore"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := mux.Vars(r)["alias"]
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not get user id from context, unauthorized"))
+			return
+		}
+
+		link, err := urlRestorer.RestoreURL(handlers.LinkKey(r, alias), creator)
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			log.Info("Alias not in trash", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("alias not found in trash"))
+			return
+		}
+		if err != nil {
+			log.Error("Failed to restore URL", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("URL restored", slog.String("alias", alias))
+		render.JSON(w, r, update.Response{
+			Response:  resp.OK(),
+			Alias:     link.Alias,
+			Domain:    link.Domain,
+			URL:       link.URL,
+			CreatedAt: link.CreatedAt,
+			ExpiresAt: link.ExpiresAt,
+		})
+	}
+}
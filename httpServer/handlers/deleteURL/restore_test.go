@@ -0,0 +1,53 @@
+package deleteURL
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url_shortener/httpServer/handlers/url/update"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestore(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "a", URL: "https://example.com", Creator: "owner"})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/url/{alias}", New(slogdiscard.NewDiscardLogger(), s)).Methods(http.MethodDelete)
+	router.HandleFunc("/url/{alias}/restore", NewRestore(slogdiscard.NewDiscardLogger(), s)).Methods(http.MethodPost)
+	serve := func(method, path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", user))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/url/a/restore", "owner").Code, "live links are not in the trash")
+
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete, "/url/a", "owner").Code)
+	_, err = s.GetURL("a")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = s.SaveURL(storage.Link{Alias: "a", URL: "https://other.com", Creator: "someone else"})
+	require.ErrorIs(t, err, storage.ErrURLExists, "deleted aliases stay reserved")
+
+	require.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/url/a/restore", "someone else").Code)
+
+	rr := serve(http.MethodPost, "/url/a/restore", "owner")
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp update.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "https://example.com", resp.URL)
+
+	url, err := s.GetURL("a")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", url)
+}
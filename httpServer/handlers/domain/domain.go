@@ -0,0 +1,326 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator"
+	"github.com/gorilla/mux"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// Request registers a custom domain, Host is taken lower case and without a port.
+type Request struct {
+	Host string `json:"host" validate:"required,fqdn,max=253"`
+}
+
+type Domain struct {
+	Host      string     `json:"host"`
+	CreatedAt time.Time  `json:"created_at"`
+	Verified  bool       `json:"verified"`
+	Challenge *Challenge `json:"challenge,omitempty"` // what to publish to verify the domain, until it is verified
+}
+
+// Challenge is the DNS TXT record proving control of a domain: a TXT record Name with Value.
+type Challenge struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+const (
+	challengePrefix = "_url-shortener-challenge."
+	challengeValue  = "url-shortener-verification="
+)
+
+// ChallengeOf is the TXT record owner publishes on host to verify it. It only depends on host and
+// owner, so publishing it once is enough and the record of one user is no good for another.
+func ChallengeOf(host, owner string) Challenge {
+	sum := sha256.Sum256([]byte(host + "\x00" + owner))
+	return Challenge{Name: challengePrefix + host, Value: challengeValue + hex.EncodeToString(sum[:])}
+}
+
+type Response struct {
+	resp.Response
+	Domain
+}
+
+type ListResponse struct {
+	resp.Response
+	Domains []Domain `json:"domains"`
+}
+
+type Creator interface {
+	CreateDomain(d storage.Domain) (storage.Domain, error)
+}
+
+type Lister interface {
+	ListDomains(owner string) ([]storage.Domain, error)
+}
+
+type Verifier interface {
+	VerifyDomain(host, owner string) (storage.Domain, error)
+}
+
+// TXTResolver looks the TXT records of name up, net.DefaultResolver does.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+type Remover interface {
+	DeleteDomain(host, owner string) (bool, error)
+}
+
+// Invalidator drops the registered hosts the redirect handler holds, see redirect.Hosts.
+type Invalidator interface {
+	Invalidate()
+}
+
+// NewCreate claims a custom domain for the caller. It serves nothing until the caller verifies it
+// with NewVerify, the response carries the challenge to publish. Hosts in reserved serve the default
+// domain and can't be registered.
+func NewCreate(log *slog.Logger, creator Creator, reserved []string) http.HandlerFunc {
+	taken := takenHosts(reserved)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.domain.NewCreate"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+
+		var req Request
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		req.Host = storage.NormalizeHost(req.Host)
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ErrorValidator(validateErr))
+			return
+		}
+		if _, ok := taken[req.Host]; ok {
+			log.Info("domain is reserved", slog.String("host", req.Host))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("domain already exists"))
+			return
+		}
+
+		d, err := creator.CreateDomain(storage.Domain{Host: req.Host, Owner: owner})
+		if errors.Is(err, storage.ErrDomainExists) {
+			log.Info("domain already exists", slog.String("host", req.Host))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("domain already exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to create domain", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("domain created", slog.String("host", d.Host))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, Response{Response: resp.OK(), Domain: domainOf(d)})
+	}
+}
+
+// NewVerify verifies a custom domain of the caller by looking its challenge up in DNS, see ChallengeOf.
+// A verified domain starts serving its aliases. Verifying takes over an unverified claim of another
+// user: whoever controls the DNS of a host gets it.
+func NewVerify(log *slog.Logger, verifier Verifier, resolver TXTResolver, hosts Invalidator, reserved []string) http.HandlerFunc {
+	taken := takenHosts(reserved)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.domain.NewVerify"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+
+		req := Request{Host: storage.NormalizeHost(mux.Vars(r)["host"])}
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ErrorValidator(validateErr))
+			return
+		}
+		if _, ok := taken[req.Host]; ok {
+			log.Info("domain is reserved", slog.String("host", req.Host))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("domain already exists"))
+			return
+		}
+
+		challenge := ChallengeOf(req.Host, owner)
+		records, err := resolver.LookupTXT(r.Context(), challenge.Name)
+		if err != nil {
+			// NXDOMAIN and friends: the record is not published (yet)
+			log.Info("failed to look challenge up", slog.String("host", req.Host), sl.Err(err))
+		}
+		if !slices.Contains(records, challenge.Value) {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("TXT record "+challenge.Name+" does not hold "+challenge.Value))
+			return
+		}
+
+		d, err := verifier.VerifyDomain(req.Host, owner)
+		if errors.Is(err, storage.ErrDomainExists) {
+			log.Info("domain already exists", slog.String("host", req.Host))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("domain already exists"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to verify domain", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+		hosts.Invalidate()
+
+		log.Info("domain verified", slog.String("host", d.Host))
+
+		render.JSON(w, r, Response{Response: resp.OK(), Domain: domainOf(d)})
+	}
+}
+
+// NewList lists the custom domains of the caller by host.
+func NewList(log *slog.Logger, lister Lister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.domain.NewList"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+
+		domains, err := lister.ListDomains(owner)
+		if err != nil {
+			log.Error("failed to list domains", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		response := ListResponse{Response: resp.OK(), Domains: make([]Domain, 0, len(domains))}
+		for _, d := range domains {
+			response.Domains = append(response.Domains, domainOf(d))
+		}
+		render.JSON(w, r, response)
+	}
+}
+
+// NewDelete removes a custom domain of the caller. Domains that still have links, trashed ones
+// included, answer 409: their aliases would start resolving on the default domain.
+func NewDelete(log *slog.Logger, remover Remover, hosts Invalidator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.domain.NewDelete"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		owner, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+		host := mux.Vars(r)["host"]
+
+		_, err = remover.DeleteDomain(host, owner)
+		if errors.Is(err, storage.ErrDomainNotFound) {
+			log.Info("domain not found", slog.String("host", host))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("domain not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrDomainInUse) {
+			log.Info("domain has links", slog.String("host", host))
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("domain has links"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to delete domain", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+		hosts.Invalidate()
+
+		log.Info("domain deleted", slog.String("host", host))
+
+		render.JSON(w, r, resp.OK())
+	}
+}
+
+func domainOf(d storage.Domain) Domain {
+	domain := Domain{Host: d.Host, CreatedAt: d.CreatedAt, Verified: d.Verified()}
+	if !domain.Verified {
+		challenge := ChallengeOf(d.Host, d.Owner)
+		domain.Challenge = &challenge
+	}
+	return domain
+}
+
+func takenHosts(reserved []string) map[string]struct{} {
+	taken := make(map[string]struct{}, len(reserved))
+	for _, host := range reserved {
+		taken[storage.NormalizeHost(host)] = struct{}{}
+	}
+	return taken
+}
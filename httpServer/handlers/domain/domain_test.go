@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type invalidations int
+
+func (i *invalidations) Invalidate() { *i++ }
+
+// zone is a fake DNS of TXT records by name.
+type zone map[string][]string
+
+func (z zone) LookupTXT(_ context.Context, name string) ([]string, error) {
+	records, ok := z[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+func TestDomains(t *testing.T) {
+	s := memory.NewStorage()
+	var hosts invalidations
+	log := slogdiscard.NewDiscardLogger()
+	router := mux2.NewRouter()
+	router.Handle("/domains", NewList(log, s)).Methods(http.MethodGet)
+	router.Handle("/domains", NewCreate(log, s, []string{"sho.rt"})).Methods(http.MethodPost)
+	dns := zone{}
+	router.Handle("/domains/{host}/verify", NewVerify(log, s, dns, &hosts, []string{"sho.rt"})).Methods(http.MethodPost)
+	router.Handle("/domains/{host}", NewDelete(log, s, &hosts)).Methods(http.MethodDelete)
+
+	serve := func(method, target, body, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", user))
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(http.MethodPost, "/domains", `{"host": "Go.Acme.com:443"}`, "owner")
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var created Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	require.Equal(t, "go.acme.com", created.Host)
+	require.False(t, created.Verified)
+	require.Equal(t, ChallengeOf("go.acme.com", "owner"), *created.Challenge)
+	require.Zero(t, hosts, "unverified domains serve nothing")
+
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, "/domains", `{"host": "go.acme.com"}`, "owner").Code)
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, "/domains", `{"host": "SHO.RT"}`, "owner").Code)
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/domains", `{"host": "not a host"}`, "owner").Code)
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, "/domains/sho.rt/verify", "", "owner").Code)
+
+	// an unverified claim holds nothing: no links, and anyone may claim the host over
+	_, err := s.SaveURL(storage.Link{Alias: "sale", URL: "https://acme.com/sale", Creator: "owner", Domain: "go.acme.com"})
+	require.ErrorIs(t, err, storage.ErrDomainNotVerified)
+	require.Equal(t, http.StatusCreated, serve(http.MethodPost, "/domains", `{"host": "go.acme.com"}`, "squatter").Code)
+
+	// the record of someone else proves nothing
+	squatter := ChallengeOf("go.acme.com", "squatter")
+	dns[squatter.Name] = []string{squatter.Value}
+	rr = serve(http.MethodPost, "/domains/go.acme.com/verify", "", "owner")
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), created.Challenge.Value)
+	require.Zero(t, hosts)
+
+	dns[created.Challenge.Name] = []string{"v=spf1 -all", created.Challenge.Value}
+	rr = serve(http.MethodPost, "/domains/Go.Acme.com/verify", "", "owner")
+	require.Equal(t, http.StatusOK, rr.Code, "control of the DNS wins over the claim")
+	var verified Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &verified))
+	require.True(t, verified.Verified)
+	require.Nil(t, verified.Challenge)
+	require.EqualValues(t, 1, hosts)
+	require.Equal(t, http.StatusOK, serve(http.MethodPost, "/domains/go.acme.com/verify", "", "owner").Code, "verifying again is a no-op")
+
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, "/domains", `{"host": "go.acme.com"}`, "squatter").Code)
+	dns[created.Challenge.Name] = append(dns[created.Challenge.Name], squatter.Value)
+	require.Equal(t, http.StatusConflict, serve(http.MethodPost, "/domains/go.acme.com/verify", "", "squatter").Code)
+
+	rr = serve(http.MethodGet, "/domains", "", "owner")
+	var list ListResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list.Domains, 1)
+	require.True(t, list.Domains[0].Verified)
+	rr = serve(http.MethodGet, "/domains", "", "someone else")
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Empty(t, list.Domains)
+
+	_, err = s.SaveURL(storage.Link{Alias: "sale", URL: "https://acme.com/sale", Creator: "owner", Domain: "go.acme.com"})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/domains/go.acme.com", "", "someone else").Code)
+	require.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/domains/go.acme.com", "", "owner").Code)
+
+	_, err = s.DeleteURL(storage.Key("go.acme.com", "sale"), "owner")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusConflict, serve(http.MethodDelete, "/domains/go.acme.com", "", "owner").Code, "trashed links still hold the domain")
+
+	_, err = s.PurgeDeleted(context.Background(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, serve(http.MethodDelete, "/domains/go.acme.com", "", "owner").Code)
+	require.EqualValues(t, 3, hosts)
+	require.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/domains/go.acme.com", "", "owner").Code)
+}
@@ -0,0 +1,202 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers/redirect"
+	"url_shortener/internal/config"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/lib/qrcode"
+	"url_shortener/internal/storage"
+)
+
+const (
+	FormatPNG = "png"
+	FormatSVG = "svg"
+
+	defaultSize   = 256
+	minSize       = 32
+	defaultMargin = 4
+	maxMargin     = 20
+)
+
+type LinkGetter interface {
+	GetLink(alias string) (storage.Link, error)
+}
+
+// Options are the query parameters of a qr code.
+type Options struct {
+	Format string
+	Level  qrcode.Level
+	Style  qrcode.Style
+}
+
+// New renders the short url of an alias as a qr code. Query parameters: format (png or svg,
+// defaults to png), size in pixels (defaults to 256), margin in modules (defaults to 4), ecc (L, M,
+// Q or H, defaults to M) and fg and bg colors as rrggbb (default black on white). Like redirects,
+// the alias is looked up on the custom domain of the request host.
+func New(log *slog.Logger, links LinkGetter, hosts *redirect.Hosts, cfg config.QR) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.qr.New"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := mux.Vars(r)["alias"]
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		opts, err := ParseOptions(r, cfg.MaxSize)
+		if err != nil {
+			log.Info("invalid qr options", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		domain, err := hosts.Domain(r)
+		if err != nil {
+			log.Error("failed to resolve custom domain", sl.Err(err))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, resp.Error("service unavailable"))
+			return
+		}
+		link, err := links.GetLink(storage.Key(domain, alias))
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("url not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get link", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+		if link.Expired(time.Now()) {
+			log.Info("url expired", slog.String("alias", alias))
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, resp.Error("url expired"))
+			return
+		}
+
+		code, err := qrcode.Encode(ShortURL(r, cfg.BaseURL, domain, link.Alias), opts.Level)
+		if err != nil {
+			log.Error("failed to encode qr code", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+		var buf bytes.Buffer
+		contentType := "image/png"
+		if opts.Format == FormatSVG {
+			contentType = "image/svg+xml"
+			err = code.SVG(&buf, opts.Style)
+		} else {
+			err = code.PNG(&buf, opts.Style)
+		}
+		if err != nil {
+			log.Error("failed to render qr code", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		// the code only depends on the alias and the options, but the link may be deleted
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		if _, err := buf.WriteTo(w); err != nil {
+			log.Error("failed to write qr code", sl.Err(err))
+		}
+	}
+}
+
+// ParseOptions reads the qr code query parameters, sizes are at most maxSize pixels.
+func ParseOptions(r *http.Request, maxSize int) (Options, error) {
+	query := r.URL.Query()
+	opts := Options{
+		Format: FormatPNG,
+		Level:  qrcode.Medium,
+		Style: qrcode.Style{
+			Size:       defaultSize,
+			Margin:     defaultMargin,
+			Foreground: qrcode.Black,
+			Background: qrcode.White,
+		},
+	}
+
+	switch format := query.Get("format"); format {
+	case "", FormatPNG:
+	case FormatSVG:
+		opts.Format = FormatSVG
+	default:
+		return Options{}, fmt.Errorf("field format must be one of png, svg")
+	}
+	if raw := query.Get("size"); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < minSize || size > maxSize {
+			return Options{}, fmt.Errorf("field size must be between %d and %d", minSize, maxSize)
+		}
+		opts.Style.Size = size
+	}
+	if raw := query.Get("margin"); raw != "" {
+		margin, err := strconv.Atoi(raw)
+		if err != nil || margin < 0 || margin > maxMargin {
+			return Options{}, fmt.Errorf("field margin must be between 0 and %d", maxMargin)
+		}
+		opts.Style.Margin = margin
+	}
+	if raw := query.Get("ecc"); raw != "" {
+		level, err := qrcode.ParseLevel(raw)
+		if err != nil {
+			return Options{}, fmt.Errorf("field ecc must be one of L, M, Q, H")
+		}
+		opts.Level = level
+	}
+	var err error
+	if raw := query.Get("fg"); raw != "" {
+		if opts.Style.Foreground, err = qrcode.ParseColor(raw); err != nil {
+			return Options{}, fmt.Errorf("field fg must be a color like 1a2b3c")
+		}
+	}
+	if raw := query.Get("bg"); raw != "" {
+		if opts.Style.Background, err = qrcode.ParseColor(raw); err != nil {
+			return Options{}, fmt.Errorf("field bg must be a color like 1a2b3c")
+		}
+	}
+	return opts, nil
+}
+
+// ShortURL is the url of alias on domain. base is the scheme and host of the default domain,
+// links of custom domains keep its scheme. Without a base both come from the request.
+func ShortURL(r *http.Request, base, domain, alias string) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if u, err := url.Parse(base); err == nil && u.Scheme != "" && u.Host != "" {
+		scheme, host = u.Scheme, strings.TrimSuffix(u.Host+u.Path, "/")
+	}
+	if domain != "" {
+		host = domain
+	}
+	return scheme + "://" + host + "/" + url.PathEscape(alias)
+}
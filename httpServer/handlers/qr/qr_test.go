@@ -0,0 +1,103 @@
+package qr
+
+import (
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url_shortener/httpServer/handlers/redirect"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQR(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "flyer", URL: "https://example.com/spring", Creator: "owner"})
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Hour)
+	_, err = s.SaveURL(storage.Link{Alias: "old", URL: "https://example.com/old", Creator: "owner", ExpiresAt: &expired})
+	require.NoError(t, err)
+
+	router := mux2.NewRouter()
+	router.Handle("/{alias}/qr", New(slogdiscard.NewDiscardLogger(), s, nil, config.QR{MaxSize: 1024})).Methods(http.MethodGet)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	t.Run("PNG", func(t *testing.T) {
+		rr := serve("/flyer/qr?size=100&margin=1")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+		img, err := png.Decode(rr.Body)
+		require.NoError(t, err)
+		// the short url needs version 2, 25 modules and a margin of 1 on each side
+		assert.Equal(t, 81, img.Bounds().Dx())
+	})
+
+	t.Run("SVG with colors", func(t *testing.T) {
+		rr := serve("/flyer/qr?format=svg&fg=%23003366&bg=fafafa&ecc=H")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+		body := rr.Body.String()
+		assert.True(t, strings.HasPrefix(body, "<?xml"))
+		assert.Contains(t, body, `fill="#003366"`)
+		assert.Contains(t, body, `fill="#fafafa"`)
+	})
+
+	t.Run("Invalid options", func(t *testing.T) {
+		for _, query := range []string{"format=gif", "size=5000", "size=abc", "margin=-1", "ecc=X", "fg=red"} {
+			rr := serve("/flyer/qr?" + query)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+
+	t.Run("Unknown alias", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, serve("/missing/qr").Code)
+	})
+
+	t.Run("Expired link", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, serve("/old/qr").Code)
+	})
+}
+
+func TestShortURL(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/flyer/qr", nil)
+	req.Host = "localhost:8082"
+
+	assert.Equal(t, "http://localhost:8082/flyer", ShortURL(req, "", "", "flyer"))
+	assert.Equal(t, "https://sho.rt/flyer", ShortURL(req, "https://sho.rt/", "", "flyer"))
+	assert.Equal(t, "https://go.acme.com/flyer", ShortURL(req, "https://sho.rt", "go.acme.com", "flyer"))
+	assert.Equal(t, "http://go.acme.com/flyer", ShortURL(req, "", "go.acme.com", "flyer"))
+}
+
+func TestQRCustomDomain(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.VerifyDomain("go.acme.com", "owner")
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{Alias: "sale", URL: "https://acme.com/sale", Creator: "owner", Domain: "go.acme.com"})
+	require.NoError(t, err)
+
+	hosts := redirect.NewHosts(slogdiscard.NewDiscardLogger(), s, config.Domains{RefreshInterval: time.Minute})
+	router := mux2.NewRouter()
+	router.Handle("/{alias}/qr", New(slogdiscard.NewDiscardLogger(), s, hosts, config.QR{MaxSize: 1024})).Methods(http.MethodGet)
+
+	req := httptest.NewRequest(http.MethodGet, "/sale/qr", nil)
+	req.Host = "go.acme.com"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sale/qr", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "the alias only exists on the custom domain")
+}
@@ -0,0 +1,80 @@
+package redirect
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"log/slog"
+	"net/http"
+	"os"
+	"url_shortener/internal/config"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// ComingSoon is the answer to a link visited before its activation window when it has no
+// fallback url. Browsers get Page if there is one, other clients the json Message.
+type ComingSoon struct {
+	Status  int
+	Message string
+	Page    []byte
+}
+
+func NewComingSoon(cfg config.Activation) (ComingSoon, error) {
+	const info = "handlers.redirect.NewComingSoon"
+
+	cs := ComingSoon{Status: cfg.ComingSoonStatus, Message: cfg.ComingSoonMessage}
+	if cs.Status == 0 {
+		cs.Status = http.StatusNotFound
+	}
+	if cs.Message == "" {
+		cs.Message = "coming soon"
+	}
+	if cfg.ComingSoonPage != "" {
+		page, err := os.ReadFile(cfg.ComingSoonPage)
+		if err != nil {
+			return ComingSoon{}, fmt.Errorf("%s: %w", info, err)
+		}
+		cs.Page = page
+	}
+	return cs, nil
+}
+
+// inactive answers a visit of a link outside its activation window, err is storage.ErrURLNotStarted
+// or storage.ErrURLEnded and fallback the url to send the visitor to instead, if any.
+func inactive(w http.ResponseWriter, r *http.Request, log *slog.Logger, comingSoon ComingSoon, alias, fallback string, err error) {
+	// the window opens or closes at some point, nothing of this may be cached
+	w.Header().Set("Cache-Control", "no-store")
+	if fallback != "" {
+		log.Info("url not active, redirecting to fallback", slog.String("alias", alias), slog.String("url", fallback))
+		http.Redirect(w, r, fallback, http.StatusFound)
+		return
+	}
+	if errors.Is(err, storage.ErrURLEnded) {
+		log.Info("url no longer active", slog.String("alias", alias))
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, resp.Error("url is no longer active"))
+		return
+	}
+
+	log.Info("url not active yet", slog.String("alias", alias))
+	status := comingSoon.Status
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	if len(comingSoon.Page) > 0 && wantsHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		if _, err := w.Write(comingSoon.Page); err != nil {
+			log.Error("failed to write coming soon page", sl.Err(err))
+		}
+		return
+	}
+	msg := comingSoon.Message
+	if msg == "" {
+		msg = "coming soon"
+	}
+	render.Status(r, status)
+	render.JSON(w, r, resp.Error(msg))
+}
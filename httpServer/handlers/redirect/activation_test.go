@@ -0,0 +1,64 @@
+package redirect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectActivation(t *testing.T) {
+	now := time.Now()
+	later, earlier := now.Add(time.Hour), now.Add(-time.Hour)
+
+	s := memory.NewStorage()
+	links := []storage.Link{
+		{Alias: "launch", URL: "https://example.com/launch", NotBefore: &later},
+		{Alias: "teaser", URL: "https://example.com/launch", NotBefore: &later, FallbackURL: "https://example.com/teaser"},
+		{Alias: "over", URL: "https://example.com/sale", NotAfter: &earlier},
+		{Alias: "moved", URL: "https://example.com/old", Schedule: storage.Schedule{{At: earlier, URL: "https://example.com/new"}}},
+	}
+	for _, link := range links {
+		_, err := s.SaveURL(link)
+		require.NoError(t, err)
+	}
+
+	comingSoon := ComingSoon{Status: http.StatusServiceUnavailable, Message: "soon", Page: []byte("<p>soon</p>")}
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), s, nil, nil, s, comingSoon, nil)).Methods(http.MethodGet)
+
+	get := func(alias, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/"+alias, nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("launch", "application/json")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Contains(t, rr.Body.String(), "soon")
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+	rr = get("launch", "text/html")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "<p>soon</p>", rr.Body.String())
+
+	rr = get("teaser", "")
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://example.com/teaser", rr.Header().Get("Location"))
+
+	rr = get("over", "")
+	assert.Equal(t, http.StatusGone, rr.Code)
+
+	rr = get("moved", "")
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://example.com/new", rr.Header().Get("Location"))
+}
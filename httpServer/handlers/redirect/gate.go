@@ -0,0 +1,170 @@
+package redirect
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/storage"
+)
+
+type LinkGetter interface {
+	GetLink(alias string) (storage.Link, error)
+}
+
+var (
+	errWrongPassword = errors.New("wrong password")
+	errLocked        = errors.New("too many wrong passwords")
+)
+
+// Gate opens password protected links. After MaxAttempts wrong passwords in a row from one client
+// a link refuses every password of that client for the Lockout period, so a stranger guessing can't
+// lock the owner out. Each attempt is reserved before the password is checked, concurrent guesses
+// count against the limit as they start. Like the redirect cache, attempts are counted per process.
+type Gate struct {
+	links LinkGetter
+	cfg   config.Protection
+	now   func() time.Time
+
+	mu       sync.Mutex
+	failures map[attemptKey]*failures
+}
+
+// attemptKey identifies who is guessing which link.
+type attemptKey struct {
+	alias  string
+	client string
+}
+
+type failures struct {
+	count       int
+	pending     int // attempts reserved but not checked yet
+	last        time.Time
+	lockedUntil time.Time
+}
+
+func NewGate(links LinkGetter, cfg config.Protection) *Gate {
+	return &Gate{
+		links:    links,
+		cfg:      cfg,
+		now:      time.Now,
+		failures: make(map[attemptKey]*failures),
+	}
+}
+
+// Unlock returns the link of alias if password opens it for client, errWrongPassword if it does not
+// and errLocked while the link is locked out for client. Links a redirect can't open anyway give the
+// storage errors, the errors of storage.Link.Target included.
+func (g *Gate) Unlock(alias, client, password string) (storage.Link, error) {
+	const info = "handlers.redirect.Gate.Unlock"
+
+	key := attemptKey{alias: alias, client: client}
+	if !g.reserve(key) {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, errLocked)
+	}
+
+	link, err := g.links.GetLink(alias)
+	if err == nil {
+		if _, err = link.Target(g.now()); errors.Is(err, storage.ErrURLProtected) || errors.Is(err, storage.ErrURLLimited) {
+			err = nil
+		}
+	}
+	if err != nil {
+		g.release(key, false, false)
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, err)
+	}
+	if !link.Protected() || link.CheckPassword(password) {
+		g.release(key, true, false)
+		return link, nil
+	}
+
+	g.release(key, false, true)
+	return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, errWrongPassword)
+}
+
+// LockedFor returns how long alias stays locked out for client, 0 if it takes passwords.
+func (g *Gate) LockedFor(alias, client string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f, ok := g.failures[attemptKey{alias: alias, client: client}]
+	if !ok {
+		return 0
+	}
+	return max(f.lockedUntil.Sub(g.now()), 0)
+}
+
+// reserve takes an attempt for key, false while it is locked out or has as many attempts in flight
+// as it may still get wrong.
+func (g *Gate) reserve(key attemptKey) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	g.prune(now)
+
+	f, ok := g.failures[key]
+	if !ok {
+		f = &failures{}
+		g.failures[key] = f
+	}
+	if now.Before(f.lockedUntil) || f.count+f.pending >= g.cfg.MaxAttempts {
+		return false
+	}
+	f.pending++
+	f.last = now
+	return true
+}
+
+// release ends an attempt reserved for key: a success starts the count over, a wrong password
+// counts and may lock the link out, an attempt that never got to the password does neither.
+func (g *Gate) release(key attemptKey, success, wrong bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f := g.failures[key]
+	f.pending--
+	now := g.now()
+	switch {
+	case success:
+		f.count = 0
+	case wrong:
+		f.count++
+		f.last = now
+		if f.count >= g.cfg.MaxAttempts {
+			f.count = 0
+			f.lockedUntil = now.Add(g.cfg.Lockout)
+		}
+	}
+	if f.count == 0 && f.pending == 0 && !now.Before(f.lockedUntil) {
+		delete(g.failures, key)
+	}
+}
+
+// prune forgets clients without a wrong password for a whole lockout period. Callers must hold g.mu.
+func (g *Gate) prune(now time.Time) {
+	for key, f := range g.failures {
+		if f.pending == 0 && now.Sub(f.last) > g.cfg.Lockout && !now.Before(f.lockedUntil) {
+			delete(g.failures, key)
+		}
+	}
+}
+
+// Client identifies the client of a request for lockouts: its ip, or with TrustForwardedFor the
+// first address of X-Forwarded-For.
+func (g *Gate) Client(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if g.cfg.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	return ip
+}
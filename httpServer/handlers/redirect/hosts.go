@@ -0,0 +1,134 @@
+package redirect
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// HostSource lists the hosts of all registered custom domains.
+type HostSource interface {
+	DomainHosts(ctx context.Context) ([]string, error)
+}
+
+// loadTimeout bounds a reload of the hosts, it runs on behalf of every request waiting for it.
+const loadTimeout = 5 * time.Second
+
+// Hosts tells which custom domain a request came in on. The registered hosts are kept in memory
+// and reloaded every RefreshInterval, or on the next request after Invalidate. Like the redirect
+// cache, other instances see a new domain after at most one interval.
+//
+// Reloads run outside the lock with a context of their own, concurrent requests share one. A
+// failed reload keeps the last hosts that loaded: an empty set would send requests of a custom
+// domain to the default domain aliases of the same name.
+type Hosts struct {
+	log    *slog.Logger
+	source HostSource
+	cfg    config.Domains
+	now    func() time.Time
+	group  singleflight.Group
+
+	mu       sync.Mutex
+	hosts    map[string]struct{} // nil until the first successful load
+	loadedAt time.Time
+	stale    bool   // set by Invalidate, cleared by a load started after it
+	version  uint64 // bumped by Invalidate
+}
+
+func NewHosts(log *slog.Logger, source HostSource, cfg config.Domains) *Hosts {
+	return &Hosts{
+		log:    log,
+		source: source,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Domain returns the custom domain of the request host, empty for the default domain: any host
+// that is not registered. It fails only while the hosts have never loaded, the request can't be
+// told apart from one of a custom domain then. A nil Hosts serves the default domain only.
+func (h *Hosts) Domain(r *http.Request) (string, error) {
+	if h == nil {
+		return "", nil
+	}
+	host := storage.NormalizeHost(r.Host)
+
+	hosts, err := h.current()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := hosts[host]; ok {
+		return host, nil
+	}
+	return "", nil
+}
+
+// Invalidate makes the next request reload the hosts, called when a domain is added or removed.
+// The current hosts stay in use until the reload succeeds.
+func (h *Hosts) Invalidate() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stale = true
+	h.version++
+	h.group.Forget("hosts")
+}
+
+// current returns the registered hosts, reloading them first when they are due.
+func (h *Hosts) current() (map[string]struct{}, error) {
+	h.mu.Lock()
+	hosts := h.hosts
+	due := hosts == nil || h.stale || h.now().Sub(h.loadedAt) >= h.cfg.RefreshInterval
+	h.mu.Unlock()
+	if !due {
+		return hosts, nil
+	}
+
+	loaded, err, _ := h.group.Do("hosts", h.load)
+	if err != nil {
+		if hosts == nil {
+			return nil, err
+		}
+		return hosts, nil
+	}
+	return loaded.(map[string]struct{}), nil
+}
+
+// load reads the registered hosts and puts them in place. When the source fails the old hosts are
+// kept, until the next interval unless they were invalidated.
+func (h *Hosts) load() (any, error) {
+	const info = "handlers.redirect.Hosts.load"
+
+	h.mu.Lock()
+	version := h.version
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	list, err := h.source.DomainHosts(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.log.Error("failed to load custom domains", sl.Err(err))
+		if h.hosts != nil && !h.stale {
+			h.loadedAt = h.now()
+		}
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	hosts := make(map[string]struct{}, len(list))
+	for _, host := range list {
+		hosts[host] = struct{}{}
+	}
+	h.hosts, h.loadedAt = hosts, h.now()
+	// a domain added or removed while loading may be missing, the next request loads again
+	h.stale = version != h.version
+	return hosts, nil
+}
@@ -0,0 +1,157 @@
+package redirect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectCustomDomain(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.VerifyDomain("go.acme.com", "owner")
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{Alias: "sale", URL: "https://example.com/default", Creator: "owner"})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{Alias: "sale", URL: "https://acme.com/sale", Creator: "owner", Domain: "go.acme.com"})
+	require.NoError(t, err)
+
+	hosts := NewHosts(slogdiscard.NewDiscardLogger(), s, config.Domains{RefreshInterval: time.Minute})
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), s, nil, nil, s, ComingSoon{}, hosts)).Methods(http.MethodGet)
+
+	cases := []struct {
+		name     string
+		host     string
+		path     string
+		code     int
+		location string
+	}{
+		{name: "Custom domain", host: "go.acme.com", path: "/sale", code: http.StatusFound, location: "https://acme.com/sale"},
+		{name: "Host with port and upper case", host: "GO.acme.com:8080", path: "/sale", code: http.StatusFound, location: "https://acme.com/sale"},
+		{name: "Default domain", host: "localhost:8082", path: "/sale", code: http.StatusFound, location: "https://example.com/default"},
+		{name: "Unregistered host is the default domain", host: "other.example", path: "/sale", code: http.StatusFound, location: "https://example.com/default"},
+		{name: "Aliases don't leak across domains", host: "go.acme.com", path: "/missing", code: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Host = tc.host
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			assert.Equal(t, tc.code, rr.Code)
+			assert.Equal(t, tc.location, rr.Header().Get("Location"))
+		})
+	}
+}
+
+func TestHostsRefresh(t *testing.T) {
+	s := memory.NewStorage()
+	hosts := NewHosts(slogdiscard.NewDiscardLogger(), s, config.Domains{RefreshInterval: time.Minute})
+	now := time.Now()
+	hosts.now = func() time.Time { return now }
+
+	req := httptest.NewRequest(http.MethodGet, "/sale", nil)
+	req.Host = "acme.link"
+	domain := func() string {
+		t.Helper()
+		domain, err := hosts.Domain(req)
+		require.NoError(t, err)
+		return domain
+	}
+	require.Empty(t, domain())
+
+	_, err := s.VerifyDomain("acme.link", "owner")
+	require.NoError(t, err)
+	require.Empty(t, domain(), "hosts are reloaded once per interval")
+
+	now = now.Add(time.Minute)
+	require.Equal(t, "acme.link", domain())
+
+	_, err = s.DeleteDomain("acme.link", "owner")
+	require.NoError(t, err)
+	hosts.Invalidate()
+	require.Empty(t, domain())
+
+	var none *Hosts
+	noneDomain, err := none.Domain(req)
+	require.NoError(t, err)
+	require.Empty(t, noneDomain)
+}
+
+// flakySource fails while err is set, and like a database when the context is done.
+type flakySource struct {
+	hosts []string
+	err   error
+	calls int
+}
+
+func (s *flakySource) DomainHosts(ctx context.Context) ([]string, error) {
+	s.calls++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return s.hosts, s.err
+}
+
+func TestHostsLoadFailure(t *testing.T) {
+	source := &flakySource{err: errors.New("connection refused")}
+	hosts := NewHosts(slogdiscard.NewDiscardLogger(), source, config.Domains{RefreshInterval: time.Minute})
+	now := time.Now()
+	hosts.now = func() time.Time { return now }
+
+	req := httptest.NewRequest(http.MethodGet, "/sale", nil)
+	req.Host = "go.acme.com"
+
+	// without hosts a custom domain request can't be told from a default domain one
+	_, err := hosts.Domain(req)
+	require.Error(t, err)
+
+	source.hosts, source.err = []string{"go.acme.com"}, nil
+	domain, err := hosts.Domain(req)
+	require.NoError(t, err)
+	require.Equal(t, "go.acme.com", domain)
+
+	// failed reloads keep the hosts that loaded, whether due to the interval or to Invalidate
+	source.err = errors.New("connection refused")
+	now = now.Add(time.Minute)
+	domain, err = hosts.Domain(req)
+	require.NoError(t, err)
+	require.Equal(t, "go.acme.com", domain)
+	calls := source.calls
+	_, _ = hosts.Domain(req)
+	require.Equal(t, calls, source.calls, "a failed periodic reload waits for the next interval")
+
+	hosts.Invalidate()
+	domain, err = hosts.Domain(req)
+	require.NoError(t, err)
+	require.Equal(t, "go.acme.com", domain)
+
+	source.hosts, source.err = nil, nil
+	domain, err = hosts.Domain(req)
+	require.NoError(t, err)
+	require.Empty(t, domain, "the invalidated hosts are reloaded once the source is back")
+}
+
+func TestHostsCancelledRequest(t *testing.T) {
+	source := &flakySource{hosts: []string{"go.acme.com"}}
+	hosts := NewHosts(slogdiscard.NewDiscardLogger(), source, config.Domains{RefreshInterval: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodGet, "/sale", nil).WithContext(ctx)
+	req.Host = "go.acme.com"
+	domain, err := hosts.Domain(req)
+	require.NoError(t, err)
+	require.Equal(t, "go.acme.com", domain, "loads don't run on the request context")
+}
@@ -2,7 +2,11 @@
 
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	storage "url_shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 // URLGetter is an autogenerated mock type for the URLGetter type
 type URLGetter struct {
@@ -37,6 +41,34 @@ func (_m *URLGetter) GetURL(alias string) (string, error) {
 	return r0, r1
 }
 
+// Passthrough provides a mock function with given fields: alias
+func (_m *URLGetter) Passthrough(alias string) (storage.Passthrough, error) {
+	ret := _m.Called(alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Passthrough")
+	}
+
+	var r0 storage.Passthrough
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (storage.Passthrough, error)); ok {
+		return rf(alias)
+	}
+	if rf, ok := ret.Get(0).(func(string) storage.Passthrough); ok {
+		r0 = rf(alias)
+	} else {
+		r0 = ret.Get(0).(storage.Passthrough)
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // NewURLGetter creates a new instance of URLGetter. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewURLGetter(t interface {
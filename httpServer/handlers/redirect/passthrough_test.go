@@ -0,0 +1,68 @@
+package redirect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectPassthrough(t *testing.T) {
+	one := int64(1)
+	later := time.Now().Add(time.Hour)
+
+	s := memory.NewStorage()
+	links := []storage.Link{
+		{Alias: "docs", URL: "https://example.com/manual/?lang=en", Passthrough: storage.Passthrough{Query: true, Path: true}},
+		{Alias: "plain", URL: "https://example.com/plain"},
+		{Alias: "once", URL: "https://example.com/once", ClicksLeft: &one},
+		{Alias: "launch", URL: "https://example.com/launch", NotBefore: &later, FallbackURL: "https://example.com/soon"},
+	}
+	for _, link := range links {
+		_, err := s.SaveURL(link)
+		require.NoError(t, err)
+	}
+
+	handler := New(slogdiscard.NewDiscardLogger(), s, nil, nil, s, ComingSoon{}, nil)
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", handler).Methods(http.MethodGet)
+	router.Handle("/{alias}/{path:.+}", handler).Methods(http.MethodGet)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	cases := map[string]string{
+		"/docs":                       "https://example.com/manual/?lang=en",
+		"/docs/api/v2":                "https://example.com/manual/api/v2?lang=en",
+		"/docs/api/v2?lang=de&page=3": "https://example.com/manual/api/v2?lang=en&page=3",
+		"/plain?utm_source=mail":      "https://example.com/plain",
+		"/once?x=1":                   "https://example.com/once",
+	}
+	for target, want := range cases {
+		rr := get(target)
+		require.Equal(t, http.StatusFound, rr.Code, target)
+		assert.Equal(t, want, rr.Header().Get("Location"), target)
+	}
+
+	assert.Equal(t, http.StatusNotFound, get("/plain/sub").Code, "plain links have no subpages")
+	assert.Equal(t, http.StatusFound, get("/launch").Code)
+	assert.Equal(t, http.StatusNotFound, get("/launch/sub").Code, "inactive links have no subpages either")
+
+	// the path check comes before the click is taken
+	_, err := s.SaveURL(storage.Link{Alias: "twice", URL: "https://example.com/twice", ClicksLeft: &one})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, get("/twice/sub").Code)
+	link, err := s.GetLink("twice")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, *link.ClicksLeft)
+}
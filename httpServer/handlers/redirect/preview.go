@@ -0,0 +1,142 @@
+package redirect
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/gorilla/mux"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"url_shortener/cmd/middleware"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// PreviewSuffix appended to an alias asks for its preview page instead of the redirect, like
+// /docs+. The preview query parameter set to 1 does the same.
+const PreviewSuffix = "+"
+
+// PreviewResponse describes where a link goes without going there. URL and Host are left out for
+// links that reveal their destination only when opened, Note tells why.
+type PreviewResponse struct {
+	resp.Response
+	Alias     string    `json:"alias"`
+	URL       string    `json:"url,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Note      string    `json:"note,omitempty"`
+	// Continue opens the link, through the redirect so clicks and passwords work as usual
+	Continue string `json:"continue"`
+}
+
+var previewPage = template.Must(template.New("preview").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body>
+<main>
+<h1>Where this link goes</h1>
+{{if .URL}}<p>This short link takes you to <strong>{{.Host}}</strong>:</p>
+<p><code>{{.URL}}</code></p>{{end}}
+{{if .Note}}<p>{{.Note}}</p>{{end}}
+<p>Created {{.CreatedAt.UTC.Format "2 January 2006 15:04 UTC"}}.</p>
+<p><a href="{{.Continue}}" role="button">Continue</a></p>
+</main>
+</body>
+</html>
+`))
+
+// NewPreview shows where an alias goes without redirecting or counting a click: an HTML page with
+// a continue button for browsers, json for other clients. Protected and click-limited links keep
+// their destination hidden, the preview would be a way around the password or the limit.
+func NewPreview(log *slog.Logger, links LinkGetter, hosts *Hosts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.redirect.NewPreview"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := strings.TrimSuffix(mux.Vars(r)["alias"], PreviewSuffix)
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+
+		domain, err := hosts.Domain(r)
+		if err != nil {
+			log.Error("failed to resolve custom domain", sl.Err(err))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, resp.Error("service unavailable"))
+			return
+		}
+		link, err := links.GetLink(storage.Key(domain, alias))
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("url not found", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("url not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get link", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		preview := PreviewResponse{
+			Response:  resp.OK(),
+			Alias:     link.Alias,
+			CreatedAt: link.CreatedAt,
+			Continue:  "/" + url.PathEscape(link.Alias),
+		}
+		status := http.StatusOK
+		target, err := link.Target(time.Now())
+		switch {
+		case err == nil:
+			preview.URL = target
+		case errors.Is(err, storage.ErrURLExpired), errors.Is(err, storage.ErrURLExhausted):
+			status = http.StatusGone
+			preview.Note = "This link has expired."
+		case errors.Is(err, storage.ErrURLEnded):
+			preview.Note = "This link is no longer active."
+		case errors.Is(err, storage.ErrURLNotStarted):
+			preview.Note = "This link is not active yet."
+		case errors.Is(err, storage.ErrURLProtected):
+			preview.Note = "This link is password protected, its destination is shown once it is unlocked."
+		case errors.Is(err, storage.ErrURLLimited):
+			preview.Note = "This link can be opened a limited number of times, its destination is shown when it is opened."
+		default:
+			log.Error("failed to resolve link", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+		preview.Host = storage.TargetHost(preview.URL)
+
+		log.Info("preview shown", slog.String("alias", alias))
+
+		// scheduled destinations and activation windows change what the page says
+		w.Header().Set("Cache-Control", "no-store")
+		if !wantsHTML(r) {
+			render.Status(r, status)
+			render.JSON(w, r, preview)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		if err := previewPage.Execute(w, preview); err != nil {
+			log.Error("failed to write preview page", sl.Err(err))
+		}
+	}
+}
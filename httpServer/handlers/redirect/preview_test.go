@@ -0,0 +1,101 @@
+package redirect
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreview(t *testing.T) {
+	earlier, later := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	one := int64(1)
+
+	s := memory.NewStorage()
+	links := []storage.Link{
+		{Alias: "docs", URL: "https://Example.com/docs?page=<1>"},
+		{Alias: "secret", URL: "https://example.com/secret", Password: "hash"},
+		{Alias: "once", URL: "https://example.com/once", ClicksLeft: &one},
+		{Alias: "launch", URL: "https://example.com/launch", NotBefore: &later},
+		{Alias: "old", URL: "https://example.com/old", ExpiresAt: &earlier},
+	}
+	for _, link := range links {
+		_, err := s.SaveURL(link)
+		require.NoError(t, err)
+	}
+
+	log := slogdiscard.NewDiscardLogger()
+	preview := NewPreview(log, s, nil)
+	router := mux2.NewRouter()
+	router.Handle("/{alias:[^/]+\\+}", preview).Methods(http.MethodGet)
+	router.Handle("/{alias}", preview).Queries("preview", "1").Methods(http.MethodGet)
+	router.Handle("/{alias}", New(log, s, nil, nil, s, ComingSoon{}, nil)).Methods(http.MethodGet)
+
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Accept", accept)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+	decode := func(rr *httptest.ResponseRecorder) PreviewResponse {
+		var body PreviewResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return body
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, target := range []string{"/docs+", "/docs?preview=1"} {
+			rr := get(target, "application/json")
+			require.Equal(t, http.StatusOK, rr.Code, target)
+			assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			body := decode(rr)
+			assert.Equal(t, "docs", body.Alias)
+			assert.Equal(t, "https://Example.com/docs?page=<1>", body.URL)
+			assert.Equal(t, "example.com", body.Host)
+			assert.Equal(t, "/docs", body.Continue)
+			assert.False(t, body.CreatedAt.IsZero())
+		}
+	})
+
+	t.Run("HTML", func(t *testing.T) {
+		rr := get("/docs+", "text/html,application/xhtml+xml")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		page := rr.Body.String()
+		assert.Contains(t, page, "<strong>example.com</strong>")
+		assert.Contains(t, page, "page=&lt;1&gt;", "the destination is escaped")
+		assert.Contains(t, page, `href="/docs"`)
+	})
+
+	t.Run("Hidden destinations", func(t *testing.T) {
+		for _, alias := range []string{"secret", "once", "launch"} {
+			rr := get("/"+alias+"+", "")
+			require.Equal(t, http.StatusOK, rr.Code, alias)
+			body := decode(rr)
+			assert.Empty(t, body.URL, alias)
+			assert.NotEmpty(t, body.Note, alias)
+		}
+		link, err := s.GetLink("once")
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, *link.ClicksLeft, "previews do not use up clicks")
+	})
+
+	t.Run("Expired and missing", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, get("/old+", "").Code)
+		assert.Equal(t, http.StatusNotFound, get("/missing+", "").Code)
+	})
+
+	t.Run("Redirect without marker", func(t *testing.T) {
+		rr := get("/docs?preview=0", "")
+		assert.Equal(t, http.StatusFound, rr.Code)
+	})
+}
@@ -0,0 +1,137 @@
+package redirect
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"html/template"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// PasswordHeader carries the password of a protected link for API clients, browsers post the form.
+const PasswordHeader = "X-Link-Password"
+
+var passwordForm = template.Must(template.New("password").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Protected link</title>
+</head>
+<body>
+<form method="post">
+<p>This link is password protected.</p>
+{{if .}}<p role="alert">{{.}}</p>{{end}}
+<input type="password" name="password" autocomplete="off" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// unlock opens a protected link with the password of the request. When it stays closed it writes
+// the response itself and returns false.
+func unlock(w http.ResponseWriter, r *http.Request, log *slog.Logger, gate *Gate, alias string) (storage.Link, bool) {
+	html := wantsHTML(r)
+	deny := func(status int, msg string) (storage.Link, bool) {
+		w.Header().Set("Cache-Control", "no-store")
+		if html {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			if err := passwordForm.Execute(w, msg); err != nil {
+				log.Error("failed to write password form", sl.Err(err))
+			}
+			return storage.Link{}, false
+		}
+		render.Status(r, status)
+		render.JSON(w, r, resp.Error(msg))
+		return storage.Link{}, false
+	}
+
+	if gate == nil {
+		log.Error("protected link without a gate", slog.String("alias", alias))
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("url not found"))
+		return storage.Link{}, false
+	}
+
+	password := readPassword(r)
+	if password == "" {
+		// a browser opening the link gets the form without an error
+		if html && r.Method == http.MethodGet {
+			return deny(http.StatusUnauthorized, "")
+		}
+		return deny(http.StatusUnauthorized, "password required")
+	}
+
+	client := gate.Client(r)
+	link, err := gate.Unlock(alias, client, password)
+	switch {
+	case err == nil:
+		return link, true
+	case errors.Is(err, errWrongPassword):
+		log.Info("wrong password", slog.String("alias", alias))
+		return deny(http.StatusUnauthorized, "wrong password")
+	case errors.Is(err, errLocked):
+		log.Info("protected link locked", slog.String("alias", alias))
+		seconds := math.Ceil(gate.LockedFor(alias, client).Seconds())
+		w.Header().Set("Retry-After", strconv.Itoa(int(max(seconds, 1))))
+		return deny(http.StatusTooManyRequests, "too many wrong passwords, try again later")
+	case errors.Is(err, storage.ErrURLNotFound):
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("url not found"))
+	case errors.Is(err, storage.ErrURLExpired):
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, resp.Error("url expired"))
+	case errors.Is(err, storage.ErrURLExhausted):
+		render.Status(r, http.StatusGone)
+		render.JSON(w, r, resp.Error("click limit reached"))
+	case errors.Is(err, storage.ErrURLNotStarted), errors.Is(err, storage.ErrURLEnded):
+		// the window opened or closed since the url was looked up, the next visit gets the full answer
+		render.Status(r, http.StatusNotFound)
+		render.JSON(w, r, resp.Error("url is not active"))
+	default:
+		log.Error("failed to unlock url", sl.Err(err))
+		render.Status(r, http.StatusInternalServerError)
+		render.JSON(w, r, resp.Error("internal error"))
+	}
+	return storage.Link{}, false
+}
+
+// readPassword takes the password from PasswordHeader, or from the password field of a posted
+// form or json body.
+func readPassword(r *http.Request) string {
+	if password := r.Header.Get(PasswordHeader); password != "" {
+		return password
+	}
+	if r.Method != http.MethodPost {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var body struct {
+			Password string `json:"password"`
+		}
+		if err := render.DecodeJSON(r.Body, &body); err != nil {
+			return ""
+		}
+		return body.Password
+	}
+	return r.PostFormValue("password")
+}
+
+// wantsHTML reports whether the request comes from a browser, which gets the password form.
+func wantsHTML(r *http.Request) bool {
+	if r.Method == http.MethodPost {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,175 @@
+package redirect
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	mux2 "github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProtectedRouter(t *testing.T) (*mux2.Router, *Gate) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "docs", URL: "https://example.com/doc", Creator: "owner", Password: "secret"})
+	require.NoError(t, err)
+
+	gate := NewGate(s, config.Protection{MaxAttempts: 3, Lockout: time.Minute})
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), s, nil, gate, s, ComingSoon{}, nil)).Methods(http.MethodGet, http.MethodPost)
+	return router, gate
+}
+
+func TestRedirectProtected(t *testing.T) {
+	router, _ := newProtectedRouter(t)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Browser gets the form", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		rr := serve(req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Contains(t, rr.Body.String(), `<form method="post">`)
+		assert.NotContains(t, rr.Body.String(), "example.com")
+	})
+
+	t.Run("API client without password", func(t *testing.T) {
+		rr := serve(httptest.NewRequest(http.MethodGet, "/docs", nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Contains(t, rr.Body.String(), "password required")
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+		req.Header.Set(PasswordHeader, "secret")
+		rr := serve(req)
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "https://example.com/doc", rr.Header().Get("Location"))
+	})
+
+	t.Run("Form post", func(t *testing.T) {
+		form := url.Values{"password": {"secret"}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/docs", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := serve(req)
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Equal(t, "https://example.com/doc", rr.Header().Get("Location"))
+	})
+
+	t.Run("JSON post", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/docs", strings.NewReader(`{"password": "secret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rr := serve(req)
+		assert.Equal(t, http.StatusFound, rr.Code)
+	})
+
+	t.Run("Wrong password in form", func(t *testing.T) {
+		form := url.Values{"password": {"guess"}}.Encode()
+		req := httptest.NewRequest(http.MethodPost, "/docs", strings.NewReader(form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := serve(req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.Contains(t, rr.Body.String(), "wrong password")
+	})
+}
+
+func TestRedirectProtectedLockout(t *testing.T) {
+	router, gate := newProtectedRouter(t)
+	now := time.Now()
+	gate.now = func() time.Time { return now }
+
+	try := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+		req.Header.Set(PasswordHeader, password)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusUnauthorized, try("guess").Code)
+	}
+	rr := try("secret")
+	require.Equal(t, http.StatusTooManyRequests, rr.Code, "locked links refuse even the right password")
+	require.Equal(t, "60", rr.Header().Get("Retry-After"))
+
+	now = now.Add(time.Minute)
+	require.Equal(t, http.StatusFound, try("secret").Code)
+
+	// a success starts the count over
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusUnauthorized, try("guess").Code)
+	}
+	require.Equal(t, http.StatusFound, try("secret").Code)
+	require.Equal(t, http.StatusUnauthorized, try("guess").Code)
+	require.Zero(t, gate.LockedFor("docs", "192.0.2.1"))
+
+	// other clients keep their own count, a stranger can't lock the owner out
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusUnauthorized, try("guess").Code)
+	}
+	require.Equal(t, http.StatusTooManyRequests, try("secret").Code)
+	req := httptest.NewRequest(http.MethodGet, "/docs", nil)
+	req.RemoteAddr = "198.51.100.7:4321"
+	req.Header.Set(PasswordHeader, "secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusFound, rr.Code)
+}
+
+func TestGateConcurrentGuesses(t *testing.T) {
+	_, gate := newProtectedRouter(t)
+
+	var wg sync.WaitGroup
+	var locked atomic.Int64
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := gate.Unlock("docs", "192.0.2.1", "guess"); errors.Is(err, errLocked) {
+				locked.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.EqualValues(t, 17, locked.Load(), "only MaxAttempts guesses get to the password check")
+	require.Positive(t, gate.LockedFor("docs", "192.0.2.1"))
+}
+
+func TestRedirectOneTime(t *testing.T) {
+	s := memory.NewStorage()
+	once := int64(1)
+	_, err := s.SaveURL(storage.Link{Alias: "secret", URL: "https://example.com/secret", ClicksLeft: &once, Password: "pw"})
+	require.NoError(t, err)
+
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), s, nil, NewGate(s, config.Protection{MaxAttempts: 3, Lockout: time.Minute}), s, ComingSoon{}, nil)).Methods(http.MethodGet)
+	open := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+		req.Header.Set(PasswordHeader, password)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	require.Equal(t, http.StatusUnauthorized, open("guess").Code, "wrong passwords don't use up the click")
+	rr := open("pw")
+	require.Equal(t, http.StatusFound, rr.Code)
+	require.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	require.Equal(t, http.StatusGone, open("pw").Code)
+}
@@ -2,10 +2,12 @@ package redirect
 
 import (
 	"errors"
+	"fmt"
 	"github.com/go-chi/render"
 	"github.com/gorilla/mux"
 	"log/slog"
 	"net/http"
+	"time"
 	"url_shortener/cmd/middleware"
 	resp "url_shortener/internal/lib/api/response"
 	"url_shortener/internal/lib/logger/sl"
@@ -15,9 +17,28 @@ import (
 //go:generate go run github.com/vektra/mockery/v2@v2.49.1 --name=URLGetter
 type URLGetter interface {
 	GetURL(alias string) (string, error)
+	Passthrough(alias string) (storage.Passthrough, error)
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+// ClickLimiter takes a click of a click-limited link, atomically so concurrent redirects can't
+// go over the limit.
+type ClickLimiter interface {
+	ConsumeClick(alias string) (string, error)
+}
+
+// ClickRecorder records a resolved redirect, it must not block on storage.
+type ClickRecorder interface {
+	Record(r *http.Request, alias string)
+}
+
+// New redirects to the target of the alias. Password protected links are opened through gate,
+// GET asks for the password and POST (or PasswordHeader on either) sends it. Click-limited links
+// redirect through clickLimiter and answer 410 once their clicks are used up. Links outside their
+// activation window redirect to their fallback url, or answer comingSoon before and 410 after it.
+// The alias is looked up on the custom domain hosts resolves the request host to, see storage.Key.
+// Links that forward them get the query string and the path after the alias, /{alias}/{path},
+// carried over to their target; the path of other links is not found.
+func New(log *slog.Logger, urlGetter URLGetter, clickRecorder ClickRecorder, gate *Gate, clickLimiter ClickLimiter, comingSoon ComingSoon, hosts *Hosts) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const info = "handlers.redirect.New"
 
@@ -33,19 +54,90 @@ func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
 			render.JSON(w, r, resp.Error("invalid request"))
 			return
 		}
+		domain, err := hosts.Domain(r)
+		if err != nil {
+			log.Error("failed to resolve custom domain", sl.Err(err))
+			render.Status(r, http.StatusServiceUnavailable)
+			render.JSON(w, r, resp.Error("service unavailable"))
+			return
+		}
+		// from here on alias is the key of the link, "host/alias" on a custom domain
+		alias = storage.Key(domain, alias)
 
 		resultURL, err := urlGetter.GetURL(alias)
+		var unlocked *storage.Link
+		if errors.Is(err, storage.ErrURLProtected) {
+			link, ok := unlock(w, r, log, gate, alias)
+			if !ok {
+				return
+			}
+			unlocked = &link
+			resultURL, err = link.Destination(time.Now()), nil
+			if link.Limited() {
+				err = storage.ErrURLLimited
+			}
+		}
+		limited := errors.Is(err, storage.ErrURLLimited)
+		// passthrough is only looked up for requests that have something to forward, and before a
+		// click is taken, a path the link does not forward must not use one up. Inactive links check
+		// the path too, a subpage they don't have is not found rather than coming soon.
+		suffix := params["path"]
+		active := err == nil || limited
+		closed := errors.Is(err, storage.ErrURLNotStarted) || errors.Is(err, storage.ErrURLEnded)
+		var passthrough storage.Passthrough
+		if (active && r.URL.RawQuery != "") || ((active || closed) && suffix != "") {
+			var lookupErr error
+			if unlocked != nil {
+				passthrough = unlocked.Passthrough
+			} else {
+				passthrough, lookupErr = urlGetter.Passthrough(alias)
+			}
+			switch {
+			case lookupErr != nil:
+				err, limited = lookupErr, false
+			case suffix != "" && !passthrough.Path:
+				err, limited = fmt.Errorf("%s: %s/%s, %w", info, alias, suffix, storage.ErrURLNotFound), false
+			}
+		}
+		if limited && clickLimiter != nil {
+			resultURL, err = clickLimiter.ConsumeClick(alias)
+		}
 		if errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("url not found", "alias", alias)
+			render.Status(r, http.StatusNotFound)
 			render.JSON(w, r, resp.Error("url not found"))
 			return
 		}
+		if errors.Is(err, storage.ErrURLNotStarted) || errors.Is(err, storage.ErrURLEnded) {
+			inactive(w, r, log, comingSoon, alias, resultURL, err)
+			return
+		}
+		if errors.Is(err, storage.ErrURLExpired) {
+			log.Info("url expired", "alias", alias)
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, resp.Error("url expired"))
+			return
+		}
+		if errors.Is(err, storage.ErrURLExhausted) {
+			log.Info("click limit reached", "alias", alias)
+			render.Status(r, http.StatusGone)
+			render.JSON(w, r, resp.Error("click limit reached"))
+			return
+		}
 		if err != nil {
 			log.Error("failed to get url", sl.Err(err))
 			render.JSON(w, r, resp.Error("internal error"))
 			return
 		}
+		resultURL = passthrough.Forward(resultURL, r.URL.Query(), suffix)
 		log.Info("url gotten", slog.String("url", resultURL))
+		if clickRecorder != nil {
+			clickRecorder.Record(r, alias)
+		}
+		if limited {
+			// every visit must come back here to be counted
+			w.Header().Set("Cache-Control", "no-store")
+		}
 		http.Redirect(w, r, resultURL, http.StatusFound)
 	}
 }
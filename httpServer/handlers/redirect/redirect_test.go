@@ -8,6 +8,7 @@ import (
 	"url_shortener/httpServer/handlers/redirect/mocks"
 	"url_shortener/internal/lib/api"
 	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,7 +40,7 @@ func TestSaveHandler(t *testing.T) {
 
 			router := mux2.NewRouter()
 
-			router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), urlGetterMock)).Methods(http.MethodGet)
+			router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), urlGetterMock, nil, nil, nil, ComingSoon{}, nil)).Methods(http.MethodGet)
 
 			ts := httptest.NewServer(router)
 			defer ts.Close()
@@ -52,3 +53,31 @@ func TestSaveHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRedirectExpired(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", "expired").
+		Return("", storage.ErrURLExpired).Once()
+
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), urlGetterMock, nil, nil, nil, ComingSoon{}, nil)).Methods(http.MethodGet)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/expired", nil))
+
+	assert.Equal(t, http.StatusGone, rr.Code)
+}
+
+func TestRedirectNotFound(t *testing.T) {
+	urlGetterMock := mocks.NewURLGetter(t)
+	urlGetterMock.On("GetURL", "deleted").
+		Return("", storage.ErrURLNotFound).Once()
+
+	router := mux2.NewRouter()
+	router.Handle("/{alias}", New(slogdiscard.NewDiscardLogger(), urlGetterMock, nil, nil, nil, ComingSoon{}, nil)).Methods(http.MethodGet)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/deleted", nil))
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,157 @@
+package alias
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/go-playground/validator"
+	"github.com/gorilla/mux"
+	"os"
+	"regexp"
+	"strings"
+	"url_shortener/httpServer/handlers/redirect"
+	"url_shortener/internal/config"
+)
+
+// Validation tags registered by Policy.Validator, in the order they should appear on a field
+const (
+	TagChars    = "alias_chars"
+	TagMin      = "alias_min"
+	TagMax      = "alias_max"
+	TagReserved = "alias_reserved"
+	TagBlocked  = "alias_blocked"
+)
+
+// Policy decides which aliases users may pick themselves. Aliases never contain "/", whatever the
+// pattern: it separates the custom domain from the alias in link keys, see storage.Key. Nor do they
+// end with redirect.PreviewSuffix, which asks for the preview of the alias without it.
+type Policy struct {
+	pattern   *regexp.Regexp
+	minLength int
+	maxLength int
+	reserved  map[string]struct{} // lower case
+	blocked   []string            // lower case, matched as substrings
+}
+
+// NewPolicy builds a policy from cfg, reading the blocklist file if one is configured.
+func NewPolicy(cfg config.AliasPolicy) (*Policy, error) {
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("alias pattern: %w", err)
+	}
+	if cfg.MinLength > cfg.MaxLength {
+		return nil, fmt.Errorf("alias min length %d is above max length %d", cfg.MinLength, cfg.MaxLength)
+	}
+	p := &Policy{
+		pattern:   pattern,
+		minLength: cfg.MinLength,
+		maxLength: cfg.MaxLength,
+		reserved:  make(map[string]struct{}),
+	}
+	p.Reserve(cfg.Reserved...)
+	if cfg.Blocklist != "" {
+		if p.blocked, err = readBlocklist(cfg.Blocklist); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// Reserve forbids words as aliases, regardless of case. It is not safe to call once handlers use the policy.
+func (p *Policy) Reserve(words ...string) {
+	for _, w := range words {
+		p.reserved[strings.ToLower(w)] = struct{}{}
+	}
+}
+
+// Reserved reports if alias is a reserved word.
+func (p *Policy) Reserved(alias string) bool {
+	_, ok := p.reserved[strings.ToLower(alias)]
+	return ok
+}
+
+// Blocked reports if alias contains a blocklisted word. Separators are ignored, so "b-a-d" matches "bad".
+func (p *Policy) Blocked(alias string) bool {
+	normalized := strings.NewReplacer("-", "", "_", "", ".", "").Replace(strings.ToLower(alias))
+	for _, w := range p.blocked {
+		if strings.Contains(normalized, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports if alias is neither reserved nor blocked. Generated aliases are checked with it,
+// they already have a valid shape.
+func (p *Policy) Allowed(alias string) bool {
+	return !p.Reserved(alias) && !p.Blocked(alias)
+}
+
+// Validator returns a validator that knows the alias_* tags.
+func (p *Policy) Validator() *validator.Validate {
+	v := validator.New()
+	rules := map[string]func(alias string) bool{
+		TagChars: func(alias string) bool {
+			return !strings.Contains(alias, "/") && !strings.HasSuffix(alias, redirect.PreviewSuffix) && p.pattern.MatchString(alias)
+		},
+		TagMin:      func(alias string) bool { return len(alias) >= p.minLength },
+		TagMax:      func(alias string) bool { return len(alias) <= p.maxLength },
+		TagReserved: func(alias string) bool { return !p.Reserved(alias) },
+		TagBlocked:  func(alias string) bool { return !p.Blocked(alias) },
+	}
+	for tag, rule := range rules {
+		// tags are constants and the functions non-nil, registration cannot fail
+		_ = v.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+			return rule(fl.Field().String())
+		})
+	}
+	return v
+}
+
+// RouteWords returns the fixed first path segments of the routes on router, e.g. "url" for
+// "/url/{alias}". An alias equal to one of them would be shadowed by the route or shadow it.
+func RouteWords(router *mux.Router) ([]string, error) {
+	seen := make(map[string]struct{})
+	var words []string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // routes without a path, like subrouters matched by host
+		}
+		first, _, _ := strings.Cut(strings.TrimPrefix(tpl, "/"), "/")
+		if first == "" || strings.Contains(first, "{") {
+			return nil
+		}
+		if _, ok := seen[first]; !ok {
+			seen[first] = struct{}{}
+			words = append(words, first)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk routes: %w", err)
+	}
+	return words, nil
+}
+
+// readBlocklist reads one word per line, skipping blank lines and # comments
+func readBlocklist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("alias blocklist: %w", err)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		w := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if w == "" || strings.HasPrefix(w, "#") {
+			continue
+		}
+		words = append(words, w)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("alias blocklist: %w", err)
+	}
+	return words, nil
+}
@@ -0,0 +1,80 @@
+package alias
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"url_shortener/internal/config"
+
+	"github.com/go-playground/validator"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	Alias string `validate:"omitempty,alias_chars,alias_min,alias_max,alias_reserved,alias_blocked"`
+}
+
+func TestPolicy(t *testing.T) {
+	blocklist := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklist, []byte("# words\nbad\n\n"), 0o600))
+
+	policy, err := NewPolicy(config.AliasPolicy{
+		Pattern:   "^[A-Za-z0-9_-]+$",
+		MinLength: 3,
+		MaxLength: 10,
+		Reserved:  []string{"admin"},
+		Blocklist: blocklist,
+	})
+	require.NoError(t, err)
+	policy.Reserve("login")
+
+	cases := map[string]string{
+		"my-link":     "",
+		"":            "",
+		"with space":  TagChars,
+		"a/b":         TagChars,
+		"ab":          TagMin,
+		"much-longer": TagMax,
+		"Login":       TagReserved,
+		"admin":       TagReserved,
+		"so-b-a-d":    TagBlocked,
+	}
+	v := policy.Validator()
+	for alias, wantTag := range cases {
+		err := v.Struct(request{Alias: alias})
+		if wantTag == "" {
+			require.NoError(t, err, alias)
+			continue
+		}
+		require.Error(t, err, alias)
+		require.Equal(t, wantTag, err.(validator.ValidationErrors)[0].ActualTag(), alias)
+	}
+
+	require.True(t, policy.Allowed("x7Kp2q"))
+	require.False(t, policy.Allowed("xbadx"))
+
+	// a pattern that allows anything still keeps "/" out, and "+" at the end, where it asks for a preview
+	loose, err := NewPolicy(config.AliasPolicy{Pattern: "^.+$", MinLength: 1, MaxLength: 10})
+	require.NoError(t, err)
+	require.NoError(t, loose.Validator().Struct(request{Alias: "a.b+c"}))
+	for _, alias := range []string{"a/b", "docs+"} {
+		err = loose.Validator().Struct(request{Alias: alias})
+		require.Error(t, err, alias)
+		require.Equal(t, TagChars, err.(validator.ValidationErrors)[0].ActualTag(), alias)
+	}
+}
+
+func TestRouteWords(t *testing.T) {
+	router := mux.NewRouter()
+	private := router.PathPrefix("/").Subrouter()
+	private.Handle("/url", http.NotFoundHandler())
+	private.Handle("/url/{alias}", http.NotFoundHandler())
+	router.Handle("/{alias}", http.NotFoundHandler())
+	router.Handle("/login", http.NotFoundHandler())
+
+	words, err := RouteWords(router)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"url", "login"}, words)
+}
@@ -0,0 +1,164 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	"url_shortener/httpServer/handlers/url/save"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+const (
+	ModeAtomic  = "atomic"   // all items are saved or none
+	ModePerItem = "per_item" // every item is saved on its own
+)
+
+type Request struct {
+	Mode  string         `json:"mode,omitempty"` // atomic if empty
+	Items []save.Request `json:"items"`
+}
+
+// ItemResult is the outcome of the item at the same position in the request, either an alias or an error.
+type ItemResult struct {
+	Alias     string     `json:"alias,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Existing  bool       `json:"existing,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Items []ItemResult `json:"items,omitempty"`
+}
+
+type BatchSaver interface {
+	save.URLSaver
+	SaveURLs(ctx context.Context, links []storage.Link) ([]string, error)
+}
+
+// New saves up to limit links in one request. Items are validated like in save.New, results come
+// back in the order of the items.
+func New(log *slog.Logger, saver BatchSaver, aliases save.AliasOptions, dedup bool, limit int) http.HandlerFunc {
+	batchSaver := NewSaver(saver, aliases)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.url.batch.New"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+		)
+
+		var req Request
+		err := render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+
+			return
+		}
+
+		if req.Mode == "" {
+			req.Mode = ModeAtomic
+		}
+		if req.Mode != ModeAtomic && req.Mode != ModePerItem {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("unknown mode %q, want %s or %s", req.Mode, ModeAtomic, ModePerItem)))
+
+			return
+		}
+		if len(req.Items) == 0 || len(req.Items) > limit {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("a batch has from 1 to %d items", limit)))
+
+			return
+		}
+
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not get user id from context, unauthorized"))
+
+			return
+		}
+
+		log.Info("batch decoded", slog.String("mode", req.Mode), slog.Int("items", len(req.Items)))
+
+		items := make([]Item, len(req.Items))
+		results := make([]ItemResult, len(req.Items))
+		failed := false
+		now := time.Now()
+		for i, itemReq := range req.Items {
+			results[i], items[i], err = batchSaver.Prepare(creator, itemReq, dedup, now)
+			if err != nil {
+				log.Error("failed to look up existing link", sl.Err(err))
+
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("failed to add urls"))
+
+				return
+			}
+			if results[i].Error != "" {
+				failed = true
+			}
+		}
+
+		if req.Mode == ModePerItem {
+			for i := range items {
+				if !items[i].Done() {
+					results[i] = batchSaver.SaveOne(log, items[i])
+				}
+			}
+			render.JSON(w, r, Response{Response: resp.OK(), Items: results})
+
+			return
+		}
+
+		if failed {
+			MarkNotSaved(results)
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, Response{Response: resp.Error("invalid items"), Items: results})
+
+			return
+		}
+		status, err := batchSaver.SaveAll(r.Context(), items, results)
+		if err != nil {
+			log.Error("failed to save batch", sl.Err(err))
+		}
+		if status != http.StatusOK {
+			MarkNotSaved(results)
+
+			render.Status(r, status)
+			render.JSON(w, r, Response{Response: resp.Error("failed to add urls"), Items: results})
+
+			return
+		}
+
+		log.Info("batch saved", slog.Int("items", len(items)))
+
+		render.JSON(w, r, Response{Response: resp.OK(), Items: results})
+	}
+}
@@ -0,0 +1,145 @@
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url_shortener/httpServer/handlers/url/alias"
+	"url_shortener/httpServer/handlers/url/random"
+	"url_shortener/httpServer/handlers/url/save"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newHandler(t *testing.T, s BatchSaver) http.HandlerFunc {
+	crypto, err := random.NewCrypto("", false)
+	require.NoError(t, err)
+	policy, err := alias.NewPolicy(config.AliasPolicy{Pattern: "^[A-Za-z0-9_-]+$", MinLength: 3, MaxLength: 64})
+	require.NoError(t, err)
+	policy.Reserve("url")
+	aliases := save.AliasOptions{
+		Policy:     policy,
+		Generators: map[string]save.AliasGenerator{random.GeneratorRandom: crypto},
+		Default:    random.GeneratorRandom,
+		Length:     6,
+		Attempts:   5,
+	}
+	return New(slogdiscard.NewDiscardLogger(), s, aliases, true, 3)
+}
+
+func serve(t *testing.T, h http.HandlerFunc, body string) (int, Response) {
+	req := httptest.NewRequest(http.MethodPost, "/url/batch", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	return rr.Code, resp
+}
+
+func TestBatch(t *testing.T) {
+	cases := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantItems  []ItemResult // only errors, Existing and custom aliases are compared
+		wantLinks  int
+	}{
+		{
+			name:       "Atomic",
+			body:       `{"items": [{"url": "https://a.com"}, {"url": "https://b.com", "alias": "bee"}, {"url": "https://google.com"}]}`,
+			wantStatus: http.StatusOK,
+			wantItems:  []ItemResult{{}, {Alias: "bee"}, {Alias: "google", Existing: true}},
+			wantLinks:  3,
+		},
+		{
+			name:       "Atomic invalid item",
+			body:       `{"items": [{"url": "https://a.com"}, {"url": "https://b.com", "alias": "url"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantItems:  []ItemResult{{Error: errNotSaved}, {Error: "field Alias is a reserved word"}},
+			wantLinks:  1,
+		},
+		{
+			name:       "Atomic taken alias",
+			body:       `{"items": [{"url": "https://a.com"}, {"url": "https://b.com", "alias": "google"}]}`,
+			wantStatus: http.StatusConflict,
+			wantItems:  []ItemResult{{Error: errNotSaved}, {Error: "alias already exists"}},
+			wantLinks:  1,
+		},
+		{
+			name:       "Per item",
+			body:       `{"mode": "per_item", "items": [{"url": "https://a.com"}, {"url": "https://b.com", "alias": "google"}, {"url": "bad"}]}`,
+			wantStatus: http.StatusOK,
+			wantItems:  []ItemResult{{}, {Error: "alias already exists"}, {Error: "field URL is not a valid URL"}},
+			wantLinks:  2,
+		},
+		{
+			name:       "Too many items",
+			body:       `{"items": [{"url": "https://a.com"}, {"url": "https://b.com"}, {"url": "https://c.com"}, {"url": "https://d.com"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantLinks:  1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := memory.NewStorage()
+			_, err := s.SaveURL(storage.Link{Alias: "google", URL: "https://google.com", Creator: "owner"})
+			require.NoError(t, err)
+
+			status, resp := serve(t, newHandler(t, s), tc.body)
+
+			require.Equal(t, tc.wantStatus, status)
+			require.Len(t, resp.Items, len(tc.wantItems))
+			for i, want := range tc.wantItems {
+				got := resp.Items[i]
+				require.Equal(t, want.Error, got.Error, i)
+				require.Equal(t, want.Existing, got.Existing, i)
+				if want.Error == "" {
+					require.NotEmpty(t, got.Alias, i)
+				}
+				if want.Alias != "" {
+					require.Equal(t, want.Alias, got.Alias, i)
+				}
+			}
+
+			links, err := s.ListURLs(context.Background(), storage.ListQuery{Creator: "owner", Limit: 10})
+			require.NoError(t, err)
+			require.Len(t, links, tc.wantLinks)
+		})
+	}
+}
+
+// takenOnce reports the first generated alias of a batch as taken
+type takenOnce struct {
+	*memory.Storage
+	calls int
+}
+
+func (s *takenOnce) SaveURLs(ctx context.Context, links []storage.Link) ([]string, error) {
+	s.calls++
+	if s.calls == 1 {
+		return nil, &storage.ItemError{Index: 0, Err: storage.ErrURLExists}
+	}
+	return s.Storage.SaveURLs(ctx, links)
+}
+
+func TestBatchRetriesGeneratedAlias(t *testing.T) {
+	s := &takenOnce{Storage: memory.NewStorage()}
+
+	status, resp := serve(t, newHandler(t, s), `{"items": [{"url": "https://a.com"}, {"url": "https://b.com", "alias": "bee"}]}`)
+
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, 2, s.calls)
+	require.Equal(t, "bee", resp.Items[1].Alias)
+	_, err := s.GetLink(resp.Items[0].Alias)
+	require.NoError(t, err)
+}
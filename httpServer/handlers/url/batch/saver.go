@@ -0,0 +1,199 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-playground/validator"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/httpServer/handlers"
+	"url_shortener/httpServer/handlers/url/save"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// Errors of single items, in ItemResult.Error
+const (
+	ErrAliasExists        = "alias already exists"
+	ErrCollectionNotFound = "collection not found"
+	ErrDomainNotFound     = "domain not found"
+	ErrDomainNotVerified  = "domain not verified"
+	errNotSaved           = "not saved, another item failed"
+)
+
+// Saver saves many links at once the way save.New saves one. It backs POST /url/batch and imports.
+type Saver struct {
+	store    BatchSaver
+	aliases  save.AliasOptions
+	validate *validator.Validate
+}
+
+func NewSaver(store BatchSaver, aliases save.AliasOptions) *Saver {
+	return &Saver{store: store, aliases: aliases, validate: aliases.Policy.Validator()}
+}
+
+// Item is a request item on its way to storage.
+type Item struct {
+	link      storage.Link
+	generator save.AliasGenerator // nil for aliases picked by the user
+	attempt   int                 // of the generated alias
+	done      bool                // failed or answered with an existing link
+}
+
+// Done reports if the item needs no saving, its result is final.
+func (it Item) Done() bool {
+	return it.done
+}
+
+// Prepare validates an item and turns it into a link. Invalid items and items answered with an
+// existing link come back done, with their result filled in.
+func (s *Saver) Prepare(creator string, req save.Request, dedup bool, now time.Time) (ItemResult, Item, error) {
+	fail := func(msg string) (ItemResult, Item, error) {
+		return ItemResult{Error: msg}, Item{done: true}, nil
+	}
+
+	if err := s.validate.Struct(req); err != nil {
+		return fail(resp.ErrorValidator(err.(validator.ValidationErrors)).Error)
+	}
+	generator, err := s.aliases.Generator(req.Generator)
+	if err != nil {
+		return fail(err.Error())
+	}
+	expiresAt, err := handlers.ResolveExpiration(req.ExpiresAt, req.TTL, now)
+	if err != nil {
+		return fail(err.Error())
+	}
+
+	if req.WantsDedup(dedup) {
+		existing, err := s.store.LinkByTarget(creator, req.URL)
+		if err == nil {
+			return ItemResult{Alias: existing.Alias, ExpiresAt: existing.ExpiresAt, Existing: true}, Item{done: true}, nil
+		}
+		if !errors.Is(err, storage.ErrURLNotFound) {
+			return ItemResult{}, Item{}, err
+		}
+	}
+
+	link, err := req.Link(creator, expiresAt)
+	if err != nil {
+		return fail(err.Error())
+	}
+	it := Item{link: link}
+	if req.Alias == "" {
+		it.generator = generator
+	}
+	return ItemResult{}, it, nil
+}
+
+// SaveOne saves a single item on its own.
+func (s *Saver) SaveOne(log *slog.Logger, it Item) ItemResult {
+	var err error
+	link := it.link
+	if it.generator == nil {
+		_, err = s.store.SaveURL(link)
+	} else {
+		link.Alias, _, err = save.SaveWithGeneratedAlias(s.store, it.generator, s.aliases, link)
+	}
+	if errors.Is(err, storage.ErrURLExists) && it.generator == nil {
+		return ItemResult{Error: ErrAliasExists}
+	}
+	if errors.Is(err, storage.ErrCollectionNotFound) {
+		return ItemResult{Error: ErrCollectionNotFound}
+	}
+	if errors.Is(err, storage.ErrDomainNotFound) {
+		return ItemResult{Error: ErrDomainNotFound}
+	}
+	if errors.Is(err, storage.ErrDomainNotVerified) {
+		return ItemResult{Error: ErrDomainNotVerified}
+	}
+	if err != nil {
+		log.Error("failed to add url", sl.Err(err))
+		return ItemResult{Error: "failed to add url"}
+	}
+	return ItemResult{Alias: link.Alias, Domain: link.Domain, ExpiresAt: link.ExpiresAt}
+}
+
+// SaveAll saves the items in one transaction and returns the http status of the outcome. A generated
+// alias that is taken is generated again and the transaction retried, a taken alias picked by the
+// user fails the batch with 409.
+func (s *Saver) SaveAll(ctx context.Context, items []Item, results []ItemResult) (int, error) {
+	aliases := s.aliases
+	var pending []int // positions of the items that need saving
+	for i := range items {
+		if items[i].done {
+			continue
+		}
+		pending = append(pending, i)
+		if items[i].generator != nil {
+			alias, err := aliases.GenerateAlias(items[i].generator, 0)
+			if err != nil {
+				results[i].Error = "failed to add url"
+				return http.StatusInternalServerError, err
+			}
+			items[i].link.Alias = alias
+		}
+	}
+	if len(pending) == 0 {
+		return http.StatusOK, nil
+	}
+
+	for {
+		links := make([]storage.Link, len(pending))
+		for j, i := range pending {
+			links[j] = items[i].link
+		}
+
+		_, err := s.store.SaveURLs(ctx, links)
+		if err == nil {
+			for _, i := range pending {
+				results[i] = ItemResult{Alias: items[i].link.Alias, Domain: items[i].link.Domain, ExpiresAt: items[i].link.ExpiresAt}
+			}
+			return http.StatusOK, nil
+		}
+
+		var itemErr *storage.ItemError
+		if errors.As(err, &itemErr) && errors.Is(err, storage.ErrCollectionNotFound) {
+			results[pending[itemErr.Index]].Error = ErrCollectionNotFound
+			return http.StatusBadRequest, err
+		}
+		if errors.As(err, &itemErr) && errors.Is(err, storage.ErrDomainNotFound) {
+			results[pending[itemErr.Index]].Error = ErrDomainNotFound
+			return http.StatusBadRequest, err
+		}
+		if errors.As(err, &itemErr) && errors.Is(err, storage.ErrDomainNotVerified) {
+			results[pending[itemErr.Index]].Error = ErrDomainNotVerified
+			return http.StatusBadRequest, err
+		}
+		if !errors.As(err, &itemErr) || !errors.Is(err, storage.ErrURLExists) {
+			return http.StatusInternalServerError, err
+		}
+		i := pending[itemErr.Index]
+		it := &items[i]
+		if it.generator == nil {
+			results[i].Error = ErrAliasExists
+			return http.StatusConflict, err
+		}
+		it.attempt++
+		if it.attempt >= aliases.Attempts {
+			results[i].Error = "failed to add url"
+			return http.StatusInternalServerError, fmt.Errorf("no free alias after %d attempts: %w", aliases.Attempts, err)
+		}
+		if it.link.Alias, err = aliases.GenerateAlias(it.generator, it.attempt); err != nil {
+			results[i].Error = "failed to add url"
+			return http.StatusInternalServerError, err
+		}
+	}
+}
+
+// MarkNotSaved explains the items of a failed atomic batch that were fine on their own, items
+// answered with an existing link keep it.
+func MarkNotSaved(results []ItemResult) {
+	for i := range results {
+		if results[i].Error == "" && !results[i].Existing {
+			results[i] = ItemResult{Error: errNotSaved}
+		}
+	}
+}
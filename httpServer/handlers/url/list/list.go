@@ -0,0 +1,200 @@
+package list
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/google/uuid"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+const (
+	defaultLimit = 20
+	maxLimit     = 100
+)
+
+type Link struct {
+	Alias      string     `json:"alias"`
+	Domain     string     `json:"domain,omitempty"` // custom domain of the alias, see handlers.LinkKey
+	URL        string     `json:"url"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Collection string     `json:"collection,omitempty"`
+	Clicks     int64      `json:"clicks"`
+	// ForwardQuery and ForwardPath tell what redirects carry over to the target
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	ForwardPath  bool `json:"forward_path,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Links      []Link `json:"links"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type URLLister interface {
+	ListURLs(ctx context.Context, q storage.ListQuery) ([]storage.LinkSummary, error)
+}
+
+// New lists links of the caller. Query parameters:
+//   - sort: createdAt (default) or clicks, order: desc (default) or asc
+//   - domain: substring of the target host
+//   - tag: links carrying the tag, repeat it for links carrying all of them
+//   - collection: id of the collection the links belong to
+//   - created_from, created_to: RFC 3339 creation time range, to is exclusive
+//   - limit: page size, cursor: next_cursor of the previous page
+func New(log *slog.Logger, urlLister URLLister) http.HandlerFunc {
+	return handler(log, urlLister, "handlers.url.list.New", false)
+}
+
+// NewTrash lists deleted links of the caller that can still be restored, with the query parameters of New.
+func NewTrash(log *slog.Logger, urlLister URLLister) http.HandlerFunc {
+	return handler(log, urlLister, "handlers.url.list.NewTrash", true)
+}
+
+func handler(log *slog.Logger, urlLister URLLister, info string, deleted bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+
+		q, err := parseQuery(r)
+		if err != nil {
+			log.Info("invalid list query", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+		q.Creator = creator
+		q.Deleted = deleted
+
+		// one extra link tells whether there is a next page
+		limit := q.Limit
+		q.Limit++
+		summaries, err := urlLister.ListURLs(r.Context(), q)
+		if err != nil {
+			log.Error("failed to list urls", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		response := Response{Response: resp.OK(), Links: make([]Link, 0, limit)}
+		if len(summaries) > limit {
+			summaries = summaries[:limit]
+			response.NextCursor = encodeCursor(summaries[limit-1].Cursor())
+		}
+		for _, s := range summaries {
+			response.Links = append(response.Links, Link{
+				Alias:        s.Alias,
+				Domain:       s.Domain,
+				URL:          s.URL,
+				CreatedAt:    s.CreatedAt,
+				ExpiresAt:    s.ExpiresAt,
+				DeletedAt:    s.DeletedAt,
+				Tags:         s.Tags,
+				Collection:   s.CollectionID,
+				Clicks:       s.Clicks,
+				ForwardQuery: s.Passthrough.Query,
+				ForwardPath:  s.Passthrough.Path,
+			})
+		}
+
+		render.JSON(w, r, response)
+	}
+}
+
+func parseQuery(r *http.Request) (storage.ListQuery, error) {
+	query := r.URL.Query()
+	q := storage.ListQuery{
+		SortBy:     storage.SortCreatedAt,
+		Desc:       true,
+		Domain:     query.Get("domain"),
+		Tags:       storage.NormalizeTags(query["tag"]),
+		Collection: query.Get("collection"),
+		Limit:      defaultLimit,
+	}
+
+	switch sortBy := query.Get("sort"); sortBy {
+	case "", storage.SortCreatedAt:
+	case storage.SortClicks:
+		q.SortBy = storage.SortClicks
+	default:
+		return q, fmt.Errorf("field sort must be one of createdAt, clicks")
+	}
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		q.Desc = false
+	default:
+		return q, fmt.Errorf("field order must be one of asc, desc")
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return q, fmt.Errorf("field limit must be between 1 and %d", maxLimit)
+		}
+		q.Limit = limit
+	}
+
+	for name, dst := range map[string]**time.Time{"created_from": &q.CreatedFrom, "created_to": &q.CreatedTo} {
+		if raw := query.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return q, fmt.Errorf("field %s is not a valid RFC 3339 time", name)
+			}
+			*dst = &t
+		}
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil {
+			return q, fmt.Errorf("invalid cursor")
+		}
+		q.After = &cursor
+	}
+	return q, nil
+}
+
+func encodeCursor(c storage.ListCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (storage.ListCursor, error) {
+	var c storage.ListCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	// link ids are uuids, postgres compares the cursor id as one
+	if _, err := uuid.Parse(c.ID); err != nil {
+		return c, fmt.Errorf("cursor id: %w", err)
+	}
+	return c, nil
+}
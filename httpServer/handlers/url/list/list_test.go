@@ -0,0 +1,125 @@
+package list
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListHandler(t *testing.T) {
+	s := memory.NewStorage()
+	for _, link := range []storage.Link{
+		{Alias: "a", URL: "https://google.com/search", Creator: "owner"},
+		{Alias: "b", URL: "https://docs.google.com", Creator: "owner"},
+		{Alias: "c", URL: "https://bing.com", Creator: "owner"},
+		{Alias: "d", URL: "https://google.com", Creator: "someone else"},
+	} {
+		_, err := s.SaveURL(link)
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.SaveClicks(context.Background(), []storage.Click{{Alias: "b"}, {Alias: "b"}, {Alias: "c"}}))
+
+	list := func(t *testing.T, query string) (int, Response) {
+		req := httptest.NewRequest(http.MethodGet, "/url"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+		rr := httptest.NewRecorder()
+		New(slogdiscard.NewDiscardLogger(), s).ServeHTTP(rr, req)
+
+		var resp Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		return rr.Code, resp
+	}
+
+	t.Run("Sort by clicks with pagination", func(t *testing.T) {
+		code, resp := list(t, "?sort=clicks&limit=2")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Links, 2)
+		require.Equal(t, "b", resp.Links[0].Alias)
+		require.EqualValues(t, 2, resp.Links[0].Clicks)
+		require.Equal(t, "c", resp.Links[1].Alias)
+		require.NotEmpty(t, resp.NextCursor)
+
+		code, resp = list(t, "?sort=clicks&limit=2&cursor="+resp.NextCursor)
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, resp.Links, 1)
+		require.Equal(t, "a", resp.Links[0].Alias)
+		require.Empty(t, resp.NextCursor)
+	})
+
+	t.Run("Domain filter", func(t *testing.T) {
+		_, resp := list(t, "?domain=GOOGLE&sort=clicks&order=asc")
+		require.Len(t, resp.Links, 2)
+		require.Equal(t, "a", resp.Links[0].Alias)
+		require.Equal(t, "b", resp.Links[1].Alias)
+	})
+
+	t.Run("Trash", func(t *testing.T) {
+		_, err := s.DeleteURL("c", "owner")
+		require.NoError(t, err)
+		defer s.RestoreURL("c", "owner")
+
+		_, resp := list(t, "")
+		require.Len(t, resp.Links, 2)
+
+		req := httptest.NewRequest(http.MethodGet, "/url/trash", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+		rr := httptest.NewRecorder()
+		NewTrash(slogdiscard.NewDiscardLogger(), s).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		require.Len(t, resp.Links, 1)
+		require.Equal(t, "c", resp.Links[0].Alias)
+		require.NotNil(t, resp.Links[0].DeletedAt)
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		code, _ := list(t, "?sort=alias")
+		require.Equal(t, http.StatusBadRequest, code)
+		// the id of a cursor must be a link id, a uuid
+		for _, cursor := range []string{"garbage", encodeCursor(storage.ListCursor{}), encodeCursor(storage.ListCursor{ID: "1' OR '1'='1"})} {
+			code, resp := list(t, "?cursor="+cursor)
+			require.Equal(t, http.StatusBadRequest, code, cursor)
+			require.Equal(t, "invalid cursor", resp.Error, cursor)
+		}
+	})
+}
+
+func TestListTags(t *testing.T) {
+	s := memory.NewStorage()
+	c, err := s.CreateCollection(storage.Collection{Owner: "owner", Name: "spring"})
+	require.NoError(t, err)
+	for _, link := range []storage.Link{
+		{Alias: "a", URL: "https://google.com", Creator: "owner", Tags: []string{"sale", "email"}, CollectionID: c.ID},
+		{Alias: "b", URL: "https://bing.com", Creator: "owner", Tags: []string{"sale"}},
+		{Alias: "c", URL: "https://duckduckgo.com", Creator: "owner"},
+	} {
+		_, err := s.SaveURL(link)
+		require.NoError(t, err)
+	}
+
+	list := func(query string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/url"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+		rr := httptest.NewRecorder()
+		New(slogdiscard.NewDiscardLogger(), s).ServeHTTP(rr, req)
+
+		var resp Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		var aliases []string
+		for _, l := range resp.Links {
+			aliases = append(aliases, l.Alias)
+		}
+		return aliases
+	}
+
+	require.ElementsMatch(t, []string{"a", "b"}, list("?tag=SALE"))
+	require.Equal(t, []string{"a"}, list("?tag=sale&tag=email"))
+	require.Equal(t, []string{"a"}, list("?collection="+c.ID))
+}
@@ -1,24 +1,85 @@
 package random
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
 )
 
 const charset = "abcdefghijklmnopqrstuvwxyz" +
 	"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-var seededRand *rand.Rand = rand.New(
-	rand.NewSource(time.Now().UnixNano()))
+// Lookalikes are characters that are easy to misread for one another in print
+const Lookalikes = "0Oo1lIi"
 
+// Names of the generators, used in config and in save requests
+const (
+	GeneratorRandom   = "random"
+	GeneratorSequence = "sequence"
+	GeneratorWords    = "words"
+)
+
+// StringWithCharset returns length characters picked uniformly from charset with crypto/rand.
+// It panics if the system random source fails.
 func StringWithCharset(length int, charset string) string {
-	b := make([]byte, length)
-	for i := range b {
-		b[i] = charset[seededRand.Intn(len(charset))]
+	s, err := stringWithCharset(length, charset)
+	if err != nil {
+		panic(err)
 	}
-	return string(b)
+	return s
 }
 
 func RandomString(length int) string {
 	return StringWithCharset(length, charset)
 }
+
+func stringWithCharset(length int, charset string) (string, error) {
+	max := big.NewInt(int64(len(charset)))
+	b := make([]byte, length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("read random: %w", err)
+		}
+		b[i] = charset[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// Crypto generates aliases of random characters from an alphabet.
+type Crypto struct {
+	alphabet string
+}
+
+// NewCrypto returns a generator over alphabet, all letters and digits when it is empty.
+// With noLookalikes the characters in Lookalikes are left out.
+func NewCrypto(alphabet string, noLookalikes bool) (*Crypto, error) {
+	if alphabet == "" {
+		alphabet = charset
+	}
+	if noLookalikes {
+		alphabet = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(Lookalikes, r) {
+				return -1
+			}
+			return r
+		}, alphabet)
+	}
+	if len(alphabet) < 2 {
+		return nil, fmt.Errorf("alias alphabet %q is too small", alphabet)
+	}
+	for _, r := range alphabet {
+		if r > 127 {
+			return nil, fmt.Errorf("alias alphabet %q is not ascii", alphabet)
+		}
+		if r == '/' {
+			return nil, fmt.Errorf("alias alphabet %q contains /, it separates custom domains from aliases", alphabet)
+		}
+	}
+	return &Crypto{alphabet: alphabet}, nil
+}
+
+func (c *Crypto) Generate(length int) (string, error) {
+	return stringWithCharset(length, c.alphabet)
+}
@@ -37,3 +37,66 @@ func TestRandomString(t *testing.T) {
 	})
 
 }
+
+func TestCryptoNoLookalikes(t *testing.T) {
+	gen, err := NewCrypto("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		alias, err := gen.Generate(12)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(alias) != 12 {
+			t.Errorf("wanted 12 characters, got %q", alias)
+		}
+		if strings.ContainsAny(alias, Lookalikes) {
+			t.Errorf("alias %q has look-alike characters", alias)
+		}
+	}
+	if _, err := NewCrypto("0O", true); err == nil {
+		t.Error("wanted an error for an alphabet made of look-alikes only")
+	}
+	if _, err := NewCrypto("abc/", false); err == nil {
+		t.Error("wanted an error for an alphabet with a slash")
+	}
+}
+
+type counter int64
+
+func (c *counter) NextAliasID() (int64, error) {
+	*c++
+	return int64(*c), nil
+}
+
+func TestSequence(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 61: "z", 62: "10", 3843: "zz"} {
+		if got := Base62(n); got != want {
+			t.Errorf("Base62(%d): wanted %s, got %s", n, want, got)
+		}
+	}
+
+	c := counter(61)
+	gen := NewSequence(&c)
+	for _, want := range []string{"00010", "00011"} {
+		alias, err := gen.Generate(5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if alias != want {
+			t.Errorf("wanted %s, got %s", want, alias)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	alias, err := NewWords().Generate(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(alias, "-")
+	if len(parts) != 3 || len(parts[2]) != 2 {
+		t.Errorf("wanted word-word-NN, got %q", alias)
+	}
+}
@@ -0,0 +1,51 @@
+package random
+
+import (
+	"fmt"
+	"strings"
+)
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// Sequencer hands out increasing ids, every call a new one
+type Sequencer interface {
+	NextAliasID() (int64, error)
+}
+
+// Sequence generates aliases by base62 encoding a monotonic database sequence. The aliases are
+// short and never collide with each other, but they are easy to enumerate.
+type Sequence struct {
+	seq Sequencer
+}
+
+func NewSequence(seq Sequencer) *Sequence {
+	return &Sequence{seq: seq}
+}
+
+// Generate encodes the next sequence value, left padded with zeros to length.
+func (s *Sequence) Generate(length int) (string, error) {
+	id, err := s.seq.NextAliasID()
+	if err != nil {
+		return "", fmt.Errorf("next alias id: %w", err)
+	}
+	alias := Base62(id)
+	if len(alias) < length {
+		alias = strings.Repeat(base62[:1], length-len(alias)) + alias
+	}
+	return alias, nil
+}
+
+// Base62 encodes a non-negative n with digits, upper and lower case letters.
+func Base62(n int64) string {
+	if n == 0 {
+		return base62[:1]
+	}
+	var b []byte
+	for ; n > 0; n /= 62 {
+		b = append(b, base62[n%62])
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
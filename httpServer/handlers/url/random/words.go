@@ -0,0 +1,60 @@
+package random
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// words are short, common and hard to misspell, so aliases built from them can be read out loud
+var words = []string{
+	"able", "acid", "aged", "also", "area", "army", "away", "baby", "back", "bald", "band", "bank",
+	"base", "bath", "bear", "beat", "bell", "belt", "best", "bird", "blue", "boat", "body", "bold",
+	"bone", "book", "boot", "born", "boss", "both", "bowl", "bulk", "burn", "busy", "cake", "calm",
+	"camp", "card", "care", "cart", "case", "cash", "cast", "cave", "chef", "city", "clay", "clip",
+	"club", "coal", "coat", "code", "coin", "cold", "cook", "cool", "copy", "core", "corn", "cost",
+	"crew", "crop", "cube", "cure", "dark", "dawn", "deal", "deep", "deer", "desk", "dial", "dice",
+	"dish", "dock", "door", "dove", "draw", "drum", "duck", "dune", "dust", "duty", "earn", "east",
+	"easy", "echo", "edge", "epic", "even", "exit", "face", "fact", "fair", "fall", "farm", "fast",
+	"fern", "film", "fine", "fire", "firm", "fish", "flag", "flat", "foam", "fold", "folk", "food",
+	"foot", "fork", "form", "fort", "free", "frog", "fuel", "full", "fund", "gate", "gear", "gift",
+	"glad", "glow", "goal", "gold", "golf", "good", "gray", "grid", "grow", "gulf", "hail", "half",
+	"hall", "hand", "hard", "harp", "hawk", "heat", "herb", "hero", "high", "hike", "hill", "hint",
+	"home", "hood", "hook", "hope", "horn", "host", "huge", "hunt", "idea", "inch", "iron", "isle",
+	"jade", "jazz", "jolly", "keen", "kelp", "kind", "king", "kite", "knot", "lake", "lamp", "land",
+	"lane", "last", "lava", "leaf", "lean", "lily", "lime", "line", "lion", "list", "loft", "long",
+	"loud", "luck", "lush", "made", "mail", "main", "malt", "mane", "many", "maze", "meal", "mild",
+	"milk", "mint", "mist", "mode", "moon", "moss", "much", "navy", "neat", "nest", "next", "nice",
+	"nine", "note", "oak", "oath", "open", "oval", "palm", "park", "path", "peak", "pear", "pine",
+	"pink", "plan", "plum", "pond", "pool", "port", "pure", "quiz", "rain", "ramp", "rare", "reed",
+	"rich", "ring", "road", "rock", "roof", "root", "rope", "rose", "ruby", "safe", "sage", "sail",
+	"salt", "sand", "seal", "seed", "ship", "shoe", "silk", "sky", "slow", "snow", "soft", "song",
+	"soup", "spin", "star", "stem", "sun", "surf", "swan", "tall", "team", "tent", "tide", "tile",
+	"time", "tiny", "toad", "tree", "true", "tune", "twin", "vast", "vine", "wave", "west", "wide",
+	"wild", "wind", "wise", "wolf", "wood", "wool", "yard", "yarn", "zest", "zinc",
+}
+
+// Words generates readable aliases like "calm-hawk-42". The keyspace is len(words)^2 * 100,
+// small enough that collisions are expected once a few hundred thousand links exist.
+type Words struct{}
+
+func NewWords() *Words {
+	return &Words{}
+}
+
+// Generate ignores length, word aliases always have the word-word-NN shape.
+func (Words) Generate(length int) (string, error) {
+	first, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+	second, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	if err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(100))
+	if err != nil {
+		return "", fmt.Errorf("read random: %w", err)
+	}
+	return fmt.Sprintf("%s-%s-%02d", words[first.Int64()], words[second.Int64()], n.Int64()), nil
+}
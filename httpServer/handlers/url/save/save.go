@@ -2,15 +2,16 @@ package save
 
 import (
 	"errors"
+	"fmt"
 	"github.com/go-chi/render"
 	"github.com/go-playground/validator"
 	"io"
 	"log/slog"
 	"net/http"
+	"time"
 	"url_shortener/cmd/middleware"
 	"url_shortener/httpServer/handlers"
-	"url_shortener/httpServer/handlers/url/random"
-	"url_shortener/internal/config"
+	"url_shortener/httpServer/handlers/url/alias"
 	resp "url_shortener/internal/lib/api/response"
 	"url_shortener/internal/lib/logger/sl"
 	"url_shortener/internal/storage"
@@ -18,20 +19,134 @@ import (
 
 type Request struct {
 	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	Alias string `json:"alias,omitempty" validate:"omitempty,alias_chars,alias_min,alias_max,alias_reserved,alias_blocked"`
+	// ExpiresAt and TTL are mutually exclusive, TTL is a duration like "72h" counted from now
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	// Generator picks the alias generator when Alias is empty, the deployment default if unset
+	Generator string `json:"generator,omitempty"`
+	// Dedup returns the caller's existing link to the same destination instead of adding another one,
+	// the deployment default if unset. It does not apply to requests with an alias.
+	Dedup *bool `json:"dedup,omitempty"`
+	// Password protects the link, the redirect asks for it. bcrypt takes at most maxPasswordBytes,
+	// the max tag counts characters and Link checks the bytes.
+	Password string `json:"password,omitempty" validate:"omitempty,min=4,max=72"`
+	// MaxClicks stops the link after that many redirects, 1 makes a one-time link. 0 means no limit.
+	MaxClicks int64 `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+	// NotBefore and NotAfter bound when the link redirects. Outside of them it goes to FallbackURL,
+	// without one it answers "coming soon" before and 410 after the window.
+	NotBefore   *time.Time `json:"not_before,omitempty"`
+	NotAfter    *time.Time `json:"not_after,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty" validate:"omitempty,url"`
+	// Schedule changes the destination at the given moments, URL is the destination before the first one.
+	Schedule []ScheduledURL `json:"schedule,omitempty" validate:"omitempty,max=20,dive"`
+	// Tags label the link, they are stored lower case. Collection is the id of a collection of the caller.
+	Tags       []string `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=32"`
+	Collection string   `json:"collection,omitempty"`
+	// Domain is a custom domain of the caller the alias lives on, the default domain if unset.
+	Domain string `json:"domain,omitempty"`
+	// ForwardQuery and ForwardPath make redirects carry the query string and the path after the
+	// alias over to the destination, see storage.Passthrough for parameters both of them have.
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	ForwardPath  bool `json:"forward_path,omitempty"`
+}
+
+// ScheduledURL switches the destination of the link to URL from At on.
+type ScheduledURL struct {
+	At  time.Time `json:"at" validate:"required"`
+	URL string    `json:"url" validate:"required,url"`
+}
+
+// maxPasswordBytes is the longest password bcrypt hashes.
+const maxPasswordBytes = 72
+
+// Link turns the request into the link to save for creator.
+func (req Request) Link(creator string, expiresAt *time.Time) (storage.Link, error) {
+	if len(req.Password) > maxPasswordBytes {
+		return storage.Link{}, fmt.Errorf("field Password must be at most %d bytes", maxPasswordBytes)
+	}
+	if req.NotBefore != nil && req.NotAfter != nil && !req.NotAfter.After(*req.NotBefore) {
+		return storage.Link{}, errors.New("not_after must be later than not_before")
+	}
+	var schedule storage.Schedule
+	for _, change := range req.Schedule {
+		schedule = append(schedule, storage.ScheduledURL{At: change.At.UTC(), URL: change.URL})
+	}
+	return storage.Link{
+		Alias:        req.Alias,
+		URL:          req.URL,
+		Creator:      creator,
+		ExpiresAt:    expiresAt,
+		Password:     req.Password,
+		ClicksLeft:   req.ClicksLeft(),
+		NotBefore:    req.NotBefore,
+		NotAfter:     req.NotAfter,
+		FallbackURL:  req.FallbackURL,
+		Schedule:     schedule.Sorted(),
+		Tags:         req.Tags,
+		CollectionID: req.Collection,
+		Domain:       storage.NormalizeHost(req.Domain),
+		Passthrough:  storage.Passthrough{Query: req.ForwardQuery, Path: req.ForwardPath},
+	}, nil
+}
+
+// ClicksLeft is the click count of a new link, nil without MaxClicks.
+func (req Request) ClicksLeft() *int64 {
+	if req.MaxClicks == 0 {
+		return nil
+	}
+	left := req.MaxClicks
+	return &left
+}
+
+// LogValue keeps the password out of the logs.
+func (req Request) LogValue() slog.Value {
+	type plain Request
+	p := plain(req)
+	if p.Password != "" {
+		p.Password = "[redacted]"
+	}
+	return slog.AnyValue(p)
 }
 
 type Response struct {
 	resp.Response
-	Alias string `json:"alias,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Existing  bool       `json:"existing,omitempty"` // the alias belongs to an earlier link, see Request.Dedup
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.28.2 --name=URLSaver
 type URLSaver interface {
-	SaveURL(urlToSave, alias, creator string) (string, error)
+	SaveURL(link storage.Link) (string, error)
+	LinkByTarget(creator, target string) (storage.Link, error)
+}
+
+// AliasGenerator makes aliases for links saved without one. length is a hint, generators with a
+// fixed shape may ignore it.
+type AliasGenerator interface {
+	Generate(length int) (string, error)
+}
+
+// AliasOptions configures aliases, Policy applies to all of them, the rest to the ones the user did not pick.
+type AliasOptions struct {
+	Policy     *alias.Policy
+	Generators map[string]AliasGenerator // selectable by name in Request.Generator
+	Default    string                    // name of the generator used when the request has none
+	Length     int
+	Attempts   int // generated aliases tried before giving up
 }
 
-func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+// aliasGrowEvery is how many collisions of generated aliases make the next one a character longer
+const aliasGrowEvery = 2
+
+// New saves a link. Aliases the user picked must pass aliases.Policy. The others come from one of
+// aliases.Generators and are retried up to aliases.Attempts times on collision or when the policy
+// refuses them. dedup is the default of Request.Dedup.
+func New(log *slog.Logger, urlSaver URLSaver, aliases AliasOptions, dedup bool) http.HandlerFunc {
+	validate := aliases.Policy.Validator()
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		const info = "handlers.url.save.New"
 
@@ -62,7 +177,7 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 
 		log.Info("request body decoded", slog.Any("request", req))
 
-		if err := validator.New().Struct(req); err != nil {
+		if err := validate.Struct(req); err != nil {
 			validateErr := err.(validator.ValidationErrors)
 
 			log.Error("invalid request", sl.Err(err))
@@ -72,10 +187,25 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			return
 		}
 
-		alias := req.Alias
-		if alias == "" {
-			alias = random.RandomString(config.MustLoad().AliasLength)
+		generator, err := aliases.Generator(req.Generator)
+		if err != nil {
+			log.Error("unknown alias generator", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+
+		expiresAt, err := handlers.ResolveExpiration(req.ExpiresAt, req.TTL, time.Now())
+		if err != nil {
+			log.Error("invalid expiration", sl.Err(err))
+
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
 		}
+
 		creator, err := handlers.GetUserIDFromContext(r.Context())
 		if err != nil {
 			log.Error("could not get user id from context, unauthorized", sl.Err(err))
@@ -83,11 +213,73 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 			return
 		}
 
-		id, err := urlSaver.SaveURL(req.URL, alias, creator)
-		if errors.Is(err, storage.ErrURLExists) {
-			log.Info("url already exists", slog.String("url", req.URL))
+		if req.WantsDedup(dedup) {
+			existing, err := urlSaver.LinkByTarget(creator, req.URL)
+			if err == nil {
+				log.Info("url already shortened", slog.String("alias", existing.Alias))
+
+				render.JSON(w, r, Response{
+					Response:  resp.OK(),
+					Alias:     existing.Alias,
+					ExpiresAt: existing.ExpiresAt,
+					Existing:  true,
+				})
+
+				return
+			}
+			if !errors.Is(err, storage.ErrURLNotFound) {
+				log.Error("failed to look up existing link", sl.Err(err))
 
-			render.JSON(w, r, resp.Error("url already exists"))
+				render.JSON(w, r, resp.Error("failed to add url"))
+
+				return
+			}
+		}
+
+		link, err := req.Link(creator, expiresAt)
+		if err != nil {
+			log.Error("invalid link", sl.Err(err))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+
+			return
+		}
+		var id string
+		if link.Alias != "" {
+			id, err = urlSaver.SaveURL(link)
+		} else {
+			link.Alias, id, err = SaveWithGeneratedAlias(urlSaver, generator, aliases, link)
+		}
+		if errors.Is(err, storage.ErrCollectionNotFound) {
+			log.Info("collection not found", slog.String("collection", req.Collection))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("collection not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrDomainNotFound) {
+			log.Info("domain not found", slog.String("domain", req.Domain))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("domain not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrDomainNotVerified) {
+			log.Info("domain not verified", slog.String("domain", req.Domain))
+
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("domain not verified"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrURLExists) && req.Alias != "" {
+			log.Info("alias already exists", slog.String("alias", req.Alias))
+
+			render.Status(r, http.StatusConflict)
+			render.JSON(w, r, resp.Error("alias already exists"))
 
 			return
 		}
@@ -98,17 +290,80 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 
 			return
 		}
-
 		log.Info("url added", slog.String("id", id))
 
-		responseOK(w, r, alias)
+		responseOK(w, r, link.Alias, link.Domain, expiresAt)
+	}
+}
+
+// WantsDedup reports if the request should get an existing link to its destination, def is the
+// deployment default. Requests with an alias, a password, a click limit, an expiry, an activation
+// window, a schedule, tags, a collection, a custom domain or passthrough always get a link of their own.
+func (req Request) WantsDedup(def bool) bool {
+	if req.Alias != "" || req.Password != "" || req.MaxClicks != 0 || req.ExpiresAt != nil || req.TTL != "" ||
+		req.NotBefore != nil || req.NotAfter != nil || len(req.Schedule) > 0 ||
+		len(req.Tags) > 0 || req.Collection != "" || req.Domain != "" || req.ForwardQuery || req.ForwardPath {
+		return false
+	}
+	if req.Dedup != nil {
+		return *req.Dedup
+	}
+	return def
+}
+
+// Generator returns the generator called name, the default one if name is empty.
+func (o AliasOptions) Generator(name string) (AliasGenerator, error) {
+	if name == "" {
+		name = o.Default
+	}
+	generator, ok := o.Generators[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown alias generator %q", name)
+	}
+	return generator, nil
+}
+
+// GenerateAlias returns an alias from generator for the attempt-th try to save a link, counting
+// from 0. Every aliasGrowEvery attempts the alias gets a character longer, so a crowded keyspace
+// does not keep failing at the same length. Aliases refused by the policy are generated again.
+func (o AliasOptions) GenerateAlias(generator AliasGenerator, attempt int) (string, error) {
+	for i := 0; i < o.Attempts; i++ {
+		alias, err := generator.Generate(o.Length + attempt/aliasGrowEvery)
+		if err != nil {
+			return "", fmt.Errorf("generate alias: %w", err)
+		}
+		if o.Policy.Allowed(alias) {
+			return alias, nil
+		}
+	}
+	return "", fmt.Errorf("no generated alias passed the policy after %d tries", o.Attempts)
+}
+
+// SaveWithGeneratedAlias saves link under a generated alias, generating another one when it is
+// taken. It returns the alias the link was saved under.
+func SaveWithGeneratedAlias(urlSaver URLSaver, generator AliasGenerator, aliases AliasOptions, link storage.Link) (string, string, error) {
+	var err error
+	for attempt := 0; attempt < aliases.Attempts; attempt++ {
+		link.Alias, err = aliases.GenerateAlias(generator, attempt)
+		if err != nil {
+			return "", "", err
+		}
+
+		var id string
+		id, err = urlSaver.SaveURL(link)
+		if !errors.Is(err, storage.ErrURLExists) {
+			return link.Alias, id, err
+		}
 	}
+	return "", "", fmt.Errorf("no free alias after %d attempts: %w", aliases.Attempts, err)
 }
 
-func responseOK(w http.ResponseWriter, r *http.Request, alias string) {
+func responseOK(w http.ResponseWriter, r *http.Request, alias, domain string, expiresAt *time.Time) {
 	render.JSON(w, r, Response{
-		Response: resp.OK(),
-		Alias:    alias,
+		Response:  resp.OK(),
+		Alias:     alias,
+		Domain:    domain,
+		ExpiresAt: expiresAt,
 	})
 }
 
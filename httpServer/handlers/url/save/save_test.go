@@ -0,0 +1,237 @@
+package save
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url_shortener/httpServer/handlers/url/alias"
+	"url_shortener/httpServer/handlers/url/random"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+// collidingSaver reports the first collisions saves as taken aliases
+type collidingSaver struct {
+	collisions int
+	aliases    []string
+}
+
+func (s *collidingSaver) SaveURL(link storage.Link) (string, error) {
+	s.aliases = append(s.aliases, link.Alias)
+	if len(s.aliases) <= s.collisions {
+		return "", storage.ErrURLExists
+	}
+	return "id", nil
+}
+
+func (s *collidingSaver) LinkByTarget(_, target string) (storage.Link, error) {
+	return storage.Link{}, storage.ErrURLNotFound
+}
+
+func aliasOptions(t *testing.T, attempts int) AliasOptions {
+	crypto, err := random.NewCrypto("", false)
+	require.NoError(t, err)
+	policy, err := alias.NewPolicy(config.AliasPolicy{Pattern: "^[A-Za-z0-9_-]+$", MinLength: 3, MaxLength: 64})
+	require.NoError(t, err)
+	policy.Reserve("url", "login")
+	return AliasOptions{
+		Policy: policy,
+		Generators: map[string]AliasGenerator{
+			random.GeneratorRandom: crypto,
+			random.GeneratorWords:  random.NewWords(),
+		},
+		Default:  random.GeneratorRandom,
+		Length:   6,
+		Attempts: attempts,
+	}
+}
+
+func serve(h http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSaveTakenAlias(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "google", URL: "https://google.com", Creator: "owner"})
+	require.NoError(t, err)
+
+	rr := serve(New(slogdiscard.NewDiscardLogger(), s, aliasOptions(t, 5), false), `{"url": "https://bing.com", "alias": "google"}`)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Contains(t, rr.Body.String(), "alias already exists")
+}
+
+func TestSaveInvalidAlias(t *testing.T) {
+	cases := map[string]string{
+		`{"url": "https://bing.com", "alias": "url"}`:     "field Alias is a reserved word",
+		`{"url": "https://bing.com", "alias": "a b/c"}`:   "field Alias has characters that are not allowed",
+		`{"url": "https://bing.com", "alias": "go"}`:      "field Alias is too short",
+		`{"url": "https://bing.com", "alias": "my-link"}`: `"alias":"my-link"`,
+	}
+	for body, want := range cases {
+		rr := serve(New(slogdiscard.NewDiscardLogger(), memory.NewStorage(), aliasOptions(t, 5), false), body)
+		require.Contains(t, rr.Body.String(), want)
+	}
+}
+
+func TestSaveRetriesGeneratedAlias(t *testing.T) {
+	saver := &collidingSaver{collisions: 3}
+
+	rr := serve(New(slogdiscard.NewDiscardLogger(), saver, aliasOptions(t, 5), false), `{"url": "https://bing.com"}`)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, saver.aliases, 4)
+	for i, alias := range saver.aliases {
+		require.Len(t, alias, 6+i/aliasGrowEvery)
+	}
+
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, saver.aliases[3], resp.Alias)
+}
+
+func TestSaveGivesUpOnCrowdedKeyspace(t *testing.T) {
+	saver := &collidingSaver{collisions: 10}
+
+	rr := serve(New(slogdiscard.NewDiscardLogger(), saver, aliasOptions(t, 3), false), `{"url": "https://bing.com"}`)
+
+	require.Len(t, saver.aliases, 3)
+	require.Contains(t, rr.Body.String(), "failed to add url")
+}
+
+func TestSaveRequestGenerator(t *testing.T) {
+	s := memory.NewStorage()
+	h := New(slogdiscard.NewDiscardLogger(), s, aliasOptions(t, 5), false)
+
+	rr := serve(h, `{"url": "https://bing.com", "generator": "words"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Regexp(t, `^[a-z]+-[a-z]+-[0-9]{2}$`, resp.Alias)
+
+	rr = serve(h, `{"url": "https://bing.com", "generator": "sequence"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "unknown alias generator")
+}
+
+func TestSaveDedup(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "bing", URL: "https://Bing.com:443/?b=2&a=1", Creator: "owner"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		name      string
+		dedup     bool
+		body      string
+		wantAlias string
+	}{
+		{name: "Config default", dedup: true, body: `{"url": "https://bing.com/?a=1&b=2"}`, wantAlias: "bing"},
+		{name: "Request opt in", dedup: false, body: `{"url": "https://bing.com/?a=1&b=2", "dedup": true}`, wantAlias: "bing"},
+		{name: "Request opt out", dedup: true, body: `{"url": "https://bing.com/?a=1&b=2", "dedup": false}`},
+		{name: "Other destination", dedup: true, body: `{"url": "https://bing.com/?a=1"}`},
+		{name: "Custom alias", dedup: true, body: `{"url": "https://bing.com/?a=1&b=2", "alias": "bing2"}`, wantAlias: "bing2"},
+		{name: "Expires at", dedup: true, body: `{"url": "https://bing.com/?a=1&b=2", "expires_at": "2099-01-01T00:00:00Z"}`},
+		{name: "TTL", dedup: true, body: `{"url": "https://bing.com/?a=1&b=2", "ttl": "72h"}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := serve(New(slogdiscard.NewDiscardLogger(), s, aliasOptions(t, 5), tc.dedup), tc.body)
+			require.Equal(t, http.StatusOK, rr.Code)
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.NotEmpty(t, resp.Alias)
+			if tc.wantAlias == "bing" {
+				require.Equal(t, "bing", resp.Alias)
+				require.True(t, resp.Existing)
+				return
+			}
+			require.NotEqual(t, "bing", resp.Alias)
+			require.False(t, resp.Existing)
+			if tc.wantAlias != "" {
+				require.Equal(t, tc.wantAlias, resp.Alias)
+			}
+		})
+	}
+}
+
+func TestSavePassword(t *testing.T) {
+	s := memory.NewStorage()
+	h := New(slogdiscard.NewDiscardLogger(), s, aliasOptions(t, 5), true)
+
+	rr := serve(h, `{"url": "https://bing.com", "alias": "docs", "password": "abc"}`)
+	require.Contains(t, rr.Body.String(), "field Password is too short")
+
+	// 42 characters, but 84 bytes: more than bcrypt takes
+	rr = serve(h, `{"url": "https://bing.com", "alias": "docs", "password": "`+strings.Repeat("пароль", 7)+`"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "at most 72 bytes")
+
+	rr = serve(h, `{"url": "https://bing.com", "alias": "docs", "password": "secret"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	_, err := s.GetURL("docs")
+	require.ErrorIs(t, err, storage.ErrURLProtected)
+
+	// a protected link is never handed out by deduplication, nor deduplicated itself
+	rr = serve(h, `{"url": "https://bing.com", "password": "other"}`)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.False(t, resp.Existing)
+	require.NotEqual(t, "docs", resp.Alias)
+}
+
+func TestSaveActivation(t *testing.T) {
+	s := memory.NewStorage()
+	h := New(slogdiscard.NewDiscardLogger(), s, aliasOptions(t, 5), false)
+
+	rr := serve(h, `{"url": "https://bing.com", "alias": "launch",
+		"not_before": "2030-01-02T00:00:00Z", "not_after": "2030-01-01T00:00:00Z"}`)
+	require.Contains(t, rr.Body.String(), "not_after must be later than not_before")
+
+	rr = serve(h, `{"url": "https://bing.com", "alias": "launch", "not_before": "2030-01-01T00:00:00Z",
+		"fallback_url": "https://bing.com/soon",
+		"schedule": [{"at": "2030-03-01T00:00:00Z", "url": "https://bing.com/c"}, {"at": "2030-02-01T00:00:00Z", "url": "https://bing.com/b"}]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	url, err := s.GetURL("launch")
+	require.ErrorIs(t, err, storage.ErrURLNotStarted)
+	require.Equal(t, "https://bing.com/soon", url)
+	link, err := s.GetLink("launch")
+	require.NoError(t, err)
+	require.Equal(t, "https://bing.com/b", link.Schedule[0].URL, "schedule must be sorted")
+}
+
+func TestSavePassthrough(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "bing", URL: "https://bing.com/docs/", Creator: "owner"})
+	require.NoError(t, err)
+	h := New(slogdiscard.NewDiscardLogger(), s, aliasOptions(t, 5), true)
+
+	// a forwarding link is a link of its own, not the plain one to the same destination
+	rr := serve(h, `{"url": "https://bing.com/docs/", "forward_query": true, "forward_path": true}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.False(t, resp.Existing)
+
+	p, err := s.Passthrough(resp.Alias)
+	require.NoError(t, err)
+	require.Equal(t, storage.Passthrough{Query: true, Path: true}, p)
+}
+
+func TestRequestLogValue(t *testing.T) {
+	req := Request{URL: "https://bing.com", Password: "secret"}
+	require.NotContains(t, req.LogValue().String(), "secret")
+}
@@ -0,0 +1,156 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"github.com/gorilla/mux"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	"url_shortener/internal/clicks"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+const (
+	defaultRange = 30 * 24 * time.Hour
+	maxBuckets   = 1000
+	topValues    = 10
+)
+
+type Response struct {
+	resp.Response
+	Alias          string                `json:"alias"`
+	Domain         string                `json:"domain,omitempty"`
+	From           time.Time             `json:"from"`
+	To             time.Time             `json:"to"`
+	Bucket         string                `json:"bucket"`
+	TotalClicks    int64                 `json:"total_clicks"`
+	UniqueVisitors int64                 `json:"unique_visitors"`
+	TopReferrers   []storage.ValueCount  `json:"top_referrers"`
+	TopUserAgents  []storage.ValueCount  `json:"top_user_agents"`
+	Series         []storage.ClickBucket `json:"series"`
+}
+
+type StatsGetter interface {
+	GetLink(alias string) (storage.Link, error)
+	ClickStats(ctx context.Context, alias string, from, to time.Time, top int) (storage.ClickStats, error)
+}
+
+// New serves click statistics of a link to its creator. Query parameters: from and to (RFC 3339,
+// defaults to the last 30 days), bucket (hour, day or week, defaults to day) and host, the custom
+// domain of the alias.
+func New(log *slog.Logger, statsGetter StatsGetter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.url.stats.New"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := mux.Vars(r)["alias"]
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+
+		from, to, bucket, err := ParseRange(r, time.Now())
+		if err != nil {
+			log.Info("invalid stats range", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		key := handlers.LinkKey(r, alias)
+		link, err := statsGetter.GetLink(key)
+		if errors.Is(err, storage.ErrURLNotFound) || (err == nil && link.Creator != creator) {
+			// links of other users are reported as missing, the same way DeleteURL filters on creator
+			log.Info("alias not found", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("alias not found"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to get link", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		stats, err := statsGetter.ClickStats(r.Context(), key, from, to, topValues)
+		if err != nil {
+			log.Error("failed to get click stats", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		render.JSON(w, r, Response{
+			Response:       resp.OK(),
+			Alias:          alias,
+			Domain:         link.Domain,
+			From:           from,
+			To:             to,
+			Bucket:         bucket,
+			TotalClicks:    stats.Total,
+			UniqueVisitors: stats.UniqueVisitors,
+			TopReferrers:   stats.TopReferrers,
+			TopUserAgents:  stats.TopUserAgents,
+			Series:         clicks.Series(stats.Hourly, from, to, bucket),
+		})
+	}
+}
+
+// ParseRange reads from, to and bucket query parameters and aligns the range to whole buckets.
+func ParseRange(r *http.Request, now time.Time) (time.Time, time.Time, string, error) {
+	query := r.URL.Query()
+
+	bucket := query.Get("bucket")
+	if bucket == "" {
+		bucket = clicks.BucketDay
+	}
+
+	to := now
+	if raw := query.Get("to"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, "", fmt.Errorf("field to is not a valid RFC 3339 time")
+		}
+		to = parsed
+	}
+	from := to.Add(-defaultRange)
+	if raw := query.Get("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, time.Time{}, "", fmt.Errorf("field from is not a valid RFC 3339 time")
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("field from must be before to")
+	}
+
+	from, to, buckets, err := clicks.AlignRange(from, to, bucket)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("field bucket must be one of hour, day, week")
+	}
+	if buckets > maxBuckets {
+		return time.Time{}, time.Time{}, "", fmt.Errorf("range spans %d buckets, at most %d are allowed", buckets, maxBuckets)
+	}
+	return from, to, bucket, nil
+}
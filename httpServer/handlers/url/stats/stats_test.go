@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	s := memory.NewStorage()
+	_, err := s.SaveURL(storage.Link{Alias: "google", URL: "https://google.com", Creator: "owner"})
+	require.NoError(t, err)
+
+	clickedAt := time.Date(2024, 1, 3, 10, 15, 0, 0, time.UTC)
+	require.NoError(t, s.SaveClicks(context.Background(), []storage.Click{
+		{Alias: "google", ClickedAt: clickedAt, Referrer: "https://bing.com", UserAgent: "curl", IP: "10.0.0.0"},
+		{Alias: "google", ClickedAt: clickedAt.Add(time.Minute), Referrer: "https://bing.com", UserAgent: "curl", IP: "10.0.0.0"},
+		{Alias: "google", ClickedAt: clickedAt.Add(26 * time.Hour), UserAgent: "firefox", IP: "10.0.1.0"},
+	}))
+
+	cases := []struct {
+		name       string
+		user       string
+		query      string
+		wantStatus int
+	}{
+		{name: "Owner", user: "owner", query: "?from=2024-01-01T00:00:00Z&to=2024-01-08T00:00:00Z", wantStatus: http.StatusOK},
+		{name: "Not owner", user: "someone else", wantStatus: http.StatusNotFound},
+		{name: "Bad bucket", user: "owner", query: "?bucket=month", wantStatus: http.StatusBadRequest},
+		{name: "Too many buckets", user: "owner", query: "?from=2000-01-01T00:00:00Z&bucket=hour", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.Handle("/url/{alias}/stats", New(slogdiscard.NewDiscardLogger(), s)).Methods(http.MethodGet)
+
+			req := httptest.NewRequest(http.MethodGet, "/url/google/stats"+tc.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), "user_id", tc.user))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+
+			var resp Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.EqualValues(t, 3, resp.TotalClicks)
+			require.EqualValues(t, 2, resp.UniqueVisitors)
+			require.Equal(t, "day", resp.Bucket)
+			require.Len(t, resp.Series, 7)
+			require.EqualValues(t, 2, resp.Series[2].Clicks)
+			require.EqualValues(t, 1, resp.Series[3].Clicks)
+			require.Equal(t, storage.ValueCount{Value: "https://bing.com", Clicks: 2}, resp.TopReferrers[0])
+			require.Equal(t, "curl", resp.TopUserAgents[0].Value)
+		})
+	}
+}
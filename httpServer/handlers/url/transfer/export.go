@@ -0,0 +1,192 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// exportPage is how many links are read from storage at a time
+const exportPage = 500
+
+type URLLister interface {
+	ListURLs(ctx context.Context, q storage.ListQuery) ([]storage.LinkSummary, error)
+}
+
+// NewExport streams all links of the caller, oldest first, as csv, json or ndjson. Expired links
+// waiting for the sweep are left out, an import would refuse them. Protected links are marked, see
+// Record.
+func NewExport(log *slog.Logger, lister URLLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.url.transfer.NewExport"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not get user id from context, unauthorized"))
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = FormatJSON
+		}
+		contentType, ok := contentTypes[format]
+		if !ok {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("unknown format %q, want csv, json or ndjson", format)))
+			return
+		}
+
+		// the first page is read before anything is written, so a broken storage still gets a proper error
+		q := storage.ListQuery{Creator: creator, SortBy: storage.SortCreatedAt, Limit: exportPage}
+		page, err := lister.ListURLs(r.Context(), q)
+		if err != nil {
+			log.Error("failed to list urls", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+		enc := newEncoder(w, http.NewResponseController(w), format)
+
+		exported, expired := 0, 0
+		now := time.Now()
+		for {
+			for _, link := range page {
+				if link.Expired(now) {
+					expired++
+					continue
+				}
+				exported++
+				if err := enc.write(recordOf(link)); err != nil {
+					log.Error("failed to write export", sl.Err(err))
+					return
+				}
+			}
+			if err := enc.flush(); err != nil {
+				log.Error("failed to write export", sl.Err(err))
+				return
+			}
+			if len(page) < q.Limit {
+				break
+			}
+			cursor := page[len(page)-1].Cursor()
+			q.After = &cursor
+			if page, err = lister.ListURLs(r.Context(), q); err != nil {
+				// the status is sent already, a truncated file is all we can do
+				log.Error("failed to list urls", sl.Err(err))
+				return
+			}
+		}
+		if err := enc.close(); err != nil {
+			log.Error("failed to write export", sl.Err(err))
+			return
+		}
+
+		log.Info("links exported", slog.String("format", format), slog.Int("links", exported), slog.Int("expired", expired))
+	}
+}
+
+// encoder writes records one at a time, flushed to the client page by page so large exports stream
+type encoder struct {
+	w       io.Writer
+	rc      *http.ResponseController
+	format  string
+	csv     *csv.Writer
+	written int
+}
+
+func newEncoder(w io.Writer, rc *http.ResponseController, format string) *encoder {
+	e := &encoder{w: w, rc: rc, format: format}
+	if format == FormatCSV {
+		e.csv = csv.NewWriter(w)
+	}
+	return e
+}
+
+func (e *encoder) write(rec Record) error {
+	defer func() { e.written++ }()
+
+	switch e.format {
+	case FormatCSV:
+		if e.written == 0 {
+			if err := e.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		return e.csv.Write(rec.csvRow())
+	case FormatNDJSON:
+		return json.NewEncoder(e.w).Encode(rec)
+	default:
+		sep := ",\n"
+		if e.written == 0 {
+			sep = "[\n"
+		}
+		if _, err := io.WriteString(e.w, sep); err != nil {
+			return err
+		}
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		_, err = e.w.Write(b)
+		return err
+	}
+}
+
+// flush sends what was written so far to the client
+func (e *encoder) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := e.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// close finishes the file, an export without links is still a valid file
+func (e *encoder) close() error {
+	switch e.format {
+	case FormatCSV:
+		if e.written == 0 {
+			if err := e.csv.Write(csvHeader); err != nil {
+				return err
+			}
+		}
+		e.csv.Flush()
+		return e.csv.Error()
+	case FormatNDJSON:
+		return nil
+	default:
+		end := "\n]\n"
+		if e.written == 0 {
+			end = "[]\n"
+		}
+		_, err := io.WriteString(e.w, end)
+		return err
+	}
+}
@@ -0,0 +1,174 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/render"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	"url_shortener/httpServer/handlers/url/batch"
+	"url_shortener/httpServer/handlers/url/save"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+)
+
+const (
+	OnConflictFail = "fail" // import all rows in one transaction, any problem imports nothing
+	OnConflictSkip = "skip" // import row by row, rows whose alias is taken are skipped
+)
+
+// errNoPassword fails rows of protected links that come without a password, exports leave it out
+const errNoPassword = "protected link needs a password"
+
+// RowResult is the outcome of one row, Row counts data rows from 1.
+type RowResult struct {
+	Row     int    `json:"row"`
+	Alias   string `json:"alias,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ImportResponse struct {
+	resp.Response
+	Imported int         `json:"imported"`
+	Skipped  int         `json:"skipped"`
+	Failed   int         `json:"failed"`
+	Rows     []RowResult `json:"rows,omitempty"`
+}
+
+// NewImport saves up to limit links from a csv, json or ndjson file in the body, in the format
+// export writes. The format comes from the format parameter or the Content-Type, conflicts are
+// handled as the on_conflict parameter says, OnConflictFail by default.
+func NewImport(log *slog.Logger, saver *batch.Saver, limit int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.url.transfer.NewImport"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not get user id from context, unauthorized"))
+			return
+		}
+
+		onConflict := r.URL.Query().Get("on_conflict")
+		if onConflict == "" {
+			onConflict = OnConflictFail
+		}
+		if onConflict != OnConflictFail && onConflict != OnConflictSkip {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("unknown on_conflict %q, want fail or skip", onConflict)))
+			return
+		}
+
+		rows, err := readRecords(r.Body, importFormat(r), limit)
+		if errors.Is(err, errTooManyRows) {
+			render.Status(r, http.StatusRequestEntityTooLarge)
+			render.JSON(w, r, resp.Error(fmt.Sprintf("at most %d rows in one import", limit)))
+			return
+		}
+		if err != nil {
+			log.Error("failed to read import", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		items := make([]batch.Item, len(rows))
+		results := make([]batch.ItemResult, len(rows))
+		invalid := false
+		now := time.Now()
+		for i, row := range rows {
+			if row.err != nil {
+				results[i] = batch.ItemResult{Error: row.err.Error()}
+				invalid = true
+				continue
+			}
+			if row.Protected && row.Password == "" {
+				results[i] = batch.ItemResult{Error: errNoPassword}
+				invalid = true
+				continue
+			}
+			req := save.Request{URL: row.URL, Alias: row.Alias, Domain: row.Domain, ExpiresAt: row.ExpiresAt,
+				Password: row.Password, ForwardQuery: row.ForwardQuery, ForwardPath: row.ForwardPath}
+			results[i], items[i], err = saver.Prepare(creator, req, false, now)
+			if err != nil {
+				log.Error("failed to prepare row", sl.Err(err))
+				render.Status(r, http.StatusInternalServerError)
+				render.JSON(w, r, resp.Error("internal error"))
+				return
+			}
+			if results[i].Error != "" {
+				invalid = true
+			}
+		}
+
+		status := http.StatusOK
+		switch {
+		case onConflict == OnConflictSkip:
+			// rows that failed before Prepare have no item to save
+			for i := range items {
+				if results[i].Error == "" && !items[i].Done() {
+					results[i] = saver.SaveOne(log, items[i])
+				}
+			}
+		case invalid:
+			status = http.StatusBadRequest
+			batch.MarkNotSaved(results)
+		default:
+			status, err = saver.SaveAll(r.Context(), items, results)
+			if err != nil {
+				log.Error("failed to save import", sl.Err(err))
+			}
+			if status != http.StatusOK {
+				batch.MarkNotSaved(results)
+			}
+		}
+
+		response := ImportResponse{Response: resp.OK(), Rows: make([]RowResult, len(results))}
+		for i, res := range results {
+			row := RowResult{Row: i + 1, Alias: res.Alias, Error: res.Error}
+			switch {
+			case onConflict == OnConflictSkip && res.Error == batch.ErrAliasExists:
+				row = RowResult{Row: i + 1, Alias: rows[i].Alias, Skipped: true}
+				response.Skipped++
+			case res.Error != "":
+				response.Failed++
+			default:
+				response.Imported++
+			}
+			response.Rows[i] = row
+		}
+		if status != http.StatusOK {
+			response.Response = resp.Error("nothing imported")
+			response.Imported = 0
+		}
+
+		log.Info("import done", slog.Int("imported", response.Imported), slog.Int("skipped", response.Skipped), slog.Int("failed", response.Failed))
+
+		render.Status(r, status)
+		render.JSON(w, r, response)
+	}
+}
+
+// importFormat reads the format parameter, falling back to the Content-Type and then json
+func importFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	for format, contentType := range contentTypes {
+		if mediaType == contentType {
+			return format
+		}
+	}
+	return FormatJSON
+}
@@ -0,0 +1,206 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:    "text/csv",
+	FormatJSON:   "application/json",
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// csvHeader are the columns of csv files, imports need url and may leave out the others. Imports
+// also read a password column, exports never write one.
+var csvHeader = []string{"alias", "domain", "url", "created_at", "expires_at", "clicks", "forward_query", "forward_path", "protected"}
+
+// Record is one link of an export or import file. CreatedAt and Clicks are informational, imports
+// ignore them.
+//
+// Exports can't carry passwords, only their hashes are stored, so protected links are marked with
+// Protected instead. Imports refuse a protected record unless it has a Password to protect it with
+// again, a password protected link must not come back public.
+type Record struct {
+	Alias     string     `json:"alias,omitempty"`
+	Domain    string     `json:"domain,omitempty"`
+	URL       string     `json:"url"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Clicks    int64      `json:"clicks,omitempty"`
+	// ForwardQuery and ForwardPath are the passthrough settings of the link
+	ForwardQuery bool   `json:"forward_query,omitempty"`
+	ForwardPath  bool   `json:"forward_path,omitempty"`
+	Protected    bool   `json:"protected,omitempty"`
+	Password     string `json:"password,omitempty"`
+}
+
+func recordOf(link storage.LinkSummary) Record {
+	createdAt := link.CreatedAt.UTC()
+	var expiresAt *time.Time
+	if link.ExpiresAt != nil {
+		t := link.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+	return Record{
+		Alias:        link.Alias,
+		Domain:       link.Domain,
+		URL:          link.URL,
+		CreatedAt:    &createdAt,
+		ExpiresAt:    expiresAt,
+		Clicks:       link.Clicks,
+		ForwardQuery: link.Passthrough.Query,
+		ForwardPath:  link.Passthrough.Path,
+		Protected:    link.Protected(),
+	}
+}
+
+func (rec Record) csvRow() []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	return []string{rec.Alias, rec.Domain, rec.URL, formatTime(rec.CreatedAt), formatTime(rec.ExpiresAt), strconv.FormatInt(rec.Clicks, 10),
+		strconv.FormatBool(rec.ForwardQuery), strconv.FormatBool(rec.ForwardPath), strconv.FormatBool(rec.Protected)}
+}
+
+// parsed is a record read from an import file, or the reason it could not be read
+type parsed struct {
+	Record
+	err error
+}
+
+// errTooManyRows stops reading an import past its row limit
+var errTooManyRows = errors.New("too many rows")
+
+// readRecords reads up to limit records of format from r. Rows that can't be read come back with
+// an error, errors of the file as a whole are returned.
+func readRecords(r io.Reader, format string, limit int) ([]parsed, error) {
+	var rows []parsed
+	add := func(p parsed) error {
+		if len(rows) == limit {
+			return errTooManyRows
+		}
+		rows = append(rows, p)
+		return nil
+	}
+
+	switch format {
+	case FormatJSON:
+		// the array is read one record at a time, so the limit stops a large file early
+		dec := json.NewDecoder(r)
+		start, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+		if start != json.Delim('[') {
+			return nil, errors.New("invalid json: want an array of links")
+		}
+		for dec.More() {
+			var rec Record
+			if err := dec.Decode(&rec); err != nil {
+				return nil, fmt.Errorf("invalid json: %w", err)
+			}
+			if err := add(parsed{Record: rec}); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("invalid json: %w", err)
+		}
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			var p parsed
+			if err := json.Unmarshal([]byte(line), &p.Record); err != nil {
+				p.err = errors.New("invalid json")
+			}
+			if err := add(p); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("invalid ndjson: %w", err)
+		}
+	case FormatCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv header: %w", err)
+		}
+		columns := make(map[string]int)
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := columns["url"]; !ok {
+			return nil, errors.New("csv header has no url column")
+		}
+		for {
+			row, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid csv: %w", err)
+			}
+			if err := add(csvRecord(columns, row)); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return rows, nil
+}
+
+func csvRecord(columns map[string]int, row []string) parsed {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	p := parsed{Record: Record{Alias: field("alias"), Domain: field("domain"), URL: field("url"), Password: field("password")}}
+	if v := field("expires_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			p.err = errors.New("expires_at is not an RFC 3339 time")
+			return p
+		}
+		p.ExpiresAt = &t
+	}
+	for name, dst := range map[string]*bool{"forward_query": &p.ForwardQuery, "forward_path": &p.ForwardPath, "protected": &p.Protected} {
+		if v := field(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				p.err = fmt.Errorf("%s is not true or false", name)
+				return p
+			}
+			*dst = b
+		}
+	}
+	return p
+}
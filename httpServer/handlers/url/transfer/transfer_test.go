@@ -0,0 +1,216 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"url_shortener/httpServer/handlers/url/alias"
+	"url_shortener/httpServer/handlers/url/batch"
+	"url_shortener/httpServer/handlers/url/random"
+	"url_shortener/httpServer/handlers/url/save"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSaver(t *testing.T, s batch.BatchSaver) *batch.Saver {
+	crypto, err := random.NewCrypto("", false)
+	require.NoError(t, err)
+	policy, err := alias.NewPolicy(config.AliasPolicy{Pattern: "^[A-Za-z0-9_-]+$", MinLength: 3, MaxLength: 64})
+	require.NoError(t, err)
+	return batch.NewSaver(s, save.AliasOptions{
+		Policy:     policy,
+		Generators: map[string]save.AliasGenerator{random.GeneratorRandom: crypto},
+		Default:    random.GeneratorRandom,
+		Length:     6,
+		Attempts:   5,
+	})
+}
+
+func do(h http.HandlerFunc, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+	req = req.WithContext(context.WithValue(req.Context(), "user_id", "owner"))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestExportImport(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			src := memory.NewStorage()
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			for i, target := range []string{"https://a.com", "https://b.com", "https://c.com"} {
+				link := storage.Link{Alias: "link" + string(rune('a'+i)), URL: target, Creator: "owner"}
+				if i == 0 {
+					link.ExpiresAt = &expiresAt
+				}
+				if i == 1 {
+					link.Passthrough = storage.Passthrough{Query: true}
+				}
+				_, err := src.SaveURL(link)
+				require.NoError(t, err)
+			}
+			_, err := src.SaveURL(storage.Link{Alias: "other", URL: "https://d.com", Creator: "someone else"})
+			require.NoError(t, err)
+			_, err = src.VerifyDomain("go.example.com", "owner")
+			require.NoError(t, err)
+			_, err = src.SaveURL(storage.Link{Alias: "linka", Domain: "go.example.com", URL: "https://e.com", Creator: "owner"})
+			require.NoError(t, err)
+
+			rr := do(NewExport(slogdiscard.NewDiscardLogger(), src), http.MethodGet, "/url/export?format="+format, "")
+			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, contentTypes[format], rr.Header().Get("Content-Type"))
+			exported := rr.Body.String()
+			require.NotContains(t, exported, "https://d.com")
+
+			dst := memory.NewStorage()
+			_, err = dst.VerifyDomain("go.example.com", "owner")
+			require.NoError(t, err)
+			rr = do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, dst), 10), http.MethodPost, "/url/import?format="+format, exported)
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+			var resp ImportResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, 4, resp.Imported)
+
+			link, err := dst.GetLink("linka")
+			require.NoError(t, err)
+			require.Equal(t, "https://a.com", link.URL)
+			require.NotNil(t, link.ExpiresAt)
+			require.True(t, expiresAt.Equal(*link.ExpiresAt))
+
+			link, err = dst.GetLink("linkb")
+			require.NoError(t, err)
+			require.Equal(t, storage.Passthrough{Query: true}, link.Passthrough)
+
+			link, err = dst.GetLink(storage.Key("go.example.com", "linka"))
+			require.NoError(t, err)
+			require.Equal(t, "https://e.com", link.URL)
+		})
+	}
+}
+
+func TestImportConflicts(t *testing.T) {
+	body := "url,alias\nhttps://a.com,taken\nhttps://b.com,fresh\nhttps://c.com,\n"
+
+	cases := []struct {
+		onConflict   string
+		wantStatus   int
+		wantImported int
+		wantSkipped  int
+	}{
+		{onConflict: OnConflictFail, wantStatus: http.StatusConflict},
+		{onConflict: OnConflictSkip, wantStatus: http.StatusOK, wantImported: 2, wantSkipped: 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.onConflict, func(t *testing.T) {
+			s := memory.NewStorage()
+			_, err := s.SaveURL(storage.Link{Alias: "taken", URL: "https://x.com", Creator: "someone else"})
+			require.NoError(t, err)
+
+			rr := do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, s), 10), http.MethodPost,
+				"/url/import?format=csv&on_conflict="+tc.onConflict, body)
+			require.Equal(t, tc.wantStatus, rr.Code)
+
+			var resp ImportResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.wantImported, resp.Imported)
+			require.Equal(t, tc.wantSkipped, resp.Skipped)
+			require.Len(t, resp.Rows, 3)
+
+			_, err = s.GetLink("fresh")
+			require.Equal(t, tc.wantImported > 0, err == nil)
+		})
+	}
+}
+
+func TestImportInvalidRows(t *testing.T) {
+	body := "{\"url\": \"https://a.com\"}\n{\"url\": \"not a url\"}\nnot json\n"
+
+	rr := do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, memory.NewStorage()), 10), http.MethodPost, "/url/import", body)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, memory.NewStorage()), 10), http.MethodPost,
+		"/url/import?format=ndjson&on_conflict=skip", body)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp ImportResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, 1, resp.Imported)
+	require.Equal(t, 2, resp.Failed)
+	require.True(t, strings.Contains(resp.Rows[1].Error, "field URL is not a valid URL"))
+	require.Equal(t, "invalid json", resp.Rows[2].Error)
+}
+
+func TestExportProtectedAndExpired(t *testing.T) {
+	src := memory.NewStorage()
+	earlier := time.Now().Add(-time.Hour)
+	for _, link := range []storage.Link{
+		{Alias: "open", URL: "https://a.com", Creator: "owner"},
+		{Alias: "secret", URL: "https://b.com", Creator: "owner", Password: "hunter22"},
+		{Alias: "old", URL: "https://c.com", Creator: "owner", ExpiresAt: &earlier},
+	} {
+		_, err := src.SaveURL(link)
+		require.NoError(t, err)
+	}
+
+	rr := do(NewExport(slogdiscard.NewDiscardLogger(), src), http.MethodGet, "/url/export?format=ndjson", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	exported := rr.Body.String()
+	require.NotContains(t, exported, "https://c.com", "expired links are left out")
+	require.Contains(t, exported, `"alias":"secret","url":"https://b.com","created_at"`)
+	require.Contains(t, exported, `"protected":true`)
+	require.NotContains(t, exported, "hunter22")
+
+	dst := memory.NewStorage()
+	rr = do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, dst), 10), http.MethodPost, "/url/import?format=ndjson", exported)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	var resp ImportResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, errNoPassword, resp.Rows[1].Error)
+	_, err := dst.GetLink("open")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// skipping conflicts does not save the rows that failed
+	skipped := memory.NewStorage()
+	rr = do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, skipped), 10), http.MethodPost, "/url/import?format=ndjson&on_conflict=skip", exported)
+	require.Equal(t, http.StatusOK, rr.Code)
+	resp = ImportResponse{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, 1, resp.Imported)
+	require.Equal(t, 1, resp.Failed)
+	require.Equal(t, errNoPassword, resp.Rows[1].Error)
+	_, err = skipped.GetLink("secret")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = skipped.GetLink("")
+	require.ErrorIs(t, err, storage.ErrURLNotFound, "no empty link is saved for the failed row")
+
+	// with a password set again the link comes back protected
+	exported = strings.Replace(exported, `"protected":true`, `"protected":true,"password":"swordfish"`, 1)
+	rr = do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, dst), 10), http.MethodPost, "/url/import?format=ndjson", exported)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	link, err := dst.GetLink("secret")
+	require.NoError(t, err)
+	require.True(t, link.Protected())
+	require.True(t, link.CheckPassword("swordfish"))
+}
+
+func TestImportJSONLimit(t *testing.T) {
+	body := `[{"url": "https://a.com"}, {"url": "https://b.com"}, {"url": "https://c.com"}, not json at all`
+
+	rr := do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, memory.NewStorage()), 2), http.MethodPost, "/url/import", body)
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "the limit is hit before the rest is read")
+
+	rr = do(NewImport(slogdiscard.NewDiscardLogger(), newSaver(t, memory.NewStorage()), 10), http.MethodPost, "/url/import", `{"url": "https://a.com"}`)
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+	require.Contains(t, rr.Body.String(), "want an array of links")
+}
@@ -0,0 +1,169 @@
+package update
+
+import (
+	"errors"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator"
+	"github.com/gorilla/mux"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+	"url_shortener/cmd/middleware"
+	"url_shortener/httpServer/handlers"
+	resp "url_shortener/internal/lib/api/response"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+// Request holds the fields to change, omitted fields are left as they are.
+// URL follows the same validation rules as save.Request.
+type Request struct {
+	URL          *string    `json:"url,omitempty" validate:"omitempty,url"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	TTL          string     `json:"ttl,omitempty"`
+	NeverExpires bool       `json:"never_expires,omitempty"`
+	// Tags replace the tags of the link, an empty list removes them all
+	Tags *[]string `json:"tags,omitempty" validate:"omitempty,max=20,dive,min=1,max=32"`
+	// Collection moves the link to the collection of that id, an empty one takes it out of its collection
+	Collection *string `json:"collection,omitempty"`
+	// ForwardQuery and ForwardPath turn forwarding of the query string and the path after the alias on or off
+	ForwardQuery *bool `json:"forward_query,omitempty"`
+	ForwardPath  *bool `json:"forward_path,omitempty"`
+}
+
+type Response struct {
+	resp.Response
+	Alias      string     `json:"alias"`
+	Domain     string     `json:"domain,omitempty"`
+	URL        string     `json:"url"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Collection string     `json:"collection,omitempty"`
+	// ForwardQuery and ForwardPath tell what redirects carry over to the target
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	ForwardPath  bool `json:"forward_path,omitempty"`
+}
+
+type URLUpdater interface {
+	UpdateURL(alias, creator string, upd storage.LinkUpdate) (storage.Link, error)
+}
+
+func New(log *slog.Logger, urlUpdater URLUpdater) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const info = "handlers.url.update.New"
+
+		log := log.With(
+			slog.String("info", info),
+			slog.String("request_id", middleware.GetReqID(r.Context())))
+
+		alias := mux.Vars(r)["alias"]
+		if alias == "" {
+			log.Info("alias is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("invalid request"))
+			return
+		}
+		creator, err := handlers.GetUserIDFromContext(r.Context())
+		if err != nil {
+			log.Error("could not get user id from context, unauthorized", sl.Err(err))
+			render.Status(r, http.StatusUnauthorized)
+			render.JSON(w, r, resp.Error("could not ger user id from context, unauthorized"))
+			return
+		}
+
+		var req Request
+		err = render.DecodeJSON(r.Body, &req)
+		if errors.Is(err, io.EOF) {
+			log.Error("request body is empty")
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("empty request"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to decode request body", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("failed to decode request"))
+			return
+		}
+
+		log.Info("request body decoded", slog.Any("request", req))
+
+		if err := validator.New().Struct(req); err != nil {
+			validateErr := err.(validator.ValidationErrors)
+			log.Error("invalid request", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.ErrorValidator(validateErr))
+			return
+		}
+
+		upd, err := req.LinkUpdate(time.Now())
+		if err != nil {
+			log.Error("invalid update", sl.Err(err))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error(err.Error()))
+			return
+		}
+
+		link, err := urlUpdater.UpdateURL(handlers.LinkKey(r, alias), creator, upd)
+		if errors.Is(err, storage.ErrAliasNotFound) {
+			log.Info("Alias not found", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("alias not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrCollectionNotFound) {
+			log.Info("collection not found", slog.String("alias", alias))
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, resp.Error("collection not found"))
+			return
+		}
+		if errors.Is(err, storage.ErrCaseMismatch) {
+			log.Info("Alias has a case sensitivity issue", slog.String("alias", alias))
+			render.Status(r, http.StatusNotFound)
+			render.JSON(w, r, resp.Error("case sensitivity problem"))
+			return
+		}
+		if err != nil {
+			log.Error("failed to update url", sl.Err(err))
+			render.Status(r, http.StatusInternalServerError)
+			render.JSON(w, r, resp.Error("internal error"))
+			return
+		}
+
+		log.Info("url updated", slog.String("alias", alias))
+
+		render.JSON(w, r, Response{
+			Response:     resp.OK(),
+			Alias:        link.Alias,
+			Domain:       link.Domain,
+			URL:          link.URL,
+			CreatedAt:    link.CreatedAt,
+			ExpiresAt:    link.ExpiresAt,
+			Tags:         link.Tags,
+			Collection:   link.CollectionID,
+			ForwardQuery: link.Passthrough.Query,
+			ForwardPath:  link.Passthrough.Path,
+		})
+	}
+}
+
+// LinkUpdate turns the request into a storage update, expiration is counted from now.
+func (req Request) LinkUpdate(now time.Time) (storage.LinkUpdate, error) {
+	upd := storage.LinkUpdate{URL: req.URL, ClearExpiresAt: req.NeverExpires, Tags: req.Tags, CollectionID: req.Collection,
+		ForwardQuery: req.ForwardQuery, ForwardPath: req.ForwardPath}
+	if req.NeverExpires && (req.ExpiresAt != nil || req.TTL != "") {
+		return upd, errors.New("never_expires can't be combined with expires_at or ttl")
+	}
+	var err error
+	upd.ExpiresAt, err = handlers.ResolveExpiration(req.ExpiresAt, req.TTL, now)
+	if err != nil {
+		return upd, err
+	}
+	if upd.URL == nil && upd.ExpiresAt == nil && !upd.ClearExpiresAt && upd.Tags == nil && upd.CollectionID == nil &&
+		upd.ForwardQuery == nil && upd.ForwardPath == nil {
+		return upd, errors.New("nothing to update")
+	}
+	return upd, nil
+}
@@ -0,0 +1,133 @@
+package update
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	cases := []struct {
+		name       string
+		alias      string
+		user       string
+		body       string
+		wantStatus int
+		wantBody   string
+		wantURL    string
+	}{
+		{
+			name:       "Success",
+			alias:      "google",
+			user:       "owner",
+			body:       `{"url": "https://bing.com", "ttl": "24h"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"expires_at"`,
+			wantURL:    "https://bing.com",
+		},
+		{
+			name:       "Invalid URL",
+			alias:      "google",
+			user:       "owner",
+			body:       `{"url": "invalid_url"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "field URL is not a valid URL",
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Not owner",
+			alias:      "google",
+			user:       "someone else",
+			body:       `{"url": "https://bing.com"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   "alias not found",
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Case mismatch",
+			alias:      "Google",
+			user:       "owner",
+			body:       `{"url": "https://bing.com"}`,
+			wantStatus: http.StatusNotFound,
+			wantBody:   "case sensitivity problem",
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Tags",
+			alias:      "google",
+			user:       "owner",
+			body:       `{"tags": ["Search", "search", "web"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"tags":["search","web"]`,
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Tag too long",
+			alias:      "google",
+			user:       "owner",
+			body:       `{"tags": ["` + strings.Repeat("x", 33) + `"]}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "is too long",
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Unknown collection",
+			alias:      "google",
+			user:       "owner",
+			body:       `{"collection": "missing"}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "collection not found",
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Passthrough",
+			alias:      "google",
+			user:       "owner",
+			body:       `{"forward_query": true}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"forward_query":true`,
+			wantURL:    "https://google.com",
+		},
+		{
+			name:       "Nothing to update",
+			alias:      "google",
+			user:       "owner",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "nothing to update",
+			wantURL:    "https://google.com",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := memory.NewStorage()
+			_, err := s.SaveURL(storage.Link{Alias: "google", URL: "https://google.com", Creator: "owner"})
+			require.NoError(t, err)
+
+			router := mux.NewRouter()
+			router.Handle("/url/{alias}", New(slogdiscard.NewDiscardLogger(), s)).Methods(http.MethodPatch)
+
+			req := httptest.NewRequest(http.MethodPatch, "/url/"+tc.alias, bytes.NewBufferString(tc.body))
+			req = req.WithContext(context.WithValue(req.Context(), "user_id", tc.user))
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.wantStatus, rr.Code)
+			require.Contains(t, rr.Body.String(), tc.wantBody)
+
+			link, err := s.GetLink("google")
+			require.NoError(t, err)
+			require.Equal(t, tc.wantURL, link.URL)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package clicks
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/sl"
+	"url_shortener/internal/storage"
+)
+
+const (
+	OnFullDrop  = "drop"
+	OnFullBlock = "block"
+)
+
+// ClickSaver persists a batch of click events.
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+}
+
+// Recorder queues click events in a bounded channel and writes them in batches from Run,
+// so redirects never wait on analytics inserts (unless the queue is full and OnFull is "block").
+type Recorder struct {
+	log     *slog.Logger
+	saver   ClickSaver
+	cfg     config.Clicks
+	queue   chan storage.Click
+	dropped atomic.Uint64
+}
+
+func NewRecorder(log *slog.Logger, saver ClickSaver, cfg config.Clicks) *Recorder {
+	return &Recorder{
+		log:   log.With(slog.String("info", "clicks.Recorder")),
+		saver: saver,
+		cfg:   cfg,
+		queue: make(chan storage.Click, cfg.QueueSize),
+	}
+}
+
+// Record queues a click on alias made by r.
+func (rec *Recorder) Record(r *http.Request, alias string) {
+	click := storage.Click{
+		Alias:     alias,
+		ClickedAt: time.Now(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		IP:        rec.clientIP(r),
+	}
+
+	if rec.cfg.OnFull == OnFullBlock {
+		select {
+		case rec.queue <- click:
+		case <-r.Context().Done():
+			rec.dropped.Add(1)
+		}
+		return
+	}
+
+	select {
+	case rec.queue <- click:
+	default:
+		rec.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many clicks were lost because the queue was full.
+func (rec *Recorder) Dropped() uint64 {
+	return rec.dropped.Load()
+}
+
+// Run drains the queue until ctx is done, saving a batch whenever it reaches BatchSize
+// or FlushInterval passes. Queued clicks are flushed before returning.
+func (rec *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(rec.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, rec.cfg.BatchSize)
+	flush := func(ctx context.Context) {
+		if len(batch) == 0 {
+			return
+		}
+		if err := rec.saver.SaveClicks(ctx, batch); err != nil {
+			rec.log.Error("failed to save clicks", sl.Err(err), slog.Int("count", len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case click := <-rec.queue:
+			batch = append(batch, click)
+			if len(batch) >= rec.cfg.BatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for {
+				select {
+				case click := <-rec.queue:
+					batch = append(batch, click)
+					if len(batch) >= rec.cfg.BatchSize {
+						flush(shutdownCtx)
+					}
+				default:
+					flush(shutdownCtx)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (rec *Recorder) clientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if rec.cfg.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			ip = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	if rec.cfg.TruncateIP {
+		return truncateIP(ip)
+	}
+	return ip
+}
+
+// truncateIP zeroes the host part of the address: the last octet of IPv4 and the last 80 bits of IPv6.
+func truncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
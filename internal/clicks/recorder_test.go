@@ -0,0 +1,91 @@
+package clicks
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+type saverStub struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+}
+
+func (s *saverStub) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]storage.Click(nil), clicks...))
+	return nil
+}
+
+func (s *saverStub) saved() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, b := range s.batches {
+		n += len(b)
+	}
+	return n
+}
+
+func TestRecorder_Batches(t *testing.T) {
+	saver := &saverStub{}
+	rec := NewRecorder(slogdiscard.NewDiscardLogger(), saver, config.Clicks{
+		QueueSize:     100,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		OnFull:        OnFullDrop,
+		TruncateIP:    true,
+	})
+
+	for i := 0; i < 5; i++ {
+		r := httptest.NewRequest("GET", "/alias", nil)
+		r.RemoteAddr = "192.168.1.42:5555"
+		r.Header.Set("Referer", "https://google.com")
+		rec.Record(r, "alias")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rec.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool { return saver.saved() >= 4 }, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	require.Equal(t, 5, saver.saved())
+	require.Len(t, saver.batches[0], 2)
+	click := saver.batches[0][0]
+	require.Equal(t, "alias", click.Alias)
+	require.Equal(t, "192.168.1.0", click.IP)
+	require.Equal(t, "https://google.com", click.Referrer)
+}
+
+func TestRecorder_DropWhenFull(t *testing.T) {
+	rec := NewRecorder(slogdiscard.NewDiscardLogger(), &saverStub{}, config.Clicks{
+		QueueSize:     1,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		OnFull:        OnFullDrop,
+	})
+
+	rec.Record(httptest.NewRequest("GET", "/alias", nil), "alias")
+	rec.Record(httptest.NewRequest("GET", "/alias", nil), "alias")
+
+	require.EqualValues(t, 1, rec.Dropped())
+}
+
+func TestTruncateIP(t *testing.T) {
+	require.Equal(t, "10.1.2.0", truncateIP("10.1.2.3"))
+	require.Equal(t, "2001:db8:abcd::", truncateIP("2001:db8:abcd:12::1"))
+	require.Equal(t, "", truncateIP("not an ip"))
+}
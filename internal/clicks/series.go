@@ -0,0 +1,81 @@
+package clicks
+
+import (
+	"fmt"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+const (
+	BucketHour = "hour"
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// BucketStart returns the start of the UTC bucket containing t. Weeks start on Monday.
+func BucketStart(t time.Time, bucket string) (time.Time, error) {
+	t = t.UTC()
+	switch bucket {
+	case BucketHour:
+		return t.Truncate(time.Hour), nil
+	case BucketDay:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+	case BucketWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		sinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -sinceMonday), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown bucket %q", bucket)
+	}
+}
+
+// nextBucket returns the start of the bucket following the one starting at start.
+// Buckets are in UTC, so they all have a fixed length.
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case BucketHour:
+		return start.Add(time.Hour)
+	case BucketWeek:
+		return start.Add(7 * 24 * time.Hour)
+	default:
+		return start.Add(24 * time.Hour)
+	}
+}
+
+// AlignRange widens [from, to) to whole buckets and returns the number of buckets it spans.
+func AlignRange(from, to time.Time, bucket string) (time.Time, time.Time, int, error) {
+	alignedFrom, err := BucketStart(from, bucket)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	lastStart, err := BucketStart(to.Add(-time.Nanosecond), bucket)
+	if err != nil {
+		return time.Time{}, time.Time{}, 0, err
+	}
+	alignedTo := nextBucket(lastStart, bucket)
+
+	size := nextBucket(alignedFrom, bucket).Sub(alignedFrom)
+	return alignedFrom, alignedTo, int(alignedTo.Sub(alignedFrom) / size), nil
+}
+
+// Series groups hourly counts into buckets covering the aligned range [from, to),
+// buckets without clicks are included with zero clicks.
+func Series(hourly []storage.ClickBucket, from, to time.Time, bucket string) []storage.ClickBucket {
+	var series []storage.ClickBucket
+	index := make(map[time.Time]int)
+	for start := from.UTC(); start.Before(to); start = nextBucket(start, bucket) {
+		index[start] = len(series)
+		series = append(series, storage.ClickBucket{Start: start})
+	}
+
+	for _, h := range hourly {
+		start, err := BucketStart(h.Start, bucket)
+		if err != nil {
+			continue
+		}
+		if i, ok := index[start]; ok {
+			series[i].Clicks += h.Clicks
+		}
+	}
+	return series
+}
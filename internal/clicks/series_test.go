@@ -0,0 +1,45 @@
+package clicks
+
+import (
+	"testing"
+	"time"
+	"url_shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeries(t *testing.T) {
+	// 2024-01-03 is a Wednesday
+	from := time.Date(2024, 1, 3, 10, 30, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 9, 1, 0, 0, 0, time.UTC)
+	hourly := []storage.ClickBucket{
+		{Start: time.Date(2024, 1, 3, 11, 0, 0, 0, time.UTC), Clicks: 2},
+		{Start: time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC), Clicks: 3},
+		{Start: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), Clicks: 4},
+	}
+
+	alignedFrom, alignedTo, buckets, err := AlignRange(from, to, BucketDay)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), alignedFrom)
+	require.Equal(t, time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), alignedTo)
+	require.Equal(t, 7, buckets)
+
+	series := Series(hourly, alignedFrom, alignedTo, BucketDay)
+	require.Len(t, series, 7)
+	require.EqualValues(t, 5, series[0].Clicks)
+	require.EqualValues(t, 0, series[1].Clicks)
+	require.EqualValues(t, 4, series[5].Clicks)
+
+	alignedFrom, alignedTo, buckets, err = AlignRange(from, to, BucketWeek)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), alignedFrom)
+	require.Equal(t, 2, buckets)
+
+	series = Series(hourly, alignedFrom, alignedTo, BucketWeek)
+	require.Len(t, series, 2)
+	require.EqualValues(t, 5, series[0].Clicks)
+	require.EqualValues(t, 4, series[1].Clicks)
+
+	_, _, _, err = AlignRange(from, to, "month")
+	require.Error(t, err)
+}
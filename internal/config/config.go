@@ -8,10 +8,30 @@ import (
 )
 
 type Config struct {
-	Env         string `yaml:"env" env-default:"local" env-required:"true"`
-	Dsn         string `yaml:"dsn" env-required:"true"`
-	AliasLength int    `yaml:"aliasLength" env-default:"6"`
-	HTTPServer  `yaml:"http_server"`
+	Env           string `yaml:"env" env-default:"local" env-required:"true"`
+	Storage       string `yaml:"storage" env-default:"postgres"` // postgres, sqlite, memory
+	Dsn           string `yaml:"dsn"`
+	StoragePath   string `yaml:"storage_path" env-default:"./storage/storage.db"` // sqlite database file
+	AutoMigrate   bool   `yaml:"auto_migrate" env-default:"true"`                 // apply pending migrations on server start
+	AliasLength   int    `yaml:"aliasLength" env-default:"6"`
+	AliasAttempts int    `yaml:"aliasAttempts" env-default:"5"` // generated aliases tried before giving up
+	// AliasGenerator is the default generator: random, sequence, words; requests may pick another one
+	AliasGenerator    string `yaml:"aliasGenerator" env-default:"random"`
+	AliasAlphabet     string `yaml:"aliasAlphabet"`                         // characters of random aliases, letters and digits if empty
+	AliasNoLookalikes bool   `yaml:"aliasNoLookalikes" env-default:"false"` // leave 0/O/o, 1/l/I/i out of random aliases
+	DedupURLs         bool   `yaml:"dedupURLs" env-default:"false"`         // return the caller's existing link to the same destination
+	BatchLimit        int    `yaml:"batchLimit" env-default:"500"`          // most links in one POST /url/batch or /url/bulk
+	ImportLimit       int    `yaml:"importLimit" env-default:"10000"`       // most rows in one POST /url/import
+	AliasPolicy       `yaml:"alias_policy"`
+	HTTPServer        `yaml:"http_server"`
+	Expiration        `yaml:"expiration"`
+	Trash             `yaml:"trash"`
+	Protection        `yaml:"protection"`
+	Activation        `yaml:"activation"`
+	Domains           `yaml:"domains"`
+	QR                `yaml:"qr"`
+	Clicks            `yaml:"clicks"`
+	Cache             `yaml:"cache"`
 }
 type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:"localhost:8080"`
@@ -19,6 +39,71 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
 }
 
+// AliasPolicy restricts the aliases users pick themselves.
+type AliasPolicy struct {
+	Pattern   string   `yaml:"pattern" env-default:"^[A-Za-z0-9_-]+$"` // "/" is refused even if the pattern allows it
+	MinLength int      `yaml:"min_length" env-default:"3"`
+	MaxLength int      `yaml:"max_length" env-default:"64"`
+	Reserved  []string `yaml:"reserved"`  // on top of the first path segments of the routes
+	Blocklist string   `yaml:"blocklist"` // file with one word per line, aliases containing one are refused
+}
+
+// Expiration configures the background sweeper of expired links.
+type Expiration struct {
+	SweepInterval time.Duration `yaml:"sweep_interval" env-default:"1h"`
+	GracePeriod   time.Duration `yaml:"grace_period" env-default:"24h"` // expired links keep answering 410 for this long
+	Archive       bool          `yaml:"archive" env-default:"false"`    // move to url_archive instead of deleting
+}
+
+// Trash configures how long deleted links can be restored, their aliases stay taken meanwhile.
+type Trash struct {
+	Retention time.Duration `yaml:"retention" env-default:"720h"`
+}
+
+// Protection configures password protected links.
+type Protection struct {
+	MaxAttempts       int           `yaml:"max_attempts" env-default:"5"`            // wrong passwords in a row that lock the link for a client
+	Lockout           time.Duration `yaml:"lockout" env-default:"15m"`               // how long a locked link refuses passwords
+	TrustForwardedFor bool          `yaml:"trust_forwarded_for" env-default:"false"` // count lockouts for the client ip of X-Forwarded-For
+}
+
+// Activation configures the answer to links visited before their activation window that have no fallback url.
+type Activation struct {
+	ComingSoonStatus  int    `yaml:"coming_soon_status" env-default:"404"`
+	ComingSoonMessage string `yaml:"coming_soon_message" env-default:"coming soon"`
+	ComingSoonPage    string `yaml:"coming_soon_page"` // html file shown to browsers instead of the json message
+}
+
+// Domains configures host-based alias resolution of custom domains.
+type Domains struct {
+	RefreshInterval time.Duration `yaml:"refresh_interval" env-default:"1m"` // how often registered hosts are reloaded
+	Reserved        []string      `yaml:"reserved"`                          // hosts of the default domain, users can't register them
+}
+
+// QR configures the qr codes of short links.
+type QR struct {
+	BaseURL string `yaml:"base_url"`                    // scheme and host of short links, taken from the request if empty
+	MaxSize int    `yaml:"max_size" env-default:"2048"` // largest image in pixels
+}
+
+// Clicks configures the asynchronous click event queue.
+type Clicks struct {
+	QueueSize         int           `yaml:"queue_size" env-default:"10000"`
+	BatchSize         int           `yaml:"batch_size" env-default:"500"`
+	FlushInterval     time.Duration `yaml:"flush_interval" env-default:"1s"`
+	OnFull            string        `yaml:"on_full" env-default:"drop"`              // drop, block
+	TruncateIP        bool          `yaml:"truncate_ip" env-default:"true"`          // keep only the network part of client ips
+	TrustForwardedFor bool          `yaml:"trust_forwarded_for" env-default:"false"` // take client ip from X-Forwarded-For
+}
+
+// Cache configures the in-process redirect cache.
+type Cache struct {
+	Enabled     bool          `yaml:"enabled" env-default:"true"`
+	Size        int           `yaml:"size" env-default:"10000"`       // max number of cached aliases
+	TTL         time.Duration `yaml:"ttl" env-default:"1m"`           // also bounds staleness across instances
+	NegativeTTL time.Duration `yaml:"negative_ttl" env-default:"10s"` // for unknown and expired aliases
+}
+
 func MustLoad() *Config {
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
@@ -38,6 +38,20 @@ func ErrorValidator(errs validator.ValidationErrors) Response {
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a required field", err.Field()))
 		case "url":
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not a valid URL", err.Field()))
+		case "min":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is too short", err.Field()))
+		case "max":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is too long", err.Field()))
+		case "alias_chars":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s has characters that are not allowed", err.Field()))
+		case "alias_min":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is too short", err.Field()))
+		case "alias_max":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is too long", err.Field()))
+		case "alias_reserved":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s is a reserved word", err.Field()))
+		case "alias_blocked":
+			errMsgs = append(errMsgs, fmt.Sprintf("field %s contains a blocked word", err.Field()))
 		default:
 			errMsgs = append(errMsgs, fmt.Sprintf("field %s is not valid", err.Field()))
 		}
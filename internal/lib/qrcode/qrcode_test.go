@@ -0,0 +1,193 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD at 1-M from the worked example of the standard
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	ecc := rsRemainder(data, rsDivisor(10))
+	require.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, ecc)
+}
+
+func TestTables(t *testing.T) {
+	require.Equal(t, 0b111011111000100, formatBits(Low, 0))
+	require.Equal(t, 0b101010000010010, formatBits(Medium, 0))
+	require.Equal(t, 0b011010101011111, formatBits(Quartile, 0))
+	require.Equal(t, 0b001011010001001, formatBits(High, 0))
+	require.Equal(t, 0b000111110010010100, versionBits(7))
+	require.Equal(t, 0b101000110001101001, versionBits(40))
+
+	require.Equal(t, 19, numDataCodewords(1, Low))
+	require.Equal(t, 9, numDataCodewords(1, High))
+	require.Equal(t, 216, numDataCodewords(10, Medium))
+	require.Equal(t, 2956, numDataCodewords(40, Low))
+	require.Equal(t, 1276, numDataCodewords(40, High))
+
+	require.Nil(t, alignmentPositions(1))
+	require.Equal(t, []int{6, 18}, alignmentPositions(2))
+	require.Equal(t, []int{6, 22, 38}, alignmentPositions(7))
+	require.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPositions(32))
+
+	for level := Low; level <= High; level++ {
+		for version := minVersion + 1; version <= maxVersion; version++ {
+			require.Greater(t, numDataCodewords(version, level), numDataCodewords(version-1, level))
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	c, err := Encode("https://sho.rt/abc", Medium)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Version)
+	require.Equal(t, 25, c.Size)
+
+	c, err = Encode(strings.Repeat("a", 14), Medium)
+	require.NoError(t, err)
+	require.Equal(t, 1, c.Version, "1-M holds 14 bytes")
+	c, err = Encode(strings.Repeat("a", 15), Medium)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Version)
+
+	_, err = Encode(strings.Repeat("a", 2953), Low)
+	require.NoError(t, err)
+	_, err = Encode(strings.Repeat("a", 2954), Low)
+	require.ErrorIs(t, err, ErrTooLong)
+}
+
+// TestRoundTrip reads symbols back: format information, unmasking, block structure and the
+// error correction of every block.
+func TestRoundTrip(t *testing.T) {
+	texts := []string{"", "https://sho.rt/abc", "https://go.acme.com/spring-sale?utm_source=print", strings.Repeat("xyz", 120)}
+	for level := Low; level <= High; level++ {
+		for _, text := range texts {
+			c, err := Encode(text, level)
+			require.NoError(t, err)
+			require.Equal(t, text, decode(t, c), "version %d level %d", c.Version, level)
+		}
+	}
+}
+
+// decode is the reverse of Encode for the symbols it makes.
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+
+	var format int
+	for i := 14; i >= 9; i-- {
+		format = format<<1 | bit(c.modules[8][14-i])
+	}
+	format = format<<1 | bit(c.modules[8][7])
+	format = format<<1 | bit(c.modules[8][8])
+	format = format<<1 | bit(c.modules[7][8])
+	for i := 5; i >= 0; i-- {
+		format = format<<1 | bit(c.modules[i][8])
+	}
+	require.Equal(t, formatBits(c.Level, c.Mask), format)
+	require.True(t, c.modules[c.Size-8][8], "dark module")
+
+	c.applyMask(c.Mask)
+	defer c.applyMask(c.Mask)
+
+	var bits []bool
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if !c.isFunction[y][x] {
+					bits = append(bits, c.modules[y][x])
+				}
+			}
+		}
+	}
+	require.Equal(t, numRawDataModules(c.Version), len(bits))
+	raw := bitBuffer(bits[:len(bits)/8*8]).bytes()
+
+	blocks := numBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := blocks - len(raw)%blocks
+	shortLen := len(raw) / blocks
+	split := make([][]byte, blocks)
+	k := 0
+	for i := 0; i < shortLen+1; i++ {
+		for j := range split {
+			if i != shortLen-eccLen || j >= numShort {
+				split[j] = append(split[j], raw[k])
+				k++
+			}
+		}
+	}
+	var data []byte
+	divisor := rsDivisor(eccLen)
+	for _, block := range split {
+		dataLen := len(block) - eccLen
+		require.Equal(t, block[dataLen:], rsRemainder(block[:dataLen], divisor))
+		data = append(data, block[:dataLen]...)
+	}
+
+	var bb bitBuffer
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	read := func(n int) int {
+		v := 0
+		for _, b := range bb[:n] {
+			v = v<<1 | bit(b)
+		}
+		bb = bb[n:]
+		return v
+	}
+	require.Equal(t, 0b0100, read(4))
+	n := read(charCountBits(c.Version))
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(read(8))
+	}
+	return string(out)
+}
+
+func bit(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode("https://sho.rt/abc", Medium)
+	require.NoError(t, err)
+	fg, err := ParseColor("#112233")
+	require.NoError(t, err)
+	bg, err := ParseColor("ffffff")
+	require.NoError(t, err)
+	_, err = ParseColor("red")
+	require.Error(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, c.PNG(&buf, Style{Size: 300, Margin: 4, Foreground: fg, Background: bg}))
+	img, err := png.Decode(&buf)
+	require.NoError(t, err)
+	require.Equal(t, 297, img.Bounds().Dx(), "33 modules of 9 pixels")
+	r, g, b, _ := img.At(4*9, 4*9).RGBA()
+	require.Equal(t, []uint32{0x11, 0x22, 0x33}, []uint32{r >> 8, g >> 8, b >> 8}, "top left finder is dark")
+	r, _, _, _ = img.At(0, 0).RGBA()
+	require.EqualValues(t, 0xff, r>>8, "margin is light")
+
+	buf.Reset()
+	require.NoError(t, c.SVG(&buf, Style{Size: 300, Margin: 2, Foreground: fg, Background: bg}))
+	svg := buf.String()
+	require.Contains(t, svg, `width="300" height="300" viewBox="0 0 29 29"`)
+	require.Contains(t, svg, `fill="#112233"`)
+	require.Contains(t, svg, "M2,2h1v1h-1z")
+}
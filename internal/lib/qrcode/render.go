@@ -0,0 +1,87 @@
+package qrcode
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"strings"
+)
+
+var (
+	Black = color.RGBA{A: 0xff}
+	White = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+)
+
+// Style is how a symbol is drawn. Margin is the light border in modules, the standard asks for 4.
+type Style struct {
+	Size       int // width and height in pixels
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+// modulesWide is the width of the symbol with its margin.
+func (c *Code) modulesWide(margin int) int {
+	return c.Size + 2*margin
+}
+
+// PNG writes the symbol as a two color PNG. Modules are whole pixels, so the image is Size
+// rounded down to a multiple of the module count, and never less than one pixel per module.
+func (c *Code) PNG(w io.Writer, s Style) error {
+	total := c.modulesWide(s.Margin)
+	scale := max(1, s.Size/total)
+	img := image.NewPaletted(image.Rect(0, 0, total*scale, total*scale), color.Palette{s.Background, s.Foreground})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				row := (s.Margin+y)*scale + py
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex((s.Margin+x)*scale+px, row, 1)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// SVG writes the symbol as an SVG image of Size pixels, the modules are scaled by the viewBox.
+func (c *Code) SVG(w io.Writer, s Style) error {
+	total := c.modulesWide(s.Margin)
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", s.Margin+x, s.Margin+y)
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">
+<rect width="100%%" height="100%%" fill="%s"/>
+<path fill="%s" d="%s"/>
+</svg>
+`, s.Size, s.Size, total, total, hex(s.Background), hex(s.Foreground), path.String())
+	return err
+}
+
+// ParseColor reads a color written as rrggbb, optionally with a leading #.
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	var r, g, b uint8
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("color %q is not rrggbb", s)
+	}
+	if _, err := fmt.Sscanf(s, "%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA{}, fmt.Errorf("color %q is not rrggbb", s)
+	}
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}, nil
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,17 @@
+package storage
+
+import "fmt"
+
+// ItemError is the error of one link of a batch, Index is its position in the batch.
+type ItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *ItemError) Error() string {
+	return fmt.Sprintf("item %d: %v", e.Index, e.Err)
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"golang.org/x/sync/singleflight"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/storage"
+)
+
+// LinkSource is the storage behind the cache. Writes go through the cache so that
+// it can drop the entries they change.
+type LinkSource interface {
+	GetLink(alias string) (storage.Link, error)
+	DeleteURL(alias, creator string) (bool, error)
+	UpdateURL(alias, creator string, upd storage.LinkUpdate) (storage.Link, error)
+	RestoreURL(alias, creator string) (storage.Link, error)
+}
+
+type entry struct {
+	alias     string
+	url       string
+	err       error // set for negative entries: storage.ErrURLNotFound or an error of storage.Link.Target
+	expiresAt time.Time
+
+	passthrough storage.Passthrough
+}
+
+// Cache is a size bounded LRU of redirect targets in front of a LinkSource. It implements
+// redirect.URLGetter. Entries live for TTL, missing and expired aliases for NegativeTTL,
+// and concurrent misses of the same alias share a single storage lookup.
+//
+// Invalidation is local to the process: other instances see a change once their entry expires.
+type Cache struct {
+	source LinkSource
+	cfg    config.Cache
+	now    func() time.Time
+
+	mu      sync.Mutex
+	lru     *list.List // front is the most recently used
+	items   map[string]*list.Element
+	version uint64 // bumped by every invalidation, loads started before it are not stored
+
+	group  singleflight.Group
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func New(source LinkSource, cfg config.Cache) *Cache {
+	return &Cache{
+		source: source,
+		cfg:    cfg,
+		now:    time.Now,
+		lru:    list.New(),
+		items:  make(map[string]*list.Element),
+	}
+}
+
+// GetURL returns the target of alias with the same errors as the storage's GetURL.
+func (c *Cache) GetURL(alias string) (string, error) {
+	const info = "storage.cache.GetURL"
+
+	if e, ok := c.get(alias); ok {
+		c.hits.Add(1)
+		return e.url, e.err
+	}
+	c.misses.Add(1)
+
+	url, err, _ := c.group.Do(alias, func() (any, error) {
+		c.mu.Lock()
+		version := c.version
+		c.mu.Unlock()
+
+		link, err := c.source.GetLink(alias)
+		now := c.now()
+		e := entry{alias: alias, expiresAt: now.Add(c.cfg.NegativeTTL), passthrough: link.Passthrough}
+		if errors.Is(err, storage.ErrURLNotFound) {
+			e.err = err
+			c.set(e, version)
+			return "", err
+		}
+		if err != nil {
+			return "", err
+		}
+
+		target, err := link.Target(now)
+		e.url = target // the fallback url comes along with ErrURLNotStarted and ErrURLEnded
+		switch {
+		case errors.Is(err, storage.ErrURLProtected), errors.Is(err, storage.ErrURLLimited):
+			// the target stays out of the cache, the redirect goes to storage for the password
+			// check or the click count
+			e.err = fmt.Errorf("%s: %s, %w", info, alias, err)
+			e.expiresAt = now.Add(c.cfg.TTL)
+		case err != nil:
+			e.err = fmt.Errorf("%s: %s, %w", info, alias, err)
+		default:
+			e.expiresAt = now.Add(c.cfg.TTL)
+		}
+		// the entry must not outlive the target: expiration, activation window and schedule
+		if next := link.NextChange(now); next != nil && next.Before(e.expiresAt) {
+			e.expiresAt = *next
+		}
+		c.set(e, version)
+		return e.url, e.err
+	})
+	return url.(string), err
+}
+
+// Passthrough returns what redirects of alias forward to its target. The redirect asks right after
+// GetURL, so it is normally answered by the same entry; it does not count as a hit or a miss.
+func (c *Cache) Passthrough(alias string) (storage.Passthrough, error) {
+	const info = "storage.cache.Passthrough"
+
+	if e, ok := c.get(alias); ok && !errors.Is(e.err, storage.ErrURLNotFound) {
+		return e.passthrough, nil
+	}
+	link, err := c.source.GetLink(alias)
+	if err != nil {
+		return storage.Passthrough{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link.Passthrough, nil
+}
+
+// DeleteURL deletes the link in the source and drops it from the cache.
+func (c *Cache) DeleteURL(alias, creator string) (bool, error) {
+	defer c.Invalidate(alias)
+	return c.source.DeleteURL(alias, creator)
+}
+
+// UpdateURL updates the link in the source and drops it from the cache.
+func (c *Cache) UpdateURL(alias, creator string, upd storage.LinkUpdate) (storage.Link, error) {
+	defer c.Invalidate(alias)
+	return c.source.UpdateURL(alias, creator, upd)
+}
+
+// RestoreURL takes the link out of the trash in the source and drops the cached miss of it.
+func (c *Cache) RestoreURL(alias, creator string) (storage.Link, error) {
+	defer c.Invalidate(alias)
+	return c.source.RestoreURL(alias, creator)
+}
+
+// Invalidate drops alias from the cache, a lookup of it that is in flight won't be cached.
+func (c *Cache) Invalidate(alias string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.group.Forget(alias)
+	if el, ok := c.items[alias]; ok {
+		c.lru.Remove(el)
+		delete(c.items, alias)
+	}
+}
+
+// Stats returns the number of cache hits and misses since start.
+func (c *Cache) Stats() (hits, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) get(alias string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[alias]
+	if !ok {
+		return entry{}, false
+	}
+	e := el.Value.(entry)
+	if !c.now().Before(e.expiresAt) {
+		c.lru.Remove(el)
+		delete(c.items, alias)
+		return entry{}, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+func (c *Cache) set(e entry, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if version != c.version {
+		return
+	}
+	if el, ok := c.items[e.alias]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.items[e.alias] = c.lru.PushFront(e)
+	for c.lru.Len() > c.cfg.Size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(entry).alias)
+	}
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+// countingSource counts lookups and can hold them until release is closed.
+type countingSource struct {
+	*memory.Storage
+	lookups atomic.Int64
+	release chan struct{}
+}
+
+func (s *countingSource) GetLink(alias string) (storage.Link, error) {
+	s.lookups.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	return s.Storage.GetLink(alias)
+}
+
+func newTestCache(t *testing.T, cfg config.Cache) (*Cache, *countingSource) {
+	source := &countingSource{Storage: memory.NewStorage()}
+	_, err := source.SaveURL(storage.Link{Alias: "google", URL: "https://google.com", Creator: "owner"})
+	require.NoError(t, err)
+	return New(source, cfg), source
+}
+
+func TestCache_HitMissAndTTL(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 10, TTL: time.Minute, NegativeTTL: time.Second})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		url, err := c.GetURL("google")
+		require.NoError(t, err)
+		require.Equal(t, "https://google.com", url)
+	}
+	hits, misses := c.Stats()
+	require.EqualValues(t, 2, hits)
+	require.EqualValues(t, 1, misses)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetURL("missing")
+		require.ErrorIs(t, err, storage.ErrURLNotFound)
+	}
+	require.EqualValues(t, 2, source.lookups.Load())
+
+	now = now.Add(2 * time.Second)
+	_, err := c.GetURL("missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	require.EqualValues(t, 3, source.lookups.Load(), "negative entry must expire after NegativeTTL")
+
+	now = now.Add(time.Minute)
+	_, err = c.GetURL("google")
+	require.NoError(t, err)
+	require.EqualValues(t, 4, source.lookups.Load(), "entry must expire after TTL")
+}
+
+func TestCache_LinkExpiration(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 10, TTL: time.Hour, NegativeTTL: time.Second})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	expiresAt := now.Add(time.Minute)
+	_, err := source.SaveURL(storage.Link{Alias: "sale", URL: "https://google.com", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	_, err = c.GetURL("sale")
+	require.NoError(t, err)
+
+	now = now.Add(2 * time.Minute)
+	_, err = c.GetURL("sale")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+}
+
+func TestCache_Activation(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 10, TTL: time.Hour, NegativeTTL: time.Second})
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	notBefore, switchAt := now.Add(time.Minute), now.Add(3*time.Minute)
+	_, err := source.SaveURL(storage.Link{
+		Alias: "launch", URL: "https://example.com/a", NotBefore: &notBefore, FallbackURL: "https://example.com/soon",
+		Schedule: storage.Schedule{{At: switchAt, URL: "https://example.com/b"}},
+	})
+	require.NoError(t, err)
+
+	url, err := c.GetURL("launch")
+	require.ErrorIs(t, err, storage.ErrURLNotStarted)
+	require.Equal(t, "https://example.com/soon", url)
+
+	now = now.Add(2 * time.Minute)
+	url, err = c.GetURL("launch")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/a", url, "entry must not outlive the activation")
+
+	now = now.Add(2 * time.Minute)
+	url, err = c.GetURL("launch")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/b", url, "entry must not outlive a scheduled change")
+}
+
+func TestCache_Eviction(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 2, TTL: time.Minute, NegativeTTL: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		_, _ = c.GetURL(fmt.Sprintf("alias%d", i))
+	}
+	require.Equal(t, 2, c.Len())
+
+	_, _ = c.GetURL("alias0")
+	require.EqualValues(t, 4, source.lookups.Load(), "least recently used alias must be evicted")
+}
+
+func TestCache_Invalidation(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+
+	_, err := c.GetURL("google")
+	require.NoError(t, err)
+
+	target := "https://bing.com"
+	_, err = c.UpdateURL("google", "owner", storage.LinkUpdate{URL: &target})
+	require.NoError(t, err)
+	url, err := c.GetURL("google")
+	require.NoError(t, err)
+	require.Equal(t, target, url)
+
+	_, err = c.DeleteURL("google", "owner")
+	require.NoError(t, err)
+	_, err = c.GetURL("google")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// the miss cached while the link was in the trash must not outlive the restore
+	_, err = c.RestoreURL("google", "owner")
+	require.NoError(t, err)
+	url, err = c.GetURL("google")
+	require.NoError(t, err)
+	require.Equal(t, target, url)
+}
+
+func TestCache_Protected(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 10, TTL: time.Minute, NegativeTTL: time.Second})
+	_, err := source.SaveURL(storage.Link{Alias: "docs", URL: "https://example.com", Creator: "owner", Password: "secret"})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		url, err := c.GetURL("docs")
+		require.ErrorIs(t, err, storage.ErrURLProtected)
+		require.Empty(t, url)
+	}
+	require.EqualValues(t, 1, source.lookups.Load())
+
+	// click-limited links never get their target cached, every redirect has to count
+	once := int64(1)
+	_, err = source.SaveURL(storage.Link{Alias: "once", URL: "https://example.com", Creator: "owner", ClicksLeft: &once})
+	require.NoError(t, err)
+	url, err := c.GetURL("once")
+	require.ErrorIs(t, err, storage.ErrURLLimited)
+	require.Empty(t, url)
+}
+
+func TestCache_Passthrough(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 10, TTL: time.Minute, NegativeTTL: time.Second})
+	_, err := source.SaveURL(storage.Link{Alias: "docs", URL: "https://example.com/manual/", Creator: "owner",
+		Passthrough: storage.Passthrough{Path: true}})
+	require.NoError(t, err)
+
+	// a lookup after GetURL is answered by its entry
+	_, err = c.GetURL("docs")
+	require.NoError(t, err)
+	p, err := c.Passthrough("docs")
+	require.NoError(t, err)
+	require.Equal(t, storage.Passthrough{Path: true}, p)
+	require.EqualValues(t, 1, source.lookups.Load())
+
+	p, err = c.Passthrough("google")
+	require.NoError(t, err)
+	require.Equal(t, storage.Passthrough{}, p)
+	require.EqualValues(t, 2, source.lookups.Load())
+
+	_, err = c.Passthrough("missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	c, source := newTestCache(t, config.Cache{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+	source.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			url, err := c.GetURL("google")
+			require.NoError(t, err)
+			require.Equal(t, "https://google.com", url)
+		}()
+	}
+	require.Eventually(t, func() bool { return source.lookups.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+
+	require.EqualValues(t, 1, source.lookups.Load())
+}
@@ -0,0 +1,90 @@
+package storage
+
+import "time"
+
+// Click is a single resolved redirect of a short link.
+type Click struct {
+	Alias     string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	IP        string
+}
+
+const (
+	DimensionReferrer  = "referrer"
+	DimensionUserAgent = "user_agent"
+)
+
+// ClickBucket is the number of clicks in the time bucket starting at Start.
+type ClickBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// ValueCount is the number of clicks that share a referrer or a user agent.
+type ValueCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// ClickStats aggregates clicks of a link over a time range.
+type ClickStats struct {
+	Total          int64
+	UniqueVisitors int64
+	TopReferrers   []ValueCount
+	TopUserAgents  []ValueCount
+	Hourly         []ClickBucket // only hours with clicks, ordered by Start
+}
+
+// HourlyRollup is the number of clicks on Alias during the hour starting at Hour.
+type HourlyRollup struct {
+	Alias  string
+	Hour   time.Time
+	Clicks int64
+}
+
+// DimensionRollup is the number of clicks on Alias during Day with the given referrer or user agent.
+type DimensionRollup struct {
+	Alias     string
+	Day       time.Time
+	Dimension string
+	Value     string
+	Clicks    int64
+}
+
+// RollupClicks aggregates a batch of clicks into hourly counts and daily referrer/user agent counts,
+// all in UTC. Storages add them to their rollup tables when saving the batch.
+func RollupClicks(clicks []Click) ([]HourlyRollup, []DimensionRollup) {
+	hourly := make(map[HourlyRollup]int64)
+	dimensions := make(map[DimensionRollup]int64)
+	for _, c := range clicks {
+		at := c.ClickedAt.UTC()
+		hourly[HourlyRollup{Alias: c.Alias, Hour: at.Truncate(time.Hour)}]++
+
+		day, _ := DayRange(at, at.Add(time.Nanosecond))
+		dimensions[DimensionRollup{Alias: c.Alias, Day: day, Dimension: DimensionReferrer, Value: c.Referrer}]++
+		dimensions[DimensionRollup{Alias: c.Alias, Day: day, Dimension: DimensionUserAgent, Value: c.UserAgent}]++
+	}
+
+	hourlyRollups := make([]HourlyRollup, 0, len(hourly))
+	for key, count := range hourly {
+		key.Clicks = count
+		hourlyRollups = append(hourlyRollups, key)
+	}
+	dimensionRollups := make([]DimensionRollup, 0, len(dimensions))
+	for key, count := range dimensions {
+		key.Clicks = count
+		dimensionRollups = append(dimensionRollups, key)
+	}
+	return hourlyRollups, dimensionRollups
+}
+
+// DayRange returns the first and the last UTC day touched by [from, to).
+func DayRange(from, to time.Time) (time.Time, time.Time) {
+	day := func(t time.Time) time.Time {
+		t = t.UTC()
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return day(from), day(to.Add(-time.Nanosecond))
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Collection is a named group of links of Owner. Names are unique per owner.
+type Collection struct {
+	ID          string
+	Owner       string
+	Name        string
+	Description string
+	CreatedAt   time.Time
+}
+
+// CollectionSummary is a collection together with the number of its live links.
+type CollectionSummary struct {
+	Collection
+	Links int64
+}
+
+// CollectionUpdate lists the fields of a collection to change, nil fields are left as they are.
+type CollectionUpdate struct {
+	Name        *string
+	Description *string
+}
+
+// CollectionStats aggregates clicks on the live links of a collection over a time range.
+type CollectionStats struct {
+	Links    int64
+	Total    int64
+	TopLinks []ValueCount  // aliases with the most clicks in the range
+	Hourly   []ClickBucket // only hours with clicks, ordered by Start
+}
+
+// NormalizeTags returns tags trimmed, lower case, sorted and without empty ones or duplicates.
+func NormalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			normalized = append(normalized, tag)
+		}
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// HasTags reports whether the link carries all of tags.
+func (l Link) HasTags(tags []string) bool {
+	for _, tag := range tags {
+		if !slices.Contains(l.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// Domain is a custom host of Owner that serves its own aliases. Links of the default domain,
+// any host that is not registered, have an empty Link.Domain.
+//
+// A domain serves nothing and takes no links until it is verified, its owner proving control of
+// the host. Until then it is only a claim: another user may take it over by verifying first.
+type Domain struct {
+	Host       string
+	Owner      string
+	CreatedAt  time.Time
+	VerifiedAt *time.Time
+}
+
+// Verified reports whether the owner proved control of the host.
+func (d Domain) Verified() bool {
+	return d.VerifiedAt != nil
+}
+
+// Key identifies a link among all domains: its alias on the default domain, "host/alias" on a
+// custom one. Aliases can't contain "/". Storages take the key wherever they take an alias of an
+// existing link, and clicks are recorded under it.
+func Key(domain, alias string) string {
+	if domain == "" {
+		return alias
+	}
+	return domain + "/" + alias
+}
+
+// SplitKey returns the domain and the alias of a link key.
+func SplitKey(key string) (domain, alias string) {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// Key returns the key of the link, see Key.
+func (l Link) Key() string {
+	return Key(l.Domain, l.Alias)
+}
+
+// NormalizeHost returns host lower case, without a port and a trailing dot, the form domains are stored in.
+func NormalizeHost(host string) string {
+	host = strings.TrimSpace(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"golang.org/x/crypto/bcrypt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Link is a short link row of the url table.
+type Link struct {
+	ID        string
+	Alias     string
+	URL       string
+	Creator   string
+	CreatedAt time.Time
+	ExpiresAt *time.Time // nil means the link never expires
+	DeletedAt *time.Time // set while the link is in the trash
+	// Password opens a protected link. It is plain text when saving, the storage hashes it like
+	// user passwords; links read back carry the bcrypt hash. Empty for links anyone can open.
+	Password string
+	// ClicksLeft counts down the redirects of a click-limited link, nil for links without a limit.
+	ClicksLeft *int64
+	// NotBefore and NotAfter bound when the link redirects, nil for no bound. Outside of them it
+	// sends visitors to FallbackURL, if there is one.
+	NotBefore   *time.Time
+	NotAfter    *time.Time
+	FallbackURL string
+	Schedule    Schedule // changes of the destination over time, URL is the one before the first
+	// Tags label the link, see NormalizeTags. CollectionID is the collection of the creator the link
+	// belongs to, empty for none.
+	Tags         []string
+	CollectionID string
+	// Domain is the custom host the alias belongs to, empty for the default domain. Aliases are
+	// unique per domain, see Key.
+	Domain string
+	// Passthrough is what redirects forward to the target, the query string and the path after
+	// the alias.
+	Passthrough Passthrough
+}
+
+// Expired reports whether the link has expired at the given moment.
+func (l Link) Expired(now time.Time) bool {
+	return l.ExpiresAt != nil && !now.Before(*l.ExpiresAt)
+}
+
+// Target returns the url a redirect at now goes to, or why it can't go there directly: ErrURLExpired,
+// ErrURLNotStarted or ErrURLEnded together with the fallback url, ErrURLExhausted, ErrURLProtected
+// for a password, or ErrURLLimited when the redirect must consume one of the link's clicks.
+func (l Link) Target(now time.Time) (string, error) {
+	switch {
+	case l.Expired(now):
+		return "", ErrURLExpired
+	case l.NotBefore != nil && now.Before(*l.NotBefore):
+		return l.FallbackURL, ErrURLNotStarted
+	case l.NotAfter != nil && !now.Before(*l.NotAfter):
+		return l.FallbackURL, ErrURLEnded
+	case l.Exhausted():
+		return "", ErrURLExhausted
+	case l.Protected():
+		return "", ErrURLProtected
+	case l.Limited():
+		return "", ErrURLLimited
+	}
+	return l.Destination(now), nil
+}
+
+// Destination returns the url the link points to at now, following its schedule.
+func (l Link) Destination(now time.Time) string {
+	url := l.URL
+	for _, change := range l.Schedule {
+		if now.Before(change.At) {
+			break
+		}
+		url = change.URL
+	}
+	return url
+}
+
+// NextChange returns the first moment after now when a redirect of the link may go elsewhere:
+// the end of its activation window, its expiration or a scheduled destination change. nil if there
+// is none.
+func (l Link) NextChange(now time.Time) *time.Time {
+	var next *time.Time
+	consider := func(t *time.Time) {
+		if t != nil && t.After(now) && (next == nil || t.Before(*next)) {
+			next = t
+		}
+	}
+	consider(l.NotBefore)
+	consider(l.NotAfter)
+	consider(l.ExpiresAt)
+	for i := range l.Schedule {
+		consider(&l.Schedule[i].At)
+	}
+	return next
+}
+
+// Scheduled reports whether the link has an activation window or destination changes.
+func (l Link) Scheduled() bool {
+	return l.NotBefore != nil || l.NotAfter != nil || len(l.Schedule) > 0
+}
+
+// Limited reports whether redirects must go through ConsumeClick.
+func (l Link) Limited() bool {
+	return l.ClicksLeft != nil
+}
+
+// Exhausted reports whether a click-limited link has no redirects left.
+func (l Link) Exhausted() bool {
+	return l.ClicksLeft != nil && *l.ClicksLeft <= 0
+}
+
+// Protected reports whether the link needs a password.
+func (l Link) Protected() bool {
+	return l.Password != ""
+}
+
+// CheckPassword reports whether password opens a link read back from storage.
+func (l Link) CheckPassword(password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(l.Password), []byte(password)) == nil
+}
+
+// HashPassword returns the bcrypt hash of a link password to store, nil for an empty one.
+func HashPassword(password string) (*string, error) {
+	if password == "" {
+		return nil, nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	h := string(hash)
+	return &h, nil
+}
+
+// LinkUpdate lists the fields of a link to change, nil fields are left as they are.
+type LinkUpdate struct {
+	URL            *string
+	ExpiresAt      *time.Time
+	ClearExpiresAt bool // make the link never expire, takes precedence over ExpiresAt
+	Tags           *[]string
+	CollectionID   *string // empty takes the link out of its collection
+	ForwardQuery   *bool
+	ForwardPath    *bool
+}
+
+// Apply returns the link with the update applied.
+func (u LinkUpdate) Apply(link Link) Link {
+	if u.URL != nil {
+		link.URL = *u.URL
+	}
+	if u.ClearExpiresAt {
+		link.ExpiresAt = nil
+	} else if u.ExpiresAt != nil {
+		link.ExpiresAt = u.ExpiresAt
+	}
+	if u.Tags != nil {
+		link.Tags = *u.Tags
+	}
+	if u.CollectionID != nil {
+		link.CollectionID = *u.CollectionID
+	}
+	if u.ForwardQuery != nil {
+		link.Passthrough.Query = *u.ForwardQuery
+	}
+	if u.ForwardPath != nil {
+		link.Passthrough.Path = *u.ForwardPath
+	}
+	return link
+}
+
+// NormalizeURL returns the form of a destination used to spot duplicates: lower case scheme and
+// host, no default port, no fragment, "/" for an empty path and sorted query parameters. Unparsable
+// urls are returned as they are.
+func NormalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawPath = ""
+	u.Fragment, u.RawFragment = "", ""
+	// Encode sorts by key and keeps the order of repeated keys
+	u.RawQuery = u.Query().Encode()
+	u.ForceQuery = false
+	return u.String()
+}
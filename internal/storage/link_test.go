@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cases := map[string]string{
+		"https://Example.COM":                    "https://example.com/",
+		"HTTPS://example.com:443/Path?b=2&a=1#x": "https://example.com/Path?a=1&b=2",
+		"http://example.com:80/a?":               "http://example.com/a",
+		"http://example.com:8080/a":              "http://example.com:8080/a",
+		"http://[::1]:80/":                       "http://[::1]/",
+		"not a url":                              "not a url",
+	}
+	for raw, want := range cases {
+		require.Equal(t, want, NormalizeURL(raw), raw)
+	}
+}
+
+func TestLinkTarget(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	link := Link{
+		URL:         "https://example.com/a",
+		FallbackURL: "https://example.com/fallback",
+		Schedule: Schedule{
+			{At: before, URL: "https://example.com/b"},
+			{At: after, URL: "https://example.com/c"},
+		},
+	}
+
+	url, err := link.Target(now)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/b", url)
+	require.Equal(t, "https://example.com/a", link.Destination(before.Add(-time.Second)))
+	require.Equal(t, "https://example.com/c", link.Destination(after))
+	require.Equal(t, after, *link.NextChange(now))
+
+	notStarted := link
+	notStarted.NotBefore = &after
+	url, err = notStarted.Target(now)
+	require.ErrorIs(t, err, ErrURLNotStarted)
+	require.Equal(t, "https://example.com/fallback", url)
+
+	ended := link
+	ended.NotAfter = &before
+	url, err = ended.Target(now)
+	require.ErrorIs(t, err, ErrURLEnded)
+	require.Equal(t, "https://example.com/fallback", url)
+	require.Nil(t, ended.NextChange(after))
+}
+
+func TestPassthroughForward(t *testing.T) {
+	query := url.Values{"v": {"3"}, "q": {"a b"}, "tag": {"x", "y"}}
+	cases := []struct {
+		p      Passthrough
+		target string
+		query  url.Values
+		suffix string
+		want   string
+	}{
+		{Passthrough{}, "https://example.com/a?v=1", query, "api/v2", "https://example.com/a?v=1"},
+		// the target's own parameters win, the others are appended
+		{Passthrough{Query: true}, "https://example.com/a?v=1#top", query, "", "https://example.com/a?v=1&q=a+b&tag=x&tag=y#top"},
+		{Passthrough{Query: true}, "https://example.com", query, "", "https://example.com?q=a+b&tag=x&tag=y&v=3"},
+		{Passthrough{Path: true}, "https://example.com/manual/", nil, "api/v2", "https://example.com/manual/api/v2"},
+		{Passthrough{Path: true}, "https://example.com/manual?lang=en", nil, "api/v2/", "https://example.com/manual/api/v2/?lang=en"},
+		{Passthrough{Path: true}, "https://example.com", nil, "a b/../../etc", "https://example.com/a%20b/etc"},
+		{Passthrough{Path: true}, "https://example.com/a", query, "b", "https://example.com/a/b"},
+		{Passthrough{Query: true, Path: true}, "https://example.com/docs/", url.Values{"page": {"2"}}, "api", "https://example.com/docs/api?page=2"},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, tc.p.Forward(tc.target, tc.query, tc.suffix), "%+v %s %s", tc.p, tc.target, tc.suffix)
+	}
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SortCreatedAt = "createdAt"
+	SortClicks    = "clicks"
+)
+
+// ListQuery selects a page of links of Creator. Pages are keyset paginated on the
+// sort column and the link id.
+type ListQuery struct {
+	Creator     string
+	SortBy      string // SortCreatedAt or SortClicks
+	Desc        bool
+	Domain      string     // case-insensitive substring of the target host
+	CreatedFrom *time.Time // inclusive
+	CreatedTo   *time.Time // exclusive
+	After       *ListCursor
+	Limit       int
+	Deleted     bool     // list the trash instead of live links
+	Tags        []string // links carrying all of them, normalized
+	Collection  string   // id of the collection the links belong to
+}
+
+// ListCursor is the position of the last link of the previous page.
+type ListCursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	Clicks    int64     `json:"clicks"`
+	ID        string    `json:"id"`
+}
+
+// LinkSummary is a link together with its total click count.
+type LinkSummary struct {
+	Link
+	Clicks int64
+}
+
+func (l LinkSummary) Cursor() ListCursor {
+	return ListCursor{CreatedAt: l.CreatedAt, Clicks: l.Clicks, ID: l.ID}
+}
+
+// TargetHost returns the lowercased host of a target url, empty if it can't be parsed.
+func TargetHost(target string) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// SaveClicks appends click events to the in-memory log.
+func (s *Storage) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks = append(s.clicks, clicks...)
+	return nil
+}
+
+// ClickStats aggregates clicks on alias in [from, to). Unlike the sql storages there are no
+// rollup tables, everything is computed from the raw click log.
+func (s *Storage) ClickStats(_ context.Context, alias string, from, to time.Time, top int) (storage.ClickStats, error) {
+	s.mu.RLock()
+	var clicks []storage.Click
+	for _, c := range s.clicks {
+		if c.Alias == alias && !c.ClickedAt.Before(from) && c.ClickedAt.Before(to) {
+			clicks = append(clicks, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	var stats storage.ClickStats
+	visitors := make(map[[2]string]struct{})
+	for _, c := range clicks {
+		visitors[[2]string{c.IP, c.UserAgent}] = struct{}{}
+	}
+	stats.Total = int64(len(clicks))
+	stats.UniqueVisitors = int64(len(visitors))
+
+	hourly, dimensions := storage.RollupClicks(clicks)
+	for _, h := range hourly {
+		stats.Hourly = append(stats.Hourly, storage.ClickBucket{Start: h.Hour, Clicks: h.Clicks})
+	}
+	sort.Slice(stats.Hourly, func(i, j int) bool { return stats.Hourly[i].Start.Before(stats.Hourly[j].Start) })
+
+	byValue := map[string]map[string]int64{
+		storage.DimensionReferrer:  {},
+		storage.DimensionUserAgent: {},
+	}
+	for _, d := range dimensions {
+		byValue[d.Dimension][d.Value] += d.Clicks
+	}
+	stats.TopReferrers = topValues(byValue[storage.DimensionReferrer], top)
+	stats.TopUserAgents = topValues(byValue[storage.DimensionUserAgent], top)
+
+	return stats, nil
+}
+
+func topValues(counts map[string]int64, top int) []storage.ValueCount {
+	values := make([]storage.ValueCount, 0, len(counts))
+	for value, clicks := range counts {
+		values = append(values, storage.ValueCount{Value: value, Clicks: clicks})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Clicks != values[j].Clicks {
+			return values[i].Clicks > values[j].Clicks
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > top {
+		values = values[:top]
+	}
+	return values
+}
+
+// dropClicks forgets the clicks of a purged link, so the next link to take its alias starts without
+// stats. Callers must hold s.mu.
+func (s *Storage) dropClicks(alias string) {
+	kept := s.clicks[:0]
+	for _, c := range s.clicks {
+		if c.Alias != alias {
+			kept = append(kept, c)
+		}
+	}
+	s.clicks = kept
+}
@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"sort"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// CreateCollection saves a new collection and returns it with its id, storage.ErrCollectionExists
+// if the owner has one of the same name.
+func (s *Storage) CreateCollection(c storage.Collection) (storage.Collection, error) {
+	const info = "storage.memory.CreateCollection"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.collectionNamed(c.Owner, c.Name) != "" {
+		return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, c.Name, storage.ErrCollectionExists)
+	}
+	c.ID = uuid.New().String()
+	c.CreatedAt = time.Now()
+	s.collections[c.ID] = c
+	return c, nil
+}
+
+// ListCollections returns the collections of owner ordered by name, with the number of their live links.
+func (s *Storage) ListCollections(owner string) ([]storage.CollectionSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var collections []storage.CollectionSummary
+	for _, c := range s.collections {
+		if c.Owner != owner {
+			continue
+		}
+		summary := storage.CollectionSummary{Collection: c}
+		for _, link := range s.urls {
+			if link.CollectionID == c.ID && link.DeletedAt == nil {
+				summary.Links++
+			}
+		}
+		collections = append(collections, summary)
+	}
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+	return collections, nil
+}
+
+// GetCollection returns the collection id of owner, storage.ErrCollectionNotFound if owner has no such collection.
+func (s *Storage) GetCollection(id, owner string) (storage.Collection, error) {
+	const info = "storage.memory.GetCollection"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c, ok := s.collections[id]
+	if !ok || c.Owner != owner {
+		return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+	}
+	return c, nil
+}
+
+// UpdateCollection changes the name or description of the collection id of owner and returns it.
+func (s *Storage) UpdateCollection(id, owner string, upd storage.CollectionUpdate) (storage.Collection, error) {
+	const info = "storage.memory.UpdateCollection"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok || c.Owner != owner {
+		return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+	}
+	if upd.Name != nil {
+		if other := s.collectionNamed(owner, *upd.Name); other != "" && other != id {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, *upd.Name, storage.ErrCollectionExists)
+		}
+		c.Name = *upd.Name
+	}
+	if upd.Description != nil {
+		c.Description = *upd.Description
+	}
+	s.collections[id] = c
+	return c, nil
+}
+
+// DeleteCollection deletes the collection id of owner, its links stay without a collection.
+func (s *Storage) DeleteCollection(id, owner string) (bool, error) {
+	const info = "storage.memory.DeleteCollection"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.collections[id]
+	if !ok || c.Owner != owner {
+		return false, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+	}
+	delete(s.collections, id)
+	for alias, link := range s.urls {
+		if link.CollectionID == id {
+			link.CollectionID = ""
+			s.urls[alias] = link
+		}
+	}
+	return true, nil
+}
+
+// CollectionStats aggregates clicks on the live links of collection id in [from, to) from the raw click log.
+func (s *Storage) CollectionStats(_ context.Context, id string, from, to time.Time, top int) (storage.CollectionStats, error) {
+	var stats storage.CollectionStats
+
+	s.mu.RLock()
+	members := make(map[string]struct{})
+	for alias, link := range s.urls {
+		if link.CollectionID == id && link.DeletedAt == nil {
+			members[alias] = struct{}{}
+		}
+	}
+	var clicks []storage.Click
+	for _, c := range s.clicks {
+		if _, ok := members[c.Alias]; ok && !c.ClickedAt.Before(from) && c.ClickedAt.Before(to) {
+			clicks = append(clicks, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	stats.Links = int64(len(members))
+	stats.Total = int64(len(clicks))
+	hourly := make(map[time.Time]int64)
+	byAlias := make(map[string]int64)
+	for _, c := range clicks {
+		hourly[c.ClickedAt.UTC().Truncate(time.Hour)]++
+		byAlias[c.Alias]++
+	}
+	for start, count := range hourly {
+		stats.Hourly = append(stats.Hourly, storage.ClickBucket{Start: start, Clicks: count})
+	}
+	sort.Slice(stats.Hourly, func(i, j int) bool { return stats.Hourly[i].Start.Before(stats.Hourly[j].Start) })
+	stats.TopLinks = topValues(byAlias, top)
+	return stats, nil
+}
+
+// collectionNamed returns the id of the collection of owner called name, empty if there is none.
+// Callers must hold s.mu.
+func (s *Storage) collectionNamed(owner, name string) string {
+	for id, c := range s.collections {
+		if c.Owner == owner && c.Name == name {
+			return id
+		}
+	}
+	return ""
+}
+
+// checkCollection makes sure links of creator can be put in collection id, an empty id always passes.
+// Callers must hold s.mu.
+func (s *Storage) checkCollection(id, creator string) error {
+	if id == "" {
+		return nil
+	}
+	if c, ok := s.collections[id]; !ok || c.Owner != creator {
+		return fmt.Errorf("%s, %w", id, storage.ErrCollectionNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// CreateDomain claims a custom host for the owner, unverified. Unverified claims of other users
+// without links are taken over, storage.ErrDomainExists if the host is taken otherwise.
+func (s *Storage) CreateDomain(d storage.Domain) (storage.Domain, error) {
+	const info = "storage.memory.CreateDomain"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.Host = storage.NormalizeHost(d.Host)
+	if old, ok := s.domains[d.Host]; ok && (old.Owner == d.Owner || !s.claimable(old)) {
+		return storage.Domain{}, fmt.Errorf("%s: %s, %w", info, d.Host, storage.ErrDomainExists)
+	}
+	d.CreatedAt = time.Now()
+	d.VerifiedAt = nil
+	s.domains[d.Host] = d
+	return d, nil
+}
+
+// VerifyDomain marks host verified for owner, once owner proved control of it. A host that is not
+// claimed yet or claimed but unverified by someone else without links becomes owner's,
+// storage.ErrDomainExists if another user holds it.
+func (s *Storage) VerifyDomain(host, owner string) (storage.Domain, error) {
+	const info = "storage.memory.VerifyDomain"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host = storage.NormalizeHost(host)
+	d, ok := s.domains[host]
+	if ok && d.Owner != owner && !s.claimable(d) {
+		return storage.Domain{}, fmt.Errorf("%s: %s, %w", info, host, storage.ErrDomainExists)
+	}
+	now := time.Now()
+	if !ok || d.Owner != owner {
+		d = storage.Domain{Host: host, Owner: owner, CreatedAt: now}
+	}
+	if d.VerifiedAt == nil {
+		d.VerifiedAt = &now
+	}
+	s.domains[host] = d
+	return d, nil
+}
+
+// claimable reports whether another user may take d over: unverified and without links, which only
+// domains registered before verification existed can have. Callers must hold s.mu.
+func (s *Storage) claimable(d storage.Domain) bool {
+	if d.Verified() {
+		return false
+	}
+	for _, link := range s.urls {
+		if link.Domain == d.Host {
+			return false
+		}
+	}
+	return true
+}
+
+// ListDomains returns the domains of owner ordered by host.
+func (s *Storage) ListDomains(owner string) ([]storage.Domain, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var domains []storage.Domain
+	for _, d := range s.domains {
+		if d.Owner == owner {
+			domains = append(domains, d)
+		}
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Host < domains[j].Host })
+	return domains, nil
+}
+
+// DeleteDomain removes the domain host of owner. Domains with links, trashed ones included, are kept
+// with storage.ErrDomainInUse: deleting it would hand their aliases over to the default domain.
+func (s *Storage) DeleteDomain(host, owner string) (bool, error) {
+	const info = "storage.memory.DeleteDomain"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	host = storage.NormalizeHost(host)
+	if d, ok := s.domains[host]; !ok || d.Owner != owner {
+		return false, fmt.Errorf("%s: %s, %w", info, host, storage.ErrDomainNotFound)
+	}
+	for _, link := range s.urls {
+		if link.Domain == host {
+			return false, fmt.Errorf("%s: %s, %w", info, host, storage.ErrDomainInUse)
+		}
+	}
+	delete(s.domains, host)
+	return true, nil
+}
+
+// DomainHosts returns the hosts of all verified domains.
+func (s *Storage) DomainHosts(_ context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hosts := make([]string, 0, len(s.domains))
+	for host, d := range s.domains {
+		if d.Verified() {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts, nil
+}
+
+// checkDomain makes sure links of creator can be put on domain: owned by creator and verified. The
+// default domain always passes.
+// Callers must hold s.mu.
+func (s *Storage) checkDomain(domain, creator string) error {
+	if domain == "" {
+		return nil
+	}
+	d, ok := s.domains[domain]
+	if !ok || d.Owner != creator {
+		return fmt.Errorf("%s, %w", domain, storage.ErrDomainNotFound)
+	}
+	if !d.Verified() {
+		return fmt.Errorf("%s, %w", domain, storage.ErrDomainNotVerified)
+	}
+	return nil
+}
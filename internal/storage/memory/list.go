@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"url_shortener/internal/storage"
+)
+
+// ListURLs returns a page of the creator's links with their click counts.
+func (s *Storage) ListURLs(_ context.Context, q storage.ListQuery) ([]storage.LinkSummary, error) {
+	s.mu.RLock()
+	clicks := make(map[string]int64)
+	for _, c := range s.clicks {
+		clicks[c.Alias]++
+	}
+	var links []storage.LinkSummary
+	for _, link := range s.urls {
+		if link.Creator != q.Creator || (link.DeletedAt != nil) != q.Deleted {
+			continue
+		}
+		if q.Domain != "" && !strings.Contains(storage.TargetHost(link.URL), strings.ToLower(q.Domain)) {
+			continue
+		}
+		if !link.HasTags(q.Tags) || (q.Collection != "" && link.CollectionID != q.Collection) {
+			continue
+		}
+		if q.CreatedFrom != nil && link.CreatedAt.Before(*q.CreatedFrom) {
+			continue
+		}
+		if q.CreatedTo != nil && !link.CreatedAt.Before(*q.CreatedTo) {
+			continue
+		}
+		links = append(links, storage.LinkSummary{Link: link, Clicks: clicks[link.Key()]})
+	}
+	s.mu.RUnlock()
+
+	// less reports whether a goes before b in ascending order
+	less := func(a, b storage.ListCursor) bool {
+		if q.SortBy == storage.SortClicks && a.Clicks != b.Clicks {
+			return a.Clicks < b.Clicks
+		}
+		if q.SortBy != storage.SortClicks && !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+	before := func(a, b storage.ListCursor) bool {
+		if q.Desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	}
+	sort.Slice(links, func(i, j int) bool { return before(links[i].Cursor(), links[j].Cursor()) })
+
+	page := make([]storage.LinkSummary, 0, q.Limit)
+	for _, link := range links {
+		if q.After != nil && !before(*q.After, link.Cursor()) {
+			continue
+		}
+		if len(page) == q.Limit {
+			break
+		}
+		page = append(page, link)
+	}
+	return page, nil
+}
@@ -0,0 +1,391 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"slices"
+	"sync"
+	"time"
+	"url_shortener/httpServer/handlers/login"
+	"url_shortener/internal/storage"
+)
+
+// Storage keeps users and urls in process memory. It is meant for local demos and CI
+// where running Postgres is not an option; everything is lost on restart.
+type Storage struct {
+	mu    sync.RWMutex
+	users map[string]login.User   // keyed by username
+	urls  map[string]storage.Link // keyed by storage.Key
+
+	collections map[string]storage.Collection // keyed by id
+	domains     map[string]storage.Domain     // keyed by host
+
+	archive []storage.Link  // expired links moved out by PurgeExpired
+	clicks  []storage.Click // click events in the order they were saved
+	aliasID int64           // last value handed out by NextAliasID
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		users: make(map[string]login.User),
+		urls:  make(map[string]storage.Link),
+
+		collections: make(map[string]storage.Collection),
+		domains:     make(map[string]storage.Domain),
+	}
+}
+
+// Close is a no-op, it exists so the memory backend can be swapped with the others.
+func (s *Storage) Close() error {
+	return nil
+}
+
+func (s *Storage) CreateUser(user login.User) error {
+	const info = "internal.storage.memory.CreateUser"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("%s: failed to hash pass: %w", info, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[user.Username]; ok {
+		return fmt.Errorf("%s: %s, %w", info, user.Username, storage.ErrUserExists)
+	}
+	user.Password = string(hashedPassword)
+	s.users[user.Username] = user
+	return nil
+}
+
+func (s *Storage) GetUserByUsername(username string) (login.User, error) {
+	const info = "internal.storage.memory.GetUserByUsername"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		// login handler treats sql.ErrNoRows as "invalid username or password"
+		return login.User{}, fmt.Errorf("%s: %w", info, sql.ErrNoRows)
+	}
+	return user, nil
+}
+
+// SaveURL - save link in memory, returns storage.ErrURLExists if the alias is already taken
+func (s *Storage) SaveURL(link storage.Link) (string, error) {
+	const info = "storage.memory.SaveURL"
+
+	if err := hashPassword(&link); err != nil {
+		return "", fmt.Errorf("%s: failed to hash pass: %w", info, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.urls[link.Key()]; ok {
+		return "", fmt.Errorf("%s: %s, %w", info, link.Key(), storage.ErrURLExists)
+	}
+	if err := s.checkCollection(link.CollectionID, link.Creator); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	if err := s.checkDomain(link.Domain, link.Creator); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	link.Tags = storage.NormalizeTags(link.Tags)
+	link.ID = uuid.New().String()
+	link.CreatedAt = time.Now()
+	s.urls[link.Key()] = link
+	return link.ID, nil
+}
+
+// SaveURLs saves all links or none of them. The failing link is reported as a *storage.ItemError.
+func (s *Storage) SaveURLs(_ context.Context, links []storage.Link) ([]string, error) {
+	const info = "storage.memory.SaveURLs"
+
+	links = slices.Clone(links)
+	for i := range links {
+		if err := hashPassword(&links[i]); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]struct{}, len(links))
+	for i, link := range links {
+		_, taken := s.urls[link.Key()]
+		if _, dup := seen[link.Key()]; taken || dup {
+			err := fmt.Errorf("%s, %w", link.Key(), storage.ErrURLExists)
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		seen[link.Key()] = struct{}{}
+		if err := s.checkCollection(link.CollectionID, link.Creator); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		if err := s.checkDomain(link.Domain, link.Creator); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+	}
+	ids := make([]string, len(links))
+	now := time.Now()
+	for i, link := range links {
+		link.ID = uuid.New().String()
+		link.CreatedAt = now
+		link.Tags = storage.NormalizeTags(link.Tags)
+		s.urls[link.Key()] = link
+		ids[i] = link.ID
+	}
+	return ids, nil
+}
+
+// NextAliasID returns the next value of the alias sequence
+func (s *Storage) NextAliasID() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aliasID++
+	return s.aliasID, nil
+}
+
+// GetURL returns the target of alias, storage.ErrURLExpired if the link has expired but is not purged yet.
+// Links in the trash are not found, links that can't redirect right away give the errors of storage.Link.Target.
+func (s *Storage) GetURL(alias string) (string, error) {
+	const info = "storage.memory.GetURL"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.live(alias)
+	if !ok {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLNotFound)
+	}
+	url, err := link.Target(time.Now())
+	if err != nil {
+		// the fallback url of links outside their activation window comes along with the error
+		return url, fmt.Errorf("%s: %s, %w", info, alias, err)
+	}
+	return url, nil
+}
+
+// Passthrough returns what redirects of alias forward to its target, see storage.Passthrough.
+func (s *Storage) Passthrough(alias string) (storage.Passthrough, error) {
+	const info = "storage.memory.Passthrough"
+	link, err := s.GetLink(alias)
+	if err != nil {
+		return storage.Passthrough{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link.Passthrough, nil
+}
+
+// ConsumeClick takes one of the clicks left of a click-limited link and returns its target,
+// storage.ErrURLExhausted if none are left. Links without a limit just return their target.
+func (s *Storage) ConsumeClick(alias string) (string, error) {
+	const info = "storage.memory.ConsumeClick"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.live(alias)
+	if !ok {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLNotFound)
+	}
+	if link.Expired(time.Now()) {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLExpired)
+	}
+	if link.Exhausted() {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLExhausted)
+	}
+	if link.Limited() {
+		left := *link.ClicksLeft - 1
+		link.ClicksLeft = &left
+		s.urls[alias] = link
+	}
+	return link.Destination(time.Now()), nil
+}
+
+// GetLink returns the whole link of alias, expired or not. Links in the trash are not found.
+func (s *Storage) GetLink(alias string) (storage.Link, error) {
+	const info = "storage.memory.GetLink"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, ok := s.live(alias)
+	if !ok {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLNotFound)
+	}
+	return link, nil
+}
+
+// LinkByTarget returns the oldest live link of creator whose destination normalizes to the same
+// url as target, storage.ErrURLNotFound if there is none. Protected, click-limited and scheduled
+// links are left out, they can't stand in for a plain one, and so are links of custom domains and
+// links that forward the query string or path.
+func (s *Storage) LinkByTarget(creator, target string) (storage.Link, error) {
+	const info = "storage.memory.LinkByTarget"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	normalized := storage.NormalizeURL(target)
+	now := time.Now()
+	var found *storage.Link
+	for _, link := range s.urls {
+		if link.Creator != creator || link.DeletedAt != nil || link.Protected() || link.Limited() || link.Scheduled() || link.Domain != "" || link.Passthrough != (storage.Passthrough{}) || link.Expired(now) || storage.NormalizeURL(link.URL) != normalized {
+			continue
+		}
+		if found == nil || link.CreatedAt.Before(found.CreatedAt) ||
+			(link.CreatedAt.Equal(found.CreatedAt) && link.ID < found.ID) {
+			found = &link
+		}
+	}
+	if found == nil {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, target, storage.ErrURLNotFound)
+	}
+	return *found, nil
+}
+
+// PurgeExpired removes links that expired before the given moment, keeping them in an
+// in-memory archive when archive is set. Their clicks are dropped, so the next link of an alias
+// starts without stats. It returns the number of removed links.
+func (s *Storage) PurgeExpired(_ context.Context, before time.Time, archive bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for alias, link := range s.urls {
+		if !link.Expired(before) {
+			continue
+		}
+		if archive {
+			s.archive = append(s.archive, link)
+		}
+		delete(s.urls, alias)
+		s.dropClicks(alias)
+		purged++
+	}
+	return purged, nil
+}
+
+// DeleteURL moves the link identified by the alias and creator to the trash. The alias stays taken
+// until PurgeDeleted removes the link.
+func (s *Storage) DeleteURL(alias, creator string) (bool, error) {
+	const info = "storage.memory.DeleteURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.caseDifferent(alias) {
+		return false, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrCaseMismatch)
+	}
+
+	link := s.urls[alias]
+	if link.Creator != creator {
+		return false, fmt.Errorf("%s: no rows found for alias %s and creator %s, %w", info, alias, creator, storage.ErrAliasNotFound)
+	}
+	now := time.Now()
+	link.DeletedAt = &now
+	s.urls[alias] = link
+
+	return true, nil
+}
+
+// UpdateURL changes mutable fields of the link identified by alias and creator and returns the updated link.
+func (s *Storage) UpdateURL(alias, creator string, upd storage.LinkUpdate) (storage.Link, error) {
+	const info = "storage.memory.UpdateURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.caseDifferent(alias) {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrCaseMismatch)
+	}
+	link := s.urls[alias]
+	if link.Creator != creator {
+		return storage.Link{}, fmt.Errorf("%s: no rows found for alias %s and creator %s, %w", info, alias, creator, storage.ErrAliasNotFound)
+	}
+	if upd.CollectionID != nil {
+		if err := s.checkCollection(*upd.CollectionID, creator); err != nil {
+			return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+		}
+	}
+	link = upd.Apply(link)
+	link.Tags = storage.NormalizeTags(link.Tags)
+	s.urls[alias] = link
+
+	return link, nil
+}
+
+// RestoreURL takes the link identified by the alias and creator out of the trash,
+// storage.ErrAliasNotFound if the creator has no such link there.
+func (s *Storage) RestoreURL(alias, creator string) (storage.Link, error) {
+	const info = "storage.memory.RestoreURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.urls[alias]
+	if !ok || link.DeletedAt == nil || link.Creator != creator {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrAliasNotFound)
+	}
+	link.DeletedAt = nil
+	s.urls[alias] = link
+
+	return link, nil
+}
+
+// PurgeDeleted removes links that went to the trash before the given moment, freeing their aliases.
+// Their clicks go with them, so the next link of an alias starts without stats. It returns the
+// number of removed links.
+func (s *Storage) PurgeDeleted(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for alias, link := range s.urls {
+		if link.DeletedAt != nil && link.DeletedAt.Before(before) {
+			delete(s.urls, alias)
+			s.dropClicks(alias)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// CaseDifferent checks if the alias exists in a case-sensitive manner.
+func (s *Storage) CaseDifferent(alias string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.caseDifferent(alias), nil
+}
+
+// caseDifferent mirrors the postgres lookup, where alias comparison is case-sensitive,
+// so an alias stored with different casing is reported as missing. Callers must hold s.mu.
+func (s *Storage) caseDifferent(alias string) bool {
+	_, ok := s.live(alias)
+	return ok
+}
+
+// hashPassword replaces the plain text password of a link to save with its hash.
+func hashPassword(link *storage.Link) error {
+	hash, err := storage.HashPassword(link.Password)
+	if err != nil || hash == nil {
+		return err
+	}
+	link.Password = *hash
+	return nil
+}
+
+// live returns the link of alias unless it is missing or in the trash. Callers must hold s.mu.
+func (s *Storage) live(alias string) (storage.Link, bool) {
+	link, ok := s.urls[alias]
+	if !ok || link.DeletedAt != nil {
+		return storage.Link{}, false
+	}
+	return link, true
+}
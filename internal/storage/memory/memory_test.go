@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"testing"
+	"url_shortener/httpServer/handlers/login"
+	"url_shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestStorage_URLs(t *testing.T) {
+	s := NewStorage()
+
+	_, err := s.SaveURL(storage.Link{URL: "https://google.com", Alias: "google", Creator: "creator"})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(storage.Link{URL: "https://bing.com", Alias: "google", Creator: "creator"})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	url, err := s.GetURL("google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url)
+
+	_, err = s.GetURL("missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = s.DeleteURL("Google", "creator")
+	require.ErrorIs(t, err, storage.ErrCaseMismatch)
+
+	_, err = s.DeleteURL("google", "someone else")
+	require.ErrorIs(t, err, storage.ErrAliasNotFound)
+
+	ok, err := s.DeleteURL("google", "creator")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = s.GetURL("google")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_Users(t *testing.T) {
+	s := NewStorage()
+
+	user := login.User{ID: "1", Username: "user", Password: "password123"}
+	require.NoError(t, s.CreateUser(user))
+	require.ErrorIs(t, s.CreateUser(user), storage.ErrUserExists)
+
+	got, err := s.GetUserByUsername("user")
+	require.NoError(t, err)
+	require.Equal(t, "1", got.ID)
+	require.NoError(t, bcrypt.CompareHashAndPassword([]byte(got.Password), []byte("password123")))
+
+	_, err = s.GetUserByUsername("missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
+
+func TestStorage_Concurrent(t *testing.T) {
+	s := NewStorage()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			alias := fmt.Sprintf("alias%d", i%10)
+			_, _ = s.SaveURL(storage.Link{URL: "https://google.com", Alias: alias, Creator: "creator"})
+			_, _ = s.GetURL(alias)
+			_, _ = s.DeleteURL(alias, "creator")
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestStorage_ConsumeClick(t *testing.T) {
+	s := NewStorage()
+	limit := int64(5)
+	_, err := s.SaveURL(storage.Link{Alias: "limited", URL: "https://google.com", ClicksLeft: &limit})
+	require.NoError(t, err)
+
+	_, err = s.GetURL("limited")
+	require.ErrorIs(t, err, storage.ErrURLLimited)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redirects := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.ConsumeClick("limited"); err == nil {
+				mu.Lock()
+				redirects++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 5, redirects)
+	require.EqualValues(t, 5, limit, "the caller's link is not changed")
+	_, err = s.GetURL("limited")
+	require.ErrorIs(t, err, storage.ErrURLExhausted)
+}
@@ -0,0 +1,269 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// migrationFile matches names like 0001_init.up.sql and 0001_init.down.sql
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+var ErrNoDownMigration = errors.New("no down migration")
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+	Data    DataStep // runs after Up, nil for most migrations
+}
+
+// DataStep changes rows in ways a script can't, like computing values in Go. It runs in the
+// transaction of its migration, so a failing step applies nothing.
+type DataStep func(ctx context.Context, tx *sql.Tx) error
+
+// Locker takes an exclusive lock on conn so that only one instance migrates at a time.
+// The returned func releases it.
+type Locker func(ctx context.Context, conn *sql.Conn) (unlock func() error, err error)
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	lock       Locker
+}
+
+// New reads migrations from the root of fsys. lock may be nil when the database
+// already serializes writers (sqlite).
+func New(db *sql.DB, fsys fs.FS, lock Locker) (*Migrator, error) {
+	const op = "storage.migrate.New"
+
+	migrations, err := load(fsys)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &Migrator{db: db, migrations: migrations, lock: lock}, nil
+}
+
+// PostgresLock uses a session level advisory lock identified by key.
+func PostgresLock(key int64) Locker {
+	return func(ctx context.Context, conn *sql.Conn) (func() error, error) {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, key); err != nil {
+			return nil, fmt.Errorf("failed to take advisory lock: %w", err)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, key)
+			return err
+		}, nil
+	}
+}
+
+// Data attaches step to the up migration of version.
+func (m *Migrator) Data(version int64, step DataStep) error {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			m.migrations[i].Data = step
+			return nil
+		}
+	}
+	return fmt.Errorf("storage.migrate.Data: no migration %d", version)
+}
+
+// Up applies all pending migrations and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	const op = "storage.migrate.Up"
+
+	applied := 0
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		current, err := version(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if migration.Version <= current {
+				continue
+			}
+			err := apply(ctx, conn, migration.Up, migration.Data,
+				fmt.Sprintf(`INSERT INTO schema_migrations(version) VALUES (%d)`, migration.Version))
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	if err != nil {
+		return applied, fmt.Errorf("%s: %w", op, err)
+	}
+	return applied, nil
+}
+
+// Down rolls back the last steps applied migrations and returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	const op = "storage.migrate.Down"
+
+	rolledBack := 0
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		for ; rolledBack < steps; rolledBack++ {
+			current, err := version(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if current == 0 {
+				return nil
+			}
+			migration, ok := m.find(current)
+			if !ok || migration.Down == "" {
+				return fmt.Errorf("migration %d: %w", current, ErrNoDownMigration)
+			}
+			err = apply(ctx, conn, migration.Down, nil,
+				fmt.Sprintf(`DELETE FROM schema_migrations WHERE version = %d`, migration.Version))
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rolledBack, fmt.Errorf("%s: %w", op, err)
+	}
+	return rolledBack, nil
+}
+
+// Version returns the latest applied migration, 0 if none were applied.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	const op = "storage.migrate.Version"
+
+	var current int64
+	err := m.withConn(ctx, func(conn *sql.Conn) (err error) {
+		current, err = version(ctx, conn)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return current, nil
+}
+
+// withConn runs fn on a single connection holding the migration lock.
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.lock != nil {
+		unlock, err := m.lock(ctx, conn)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if unlockErr := unlock(); unlockErr != nil && err == nil {
+				err = fmt.Errorf("failed to release lock: %w", unlockErr)
+			}
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP);`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) find(v int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == v {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+func version(ctx context.Context, conn *sql.Conn) (int64, error) {
+	var current sql.NullInt64
+	err := conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return current.Int64, nil
+}
+
+// apply runs the migration script, its data step if any and the bookkeeping statement in one transaction.
+func apply(ctx context.Context, conn *sql.Conn, script string, data DataStep, bookkeeping string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if data != nil {
+		if err := data(ctx, tx); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		v, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[v]
+		if !ok {
+			migration = &Migration{Version: v, Name: match[2]}
+			byVersion[v] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("version %d is used by both %s and %s", v, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.Up = string(body)
+		} else {
+			migration.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
@@ -0,0 +1,134 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"0001_users.up.sql":   {Data: []byte(`CREATE TABLE users (id TEXT PRIMARY KEY);`)},
+		"0001_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+		"0002_name.up.sql":    {Data: []byte(`ALTER TABLE users ADD COLUMN name TEXT;`)},
+		"0002_name.down.sql":  {Data: []byte(`ALTER TABLE users DROP COLUMN name;`)},
+		"README.md":           {Data: []byte(`not a migration`)},
+	}
+	migrator, err := New(db, fsys, nil)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, applied)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, applied)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, version)
+
+	_, err = db.Exec(`INSERT INTO users(id, name) VALUES ('1', 'name')`)
+	require.NoError(t, err)
+
+	rolledBack, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, rolledBack)
+
+	_, err = db.Exec(`INSERT INTO users(id, name) VALUES ('2', 'name')`)
+	require.Error(t, err)
+
+	rolledBack, err = migrator.Down(ctx, 5)
+	require.NoError(t, err)
+	require.Equal(t, 1, rolledBack)
+
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 0, version)
+}
+
+func TestMigrator_Data(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"0001_users.up.sql": {Data: []byte(`CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT);`)},
+		"0002_names.up.sql": {Data: []byte(`INSERT INTO users(id, name) VALUES ('1', 'Name');`)},
+	}
+	migrator, err := New(db, fsys, nil)
+	require.NoError(t, err)
+	require.Error(t, migrator.Data(3, nil), "no migration 3")
+
+	fail := true
+	require.NoError(t, migrator.Data(2, func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET name = lower(name)`); err != nil {
+			return err
+		}
+		if fail {
+			return errors.New("step failed")
+		}
+		return nil
+	}))
+
+	applied, err := migrator.Up(ctx)
+	require.Error(t, err)
+	require.Equal(t, 1, applied, "a failing step rolls its migration back")
+	var rows int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM users`).Scan(&rows))
+	require.Zero(t, rows)
+
+	fail = false
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, applied)
+	var name string
+	require.NoError(t, db.QueryRow(`SELECT name FROM users WHERE id = '1'`).Scan(&name))
+	require.Equal(t, "name", name)
+}
+
+func TestMigrator_FailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	fsys := fstest.MapFS{
+		"0001_users.up.sql": {Data: []byte(`CREATE TABLE users (id TEXT PRIMARY KEY);`)},
+		"0002_bad.up.sql":   {Data: []byte(`CREATE TABLE posts (id TEXT); SELECT * FROM missing;`)},
+	}
+	migrator, err := New(db, fsys, nil)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	require.Error(t, err)
+	require.Equal(t, 1, applied)
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, version)
+
+	_, err = db.Exec(`SELECT * FROM posts`)
+	require.Error(t, err, "partially applied migration must be rolled back")
+}
+
+func TestNew_MissingUp(t *testing.T) {
+	_, err := New(nil, fstest.MapFS{"0001_users.down.sql": {Data: []byte(`DROP TABLE users;`)}}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Passthrough is what a redirect carries over from the short url to the target. Links forward
+// nothing by default, so a short link always lands on exactly the url it was made for.
+//
+// With Query, incoming query parameters are appended to the target's, but a parameter the target
+// already has keeps the target's values: the link owner decides, visitors can't override a pinned
+// utm_source or version. With Path, the path after the alias is joined onto the target path, so
+// /docs/api/v2 of a link to https://example.com/manual/ goes to https://example.com/manual/api/v2.
+// The target's own query string and fragment stay as they are.
+type Passthrough struct {
+	Query bool
+	Path  bool
+}
+
+// Forward returns target with query and the path suffix after the alias forwarded as p allows.
+// Targets that don't parse are returned as they are.
+func (p Passthrough) Forward(target string, query url.Values, suffix string) string {
+	forwardQuery := p.Query && len(query) > 0
+	forwardPath := p.Path && suffix != ""
+	if !forwardQuery && !forwardPath {
+		return target
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return target
+	}
+
+	if forwardPath {
+		var segments []string
+		for _, segment := range strings.Split(suffix, "/") {
+			// dot segments would climb out of the target path, empty ones are doubled slashes
+			if segment == "" || segment == "." || segment == ".." {
+				continue
+			}
+			segments = append(segments, url.PathEscape(segment))
+		}
+		if len(segments) > 0 {
+			if strings.HasSuffix(suffix, "/") {
+				segments[len(segments)-1] += "/"
+			}
+			u = u.JoinPath(segments...)
+		}
+	}
+
+	if forwardQuery {
+		own := u.Query()
+		extra := url.Values{}
+		for name, values := range query {
+			if _, ok := own[name]; !ok {
+				extra[name] = values
+			}
+		}
+		if len(extra) > 0 {
+			if u.RawQuery != "" {
+				u.RawQuery += "&"
+			}
+			u.RawQuery += extra.Encode()
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"url_shortener/internal/storage"
+)
+
+// SaveURLs saves all links in one transaction or none of them. The failing link is reported as a
+// *storage.ItemError, wrapping storage.ErrURLExists when its alias is taken.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link) ([]string, error) {
+	const info = "storage.postgres.SaveURLs"
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids := make([]string, len(links))
+	batch := &pgx.Batch{}
+	for i, link := range links {
+		if err := checkCollection(ctx, tx, link.CollectionID, link.Creator); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		if err := checkDomain(ctx, tx, link.Domain, link.Creator); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		ids[i] = uuid.New().String()
+		passwordHash, err := storage.HashPassword(link.Password)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		batch.Queue(`INSERT INTO url(id, url, url_normalized, alias, creator, expires_at, password_hash, clicks_left, not_before, not_after, fallback_url, schedule,
+			tags, collection_id, domain, forward_query, forward_path)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13, NULLIF($14, '')::uuid, $15, $16, $17)`,
+			ids[i], link.URL, storage.NormalizeURL(link.URL), link.Alias, link.Creator, link.ExpiresAt, passwordHash, link.ClicksLeft,
+			link.NotBefore, link.NotAfter, link.FallbackURL, link.Schedule, storage.NormalizeTags(link.Tags), link.CollectionID, link.Domain,
+			link.Passthrough.Query, link.Passthrough.Path)
+	}
+	results := tx.SendBatch(ctx, batch)
+	for i, link := range links {
+		if _, err := results.Exec(); err != nil {
+			_ = results.Close()
+			if isUniqueViolation(err) {
+				err = fmt.Errorf("%s, %w", link.Key(), storage.ErrURLExists)
+			}
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+	}
+	if err = results.Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return ids, nil
+}
@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// SaveClicks bulk inserts click events with COPY and adds them to the rollup tables in the same transaction.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const info = "storage.postgres.SaveClicks"
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"clicks"},
+		[]string{"alias", "clicked_at", "referrer", "user_agent", "ip"},
+		pgx.CopyFromSlice(len(clicks), func(i int) ([]any, error) {
+			c := clicks[i]
+			return []any{c.Alias, c.ClickedAt, c.Referrer, c.UserAgent, c.IP}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", info, err)
+	}
+
+	hourly, dimensions := storage.RollupClicks(clicks)
+	batch := &pgx.Batch{}
+	for _, h := range hourly {
+		batch.Queue(`INSERT INTO click_rollups_hourly(alias, bucket, clicks) VALUES ($1, $2, $3)
+		ON CONFLICT (alias, bucket) DO UPDATE SET clicks = click_rollups_hourly.clicks + EXCLUDED.clicks`,
+			h.Alias, h.Hour, h.Clicks)
+	}
+	for _, d := range dimensions {
+		batch.Queue(`INSERT INTO click_rollups_daily_dimensions(alias, day, dimension, value, clicks) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (alias, day, dimension, value) DO UPDATE SET clicks = click_rollups_daily_dimensions.clicks + EXCLUDED.clicks`,
+			d.Alias, d.Day, d.Dimension, d.Value, d.Clicks)
+	}
+	if err = tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("%s: failed to update rollups: %w", info, err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", info, err)
+	}
+	return nil
+}
+
+// ClickStats aggregates clicks on alias in [from, to) from the rollup tables. Only the unique
+// visitors count reads raw click events. Top lists are computed over whole UTC days.
+func (s *Storage) ClickStats(ctx context.Context, alias string, from, to time.Time, top int) (storage.ClickStats, error) {
+	const info = "storage.postgres.ClickStats"
+	var stats storage.ClickStats
+
+	rows, err := s.DB.Query(ctx, `SELECT bucket, clicks FROM click_rollups_hourly
+	WHERE alias = $1 AND bucket >= $2 AND bucket < $3 ORDER BY bucket`, alias, from, to)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	stats.Hourly, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ClickBucket, error) {
+		var b storage.ClickBucket
+		err := row.Scan(&b.Start, &b.Clicks)
+		b.Start = b.Start.UTC()
+		return b, err
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	for _, b := range stats.Hourly {
+		stats.Total += b.Clicks
+	}
+
+	err = s.DB.QueryRow(ctx, `SELECT COUNT(DISTINCT (ip, user_agent)) FROM clicks
+	WHERE alias = $1 AND clicked_at >= $2 AND clicked_at < $3`, alias, from, to).Scan(&stats.UniqueVisitors)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	firstDay, lastDay := storage.DayRange(from, to)
+	topValues := func(dimension string) ([]storage.ValueCount, error) {
+		rows, err := s.DB.Query(ctx, `SELECT value, SUM(clicks)::bigint FROM click_rollups_daily_dimensions
+		WHERE alias = $1 AND dimension = $2 AND day >= $3 AND day <= $4
+		GROUP BY value ORDER BY 2 DESC, value LIMIT $5`, alias, dimension, firstDay, lastDay, top)
+		if err != nil {
+			return nil, err
+		}
+		return pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ValueCount, error) {
+			var v storage.ValueCount
+			err := row.Scan(&v.Value, &v.Clicks)
+			return v, err
+		})
+	}
+	if stats.TopReferrers, err = topValues(storage.DimensionReferrer); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	if stats.TopUserAgents, err = topValues(storage.DimensionUserAgent); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	return stats, nil
+}
+
+// dropClicks deletes the clicks and rollups of the links where matches, url aliased as u. Purges
+// call it before deleting the links, so the next link to take a freed alias starts without stats.
+func dropClicks(ctx context.Context, tx pgx.Tx, where string, args ...any) error {
+	for _, table := range []string{"clicks", "click_rollups_hourly", "click_rollups_daily_dimensions"} {
+		stmt := fmt.Sprintf(`DELETE FROM %s WHERE alias IN (SELECT %s FROM url u WHERE %s)`, table, linkKey, where)
+		if _, err := tx.Exec(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// CreateCollection saves a new collection and returns it with its id, storage.ErrCollectionExists
+// if the owner has one of the same name.
+func (s *Storage) CreateCollection(c storage.Collection) (storage.Collection, error) {
+	const info = "storage.postgres.CreateCollection"
+	c.ID = uuid.New().String()
+	err := s.DB.QueryRow(context.Background(), `INSERT INTO collections(id, owner, name, description)
+	VALUES ($1, $2, $3, $4) RETURNING createdAt`, c.ID, c.Owner, c.Name, c.Description).Scan(&c.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, c.Name, storage.ErrCollectionExists)
+		}
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return c, nil
+}
+
+// ListCollections returns the collections of owner ordered by name, with the number of their live links.
+func (s *Storage) ListCollections(owner string) ([]storage.CollectionSummary, error) {
+	const info = "storage.postgres.ListCollections"
+	rows, err := s.DB.Query(context.Background(), `SELECT c.id, c.owner, c.name, c.description, c.createdAt,
+		(SELECT COUNT(*) FROM url u WHERE u.collection_id = c.id AND u.deleted_at IS NULL)
+	FROM collections c WHERE c.owner = $1 ORDER BY c.name`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	collections, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.CollectionSummary, error) {
+		var c storage.CollectionSummary
+		err := row.Scan(&c.ID, &c.Owner, &c.Name, &c.Description, &c.CreatedAt, &c.Links)
+		return c, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return collections, nil
+}
+
+// GetCollection returns the collection id of owner, storage.ErrCollectionNotFound if owner has no such collection.
+func (s *Storage) GetCollection(id, owner string) (storage.Collection, error) {
+	const info = "storage.postgres.GetCollection"
+	var c storage.Collection
+	cid, err := collectionID(id)
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	err = s.DB.QueryRow(context.Background(), `SELECT id, owner, name, description, createdAt
+	FROM collections WHERE id = $1::uuid AND owner = $2`, cid, owner).
+		Scan(&c.ID, &c.Owner, &c.Name, &c.Description, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+		}
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return c, nil
+}
+
+// UpdateCollection changes the name or description of the collection id of owner and returns it.
+func (s *Storage) UpdateCollection(id, owner string, upd storage.CollectionUpdate) (storage.Collection, error) {
+	const info = "storage.postgres.UpdateCollection"
+
+	cid, err := collectionID(id)
+	if err != nil {
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	args := []any{cid, owner}
+	sets := []string{"name = name"}
+	set := func(column string, v any) {
+		args = append(args, v)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if upd.Name != nil {
+		set("name", *upd.Name)
+	}
+	if upd.Description != nil {
+		set("description", *upd.Description)
+	}
+
+	var c storage.Collection
+	err = s.DB.QueryRow(context.Background(), fmt.Sprintf(`UPDATE collections SET %s WHERE id = $1::uuid AND owner = $2
+	RETURNING id, owner, name, description, createdAt`, strings.Join(sets, ", ")), args...).
+		Scan(&c.ID, &c.Owner, &c.Name, &c.Description, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+		}
+		if isUniqueViolation(err) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, *upd.Name, storage.ErrCollectionExists)
+		}
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return c, nil
+}
+
+// DeleteCollection deletes the collection id of owner, its links stay without a collection.
+func (s *Storage) DeleteCollection(id, owner string) (bool, error) {
+	const info = "storage.postgres.DeleteCollection"
+	cid, err := collectionID(id)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	result, err := s.DB.Exec(context.Background(), `DELETE FROM collections WHERE id = $1::uuid AND owner = $2`, cid, owner)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if result.RowsAffected() == 0 {
+		return false, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+	}
+	return true, nil
+}
+
+// CollectionStats aggregates clicks on the live links of collection id in [from, to) from the hourly rollups.
+func (s *Storage) CollectionStats(ctx context.Context, id string, from, to time.Time, top int) (storage.CollectionStats, error) {
+	const info = "storage.postgres.CollectionStats"
+	var stats storage.CollectionStats
+
+	cid, err := collectionID(id)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	err = s.DB.QueryRow(ctx, `SELECT COUNT(*) FROM url WHERE collection_id = $1::uuid AND deleted_at IS NULL`, cid).
+		Scan(&stats.Links)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	rows, err := s.DB.Query(ctx, `SELECT r.bucket, SUM(r.clicks)::bigint FROM click_rollups_hourly r
+	JOIN url u ON r.alias = `+linkKey+`
+	WHERE u.collection_id = $1::uuid AND u.deleted_at IS NULL AND r.bucket >= $2 AND r.bucket < $3
+	GROUP BY r.bucket ORDER BY r.bucket`, cid, from.UTC(), to.UTC())
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	stats.Hourly, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ClickBucket, error) {
+		var b storage.ClickBucket
+		err := row.Scan(&b.Start, &b.Clicks)
+		b.Start = b.Start.UTC()
+		return b, err
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	for _, b := range stats.Hourly {
+		stats.Total += b.Clicks
+	}
+
+	rows, err = s.DB.Query(ctx, `SELECT r.alias, SUM(r.clicks)::bigint FROM click_rollups_hourly r
+	JOIN url u ON r.alias = `+linkKey+`
+	WHERE u.collection_id = $1::uuid AND u.deleted_at IS NULL AND r.bucket >= $2 AND r.bucket < $3
+	GROUP BY r.alias ORDER BY 2 DESC, r.alias LIMIT $4`, cid, from.UTC(), to.UTC(), top)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	stats.TopLinks, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.ValueCount, error) {
+		var v storage.ValueCount
+		err := row.Scan(&v.Value, &v.Clicks)
+		return v, err
+	})
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	return stats, nil
+}
+
+// rowQuerier is a pool or a transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// checkCollection makes sure links of creator can be put in collection id, storage.ErrCollectionNotFound
+// if it belongs to someone else. An empty id is no collection and always passes.
+func checkCollection(ctx context.Context, q rowQuerier, id, creator string) error {
+	if id == "" {
+		return nil
+	}
+	cid, err := collectionID(id)
+	if err != nil {
+		return err
+	}
+	var ok bool
+	err = q.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM collections WHERE id = $1::uuid AND owner = $2)`, cid, creator).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s, %w", id, storage.ErrCollectionNotFound)
+	}
+	return nil
+}
+
+// collectionID returns id in the canonical form of a uuid, storage.ErrCollectionNotFound if it is
+// not one: no collection has such an id, and postgres would fail the comparison instead.
+func collectionID(id string) (string, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("%s, %w", id, storage.ErrCollectionNotFound)
+	}
+	return parsed.String(), nil
+}
@@ -0,0 +1,157 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// linkKey is storage.Key of the url row u, the alias clicks of the link are rolled up under.
+const linkKey = `CASE WHEN u.domain = '' THEN u.alias ELSE u.domain || '/' || u.alias END`
+
+// CreateDomain claims a custom host for the owner, unverified. Unverified claims of other users
+// without links are taken over, storage.ErrDomainExists if the host is taken otherwise.
+func (s *Storage) CreateDomain(d storage.Domain) (storage.Domain, error) {
+	const info = "storage.postgres.CreateDomain"
+	d.Host = storage.NormalizeHost(d.Host)
+	err := s.DB.QueryRow(context.Background(), `INSERT INTO domains(host, owner) VALUES ($1, $2)
+	ON CONFLICT(host) DO UPDATE SET owner = excluded.owner, createdAt = now()
+	WHERE domains.owner <> excluded.owner AND `+claimable+`
+	RETURNING createdAt`, d.Host, d.Owner).
+		Scan(&d.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Domain{}, fmt.Errorf("%s: %s, %w", info, d.Host, storage.ErrDomainExists)
+		}
+		return storage.Domain{}, fmt.Errorf("%s: %w", info, err)
+	}
+	d.VerifiedAt = nil
+	return d, nil
+}
+
+// VerifyDomain marks host verified for owner, once owner proved control of it. A host that is not
+// claimed yet or claimed but unverified by someone else without links becomes owner's,
+// storage.ErrDomainExists if another user holds it.
+func (s *Storage) VerifyDomain(host, owner string) (storage.Domain, error) {
+	const info = "storage.postgres.VerifyDomain"
+	d := storage.Domain{Host: storage.NormalizeHost(host), Owner: owner}
+	var verifiedAt time.Time
+	err := s.DB.QueryRow(context.Background(), `INSERT INTO domains(host, owner, verified_at) VALUES ($1, $2, now())
+	ON CONFLICT(host) DO UPDATE SET owner = excluded.owner,
+		createdAt = CASE WHEN domains.owner = excluded.owner THEN domains.createdAt ELSE now() END,
+		verified_at = COALESCE(domains.verified_at, excluded.verified_at)
+	WHERE domains.owner = excluded.owner OR `+claimable+`
+	RETURNING createdAt, verified_at`, d.Host, d.Owner).
+		Scan(&d.CreatedAt, &verifiedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Domain{}, fmt.Errorf("%s: %s, %w", info, d.Host, storage.ErrDomainExists)
+		}
+		return storage.Domain{}, fmt.Errorf("%s: %w", info, err)
+	}
+	d.VerifiedAt = &verifiedAt
+	return d, nil
+}
+
+// claimable holds for a domains row another user may take over: unverified and without links,
+// which only domains registered before verification existed can have.
+const claimable = `domains.verified_at IS NULL AND NOT EXISTS(SELECT 1 FROM url WHERE url.domain = domains.host)`
+
+// ListDomains returns the domains of owner ordered by host.
+func (s *Storage) ListDomains(owner string) ([]storage.Domain, error) {
+	const info = "storage.postgres.ListDomains"
+	rows, err := s.DB.Query(context.Background(), `SELECT host, owner, createdAt, verified_at FROM domains WHERE owner = $1 ORDER BY host`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	domains, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.Domain, error) {
+		var d storage.Domain
+		err := row.Scan(&d.Host, &d.Owner, &d.CreatedAt, &d.VerifiedAt)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return domains, nil
+}
+
+// DeleteDomain removes the domain host of owner. Domains with links, trashed ones included, are kept
+// with storage.ErrDomainInUse: deleting it would hand their aliases over to the default domain.
+func (s *Storage) DeleteDomain(host, owner string) (bool, error) {
+	const info = "storage.postgres.DeleteDomain"
+	ctx := context.Background()
+	host = storage.NormalizeHost(host)
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err = ownDomain(ctx, tx, host, owner); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	// the row lock keeps links from being added while the domain goes away
+	if _, err = tx.Exec(ctx, `SELECT 1 FROM domains WHERE host = $1 FOR UPDATE`, host); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	var inUse bool
+	if err = tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM url WHERE domain = $1)`, host).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if inUse {
+		return false, fmt.Errorf("%s: %s, %w", info, host, storage.ErrDomainInUse)
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM domains WHERE host = $1`, host); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	return true, nil
+}
+
+// DomainHosts returns the hosts of all verified domains.
+func (s *Storage) DomainHosts(ctx context.Context) ([]string, error) {
+	const info = "storage.postgres.DomainHosts"
+	rows, err := s.DB.Query(ctx, `SELECT host FROM domains WHERE verified_at IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	hosts, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return hosts, nil
+}
+
+// checkDomain makes sure links of creator can be put on domain, storage.ErrDomainNotFound if it is
+// not registered or belongs to someone else, storage.ErrDomainNotVerified if it is not verified yet.
+// The default domain always passes.
+func checkDomain(ctx context.Context, q rowQuerier, domain, creator string) error {
+	if domain == "" {
+		return nil
+	}
+	verified, err := ownDomain(ctx, q, domain, creator)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("%s, %w", domain, storage.ErrDomainNotVerified)
+	}
+	return nil
+}
+
+// ownDomain reports whether the domain of owner is verified, storage.ErrDomainNotFound if owner
+// has no such domain.
+func ownDomain(ctx context.Context, q rowQuerier, domain, owner string) (bool, error) {
+	var verified bool
+	err := q.QueryRow(ctx, `SELECT verified_at IS NOT NULL FROM domains WHERE host = $1 AND owner = $2`, domain, owner).Scan(&verified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, fmt.Errorf("%s, %w", domain, storage.ErrDomainNotFound)
+	}
+	return verified, err
+}
@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"url_shortener/internal/storage"
+)
+
+// ListURLs returns a page of the creator's links with their click counts taken from the hourly rollups.
+func (s *Storage) ListURLs(ctx context.Context, q storage.ListQuery) ([]storage.LinkSummary, error) {
+	const info = "storage.postgres.ListURLs"
+
+	args := []any{q.Creator}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	filters := []string{"u.creator = $1", "u.deleted_at IS NULL"}
+	if q.Deleted {
+		filters[1] = "u.deleted_at IS NOT NULL"
+	}
+	if q.Domain != "" {
+		filters = append(filters, fmt.Sprintf(
+			`substring(u.url from '^[a-zA-Z][a-zA-Z0-9+.-]*://(?:[^/?#@]*@)?([^/?#:]+)') ILIKE '%%' || %s || '%%'`, arg(q.Domain)))
+	}
+	if len(q.Tags) > 0 {
+		filters = append(filters, "u.tags @> "+arg(q.Tags))
+	}
+	if q.Collection != "" {
+		cid, err := collectionID(q.Collection)
+		if err != nil {
+			// no links are in a collection that can't exist
+			return nil, nil
+		}
+		filters = append(filters, "u.collection_id = "+arg(cid)+"::uuid")
+	}
+	if q.CreatedFrom != nil {
+		filters = append(filters, "u.createdAt >= "+arg(*q.CreatedFrom))
+	}
+	if q.CreatedTo != nil {
+		filters = append(filters, "u.createdAt < "+arg(*q.CreatedTo))
+	}
+
+	sortColumn, order, cmp := "createdAt", "ASC", ">"
+	if q.SortBy == storage.SortClicks {
+		sortColumn = "clicks"
+	}
+	if q.Desc {
+		order, cmp = "DESC", "<"
+	}
+
+	page := ""
+	if q.After != nil {
+		var after any = q.After.CreatedAt
+		if q.SortBy == storage.SortClicks {
+			after = q.After.Clicks
+		}
+		page = fmt.Sprintf("WHERE (%s, id) %s (%s, %s::uuid)", sortColumn, cmp, arg(after), arg(q.After.ID))
+	}
+
+	stmt := fmt.Sprintf(`SELECT id, alias, url, creator, createdAt, expires_at, deleted_at, tags, collection_id, domain, forward_query, forward_path, password_hash, clicks FROM (
+		SELECT u.id, u.alias, u.url, u.creator, u.createdAt, u.expires_at, u.deleted_at, u.tags,
+			COALESCE(u.collection_id::text, '') AS collection_id, u.domain, u.forward_query, u.forward_path,
+			COALESCE(u.password_hash, '') AS password_hash,
+			COALESCE((SELECT SUM(r.clicks) FROM click_rollups_hourly r WHERE r.alias = `+linkKey+`), 0)::bigint AS clicks
+		FROM url u WHERE %s
+	) links %s
+	ORDER BY %s %s, id %s LIMIT %s`,
+		strings.Join(filters, " AND "), page, sortColumn, order, order, arg(q.Limit))
+
+	rows, err := s.DB.Query(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	links, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (storage.LinkSummary, error) {
+		var l storage.LinkSummary
+		err := row.Scan(&l.ID, &l.Alias, &l.URL, &l.Creator, &l.CreatedAt, &l.ExpiresAt, &l.DeletedAt, &l.Tags, &l.CollectionID, &l.Domain,
+			&l.Passthrough.Query, &l.Passthrough.Path, &l.Password, &l.Clicks)
+		return l, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return links, nil
+}
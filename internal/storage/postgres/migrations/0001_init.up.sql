@@ -0,0 +1,17 @@
+-- IF NOT EXISTS lets databases created before migrations were introduced adopt this version
+CREATE TABLE IF NOT EXISTS users (
+    id UUID PRIMARY KEY,                      -- Use UUID for unique, standard IDs
+    username TEXT NOT NULL UNIQUE,           -- Ensure usernames are unique
+    password TEXT NOT NULL                   -- Password cannot be NULL
+);
+
+CREATE TABLE IF NOT EXISTS url (
+    id UUID PRIMARY KEY,
+    alias TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    creator UUID NOT NULL,                  -- Reference to the users table
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (creator) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
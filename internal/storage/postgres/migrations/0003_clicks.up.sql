@@ -0,0 +1,12 @@
+-- no foreign key to url: clicks point at the link key (see storage.Key), not a row. Trashed links
+-- keep their clicks, purges delete them together with the link (see dropClicks)
+CREATE TABLE clicks (
+    id BIGSERIAL PRIMARY KEY,
+    alias TEXT NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_clicks_alias_clicked_at ON clicks(alias, clicked_at);
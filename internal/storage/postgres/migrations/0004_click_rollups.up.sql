@@ -0,0 +1,28 @@
+-- precomputed click counts, kept up to date by SaveClicks; all buckets are in UTC
+CREATE TABLE click_rollups_hourly (
+    alias TEXT NOT NULL,
+    bucket TIMESTAMPTZ NOT NULL,
+    clicks BIGINT NOT NULL,
+    PRIMARY KEY (alias, bucket)
+);
+
+CREATE TABLE click_rollups_daily_dimensions (
+    alias TEXT NOT NULL,
+    day DATE NOT NULL,
+    dimension TEXT NOT NULL,                 -- referrer, user_agent
+    value TEXT NOT NULL,
+    clicks BIGINT NOT NULL,
+    PRIMARY KEY (alias, day, dimension, value)
+);
+
+INSERT INTO click_rollups_hourly(alias, bucket, clicks)
+SELECT alias, date_trunc('hour', clicked_at AT TIME ZONE 'UTC') AT TIME ZONE 'UTC', COUNT(*)
+FROM clicks GROUP BY 1, 2;
+
+INSERT INTO click_rollups_daily_dimensions(alias, day, dimension, value, clicks)
+SELECT alias, (clicked_at AT TIME ZONE 'UTC')::date, 'referrer', referrer, COUNT(*)
+FROM clicks GROUP BY 1, 2, 4;
+
+INSERT INTO click_rollups_daily_dimensions(alias, day, dimension, value, clicks)
+SELECT alias, (clicked_at AT TIME ZONE 'UTC')::date, 'user_agent', user_agent, COUNT(*)
+FROM clicks GROUP BY 1, 2, 4;
@@ -0,0 +1,2 @@
+-- source of sequential aliases, see random.Sequence
+CREATE SEQUENCE alias_seq;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_url_creator_normalized;
+ALTER TABLE url DROP COLUMN url_normalized;
@@ -0,0 +1,4 @@
+-- deleted links stay in the trash, keeping their alias reserved, until the sweeper purges them
+ALTER TABLE url ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX idx_url_deleted_at ON url(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- bcrypt hash of the password that opens the link, NULL for links anyone can open
+ALTER TABLE url ADD COLUMN password_hash TEXT;
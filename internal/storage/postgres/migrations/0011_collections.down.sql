@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_url_tags;
+DROP INDEX IF EXISTS idx_url_collection_id;
+ALTER TABLE url DROP COLUMN tags;
+ALTER TABLE url DROP COLUMN collection_id;
+DROP TABLE collections;
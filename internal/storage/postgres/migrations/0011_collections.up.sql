@@ -0,0 +1,17 @@
+-- named groups of links of a user, a link belongs to at most one collection
+CREATE TABLE collections (
+    id UUID PRIMARY KEY,
+    owner UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (owner, name)
+);
+
+-- deleting a collection keeps its links
+ALTER TABLE url ADD COLUMN collection_id UUID REFERENCES collections(id) ON DELETE SET NULL;
+-- free-form labels, lower case, sorted and without duplicates
+ALTER TABLE url ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX idx_url_collection_id ON url(collection_id) WHERE collection_id IS NOT NULL;
+CREATE INDEX idx_url_tags ON url USING GIN (tags);
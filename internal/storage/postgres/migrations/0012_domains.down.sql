@@ -0,0 +1,9 @@
+-- aliases are global again, links of custom domains would clash with the default one
+DELETE FROM url WHERE domain <> '';
+ALTER TABLE url DROP CONSTRAINT url_domain_alias_key;
+ALTER TABLE url ADD CONSTRAINT url_alias_key UNIQUE (alias);
+ALTER TABLE url DROP COLUMN domain;
+
+ALTER TABLE url_archive DROP COLUMN domain;
+
+DROP TABLE domains;
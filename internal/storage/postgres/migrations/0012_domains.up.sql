@@ -0,0 +1,13 @@
+-- custom hosts serving their own aliases, stored lower case without a port
+CREATE TABLE domains (
+    host TEXT PRIMARY KEY,
+    owner UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    createdAt TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- '' is the default domain, any host that is not registered; aliases are unique per domain
+ALTER TABLE url ADD COLUMN domain TEXT NOT NULL DEFAULT '';
+ALTER TABLE url DROP CONSTRAINT IF EXISTS url_alias_key;
+ALTER TABLE url ADD CONSTRAINT url_domain_alias_key UNIQUE (domain, alias);
+
+ALTER TABLE url_archive ADD COLUMN domain TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- what redirects forward to the target: the query string and the path after the alias
+ALTER TABLE url ADD COLUMN forward_query BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE url ADD COLUMN forward_path BOOLEAN NOT NULL DEFAULT FALSE;
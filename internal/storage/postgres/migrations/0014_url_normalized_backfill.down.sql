@@ -0,0 +1,2 @@
+-- the normalized values stay, new rows get the same ones
+SELECT 1;
@@ -0,0 +1,3 @@
+-- a domain serves its aliases once its owner proved control of its DNS, see handlers/domain.NewVerify;
+-- domains registered before this migration have to be verified once as well
+ALTER TABLE domains ADD COLUMN verified_at TIMESTAMPTZ;
@@ -2,19 +2,29 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"embed"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	_ "github.com/jackc/pgx/v5/stdlib" // init pgx driver
+	"github.com/jackc/pgx/v5/stdlib"
 	"golang.org/x/crypto/bcrypt"
+	"io/fs"
 	"time"
 	"url_shortener/httpServer/handlers/login"
-	"url_shortener/internal/lib/logger/sl"
 	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/migrate"
 )
 
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockKey identifies the advisory lock held while migrating
+const migrationLockKey = 712053
+
 type Storage struct {
 	DB *pgxpool.Pool
 }
@@ -24,40 +34,73 @@ func NewStorage(ctx context.Context, dsn string) (*Storage, error) {
 	if err != nil {
 		return nil, err
 	}
-	storage := &Storage{DB: pool}
-	// run init queries
-	err = storage.initDatabase(ctx)
+	return &Storage{DB: pool}, nil
+}
+
+// Migrator returns a migrator over the embedded postgres migrations. Concurrent
+// migrators are serialized with an advisory lock. The migrator works on a database/sql
+// handle over the pool, the returned func closes it once migrating is done so that its idle
+// connections go back to the pool.
+func (s *Storage) Migrator() (*migrate.Migrator, func() error, error) {
+	migrations, err := fs.Sub(migrationsFS, "migrations")
 	if err != nil {
-		pool.Close()
-		return nil, err
+		return nil, nil, err
+	}
+	db := stdlib.OpenDBFromPool(s.DB)
+	m, err := migrate.New(db, migrations, migrate.PostgresLock(migrationLockKey))
+	if err == nil {
+		err = m.Data(14, normalizeURLs)
+	}
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return m, db.Close, nil
+}
+
+// normalizeURLs is the data step of migration 0014: url_normalized of every row is set to
+// storage.NormalizeURL of its url, rows from before 0006 only had their raw url.
+func normalizeURLs(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id::text, url, COALESCE(url_normalized, '') FROM url`)
+	if err != nil {
+		return err
 	}
-	return storage, nil
-}
-func (s *Storage) initDatabase(ctx context.Context) error {
-	initQueries := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-    id UUID PRIMARY KEY,                      -- Use UUID for unique, standard IDs
-    username TEXT NOT NULL UNIQUE,           -- Ensure usernames are unique
-    password TEXT NOT NULL                   -- Password cannot be NULL
-);`,
-		`CREATE TABLE IF NOT EXISTS url (
-	id UUID PRIMARY KEY,
-	alias TEXT NOT NULL UNIQUE,
-	url TEXT NOT NULL,
-    creator UUID NOT NULL,                  -- Reference to the users table
-    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (creator) REFERENCES users(id) ON DELETE CASCADE);`,
-		`CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);`,
-	}
-	for _, query := range initQueries {
-		_, err := s.DB.Exec(ctx, query)
-		if err != nil {
-			return fmt.Errorf("%w: failed to exec query", err)
+	// read everything first, the rows can't be updated while the query is open
+	stale := make(map[string]string)
+	for rows.Next() {
+		var id, target, normalized string
+		if err := rows.Scan(&id, &target, &normalized); err != nil {
+			rows.Close()
+			return err
+		}
+		if n := storage.NormalizeURL(target); n != normalized {
+			stale[id] = n
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE url SET url_normalized = $1 WHERE id = $2`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, normalized := range stale {
+		if _, err := stmt.ExecContext(ctx, normalized, id); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// Close releases the connection pool.
+func (s *Storage) Close() error {
+	s.DB.Close()
+	return nil
+}
+
 func NewPgPool(ctx context.Context, dsn string) (pool *pgxpool.Pool, err error) {
 	const op = "storage.postgres.NewPgPool"
 	config, err := pgxpool.ParseConfig(dsn)
@@ -72,7 +115,7 @@ func NewPgPool(ctx context.Context, dsn string) (pool *pgxpool.Pool, err error)
 
 	err = pool.Ping(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("%s: %w", op, sl.Err(err))
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return pool, err
@@ -88,40 +131,190 @@ func (s *Storage) CreateUser(user login.User) error {
 
 	_, err = s.DB.Exec(context.Background(), stmt, user.ID, user.Username, hashedPassword)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %s, %w", info, user.Username, storage.ErrUserExists)
+		}
 		return fmt.Errorf("%s: failed to insert new user: %w", info, err)
 	}
 	return nil
 }
 
-// SaveURL - save url and alias in database, checking if no alias with same name exists in DB if it does it show identifies it
-func (s *Storage) SaveURL(urlToSave, alias, creator string) (string, error) {
+// SaveURL - save link in database, unique constraint violations are reported as storage.ErrURLExists
+func (s *Storage) SaveURL(link storage.Link) (string, error) {
 	const info = "storage.postgres.SaveURL"
 	id := uuid.New().String()
+	passwordHash, err := storage.HashPassword(link.Password)
+	if err != nil {
+		return "", fmt.Errorf("%s: failed to hash pass: %w", info, err)
+	}
+	if err := checkCollection(context.Background(), s.DB, link.CollectionID, link.Creator); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	if err := checkDomain(context.Background(), s.DB, link.Domain, link.Creator); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
 	var createdAt time.Time
-	stmt := `INSERT INTO url(id, url, alias, creator)
-	VALUES ($1, $2, $3, $4) RETURNING createdAt;`
-	err := s.DB.QueryRow(context.Background(), stmt, id, urlToSave, alias, creator).Scan(&createdAt)
+	stmt := `INSERT INTO url(id, url, url_normalized, alias, creator, expires_at, password_hash, clicks_left,
+		not_before, not_after, fallback_url, schedule, tags, collection_id, domain, forward_query, forward_path)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12, $13, NULLIF($14, '')::uuid, $15, $16, $17) RETURNING createdAt;`
+	err = s.DB.QueryRow(context.Background(), stmt, id, link.URL, storage.NormalizeURL(link.URL), link.Alias, link.Creator, link.ExpiresAt, passwordHash, link.ClicksLeft,
+		link.NotBefore, link.NotAfter, link.FallbackURL, link.Schedule, storage.NormalizeTags(link.Tags), link.CollectionID, link.Domain,
+		link.Passthrough.Query, link.Passthrough.Path).Scan(&createdAt)
 	if err != nil {
+		if isUniqueViolation(err) {
+			return "", fmt.Errorf("%s: %s, %w", info, link.Key(), storage.ErrURLExists)
+		}
 		return "", fmt.Errorf("%s: failed to insert entry: %w", info, err)
 	}
 	return id, nil
 }
 
+// NextAliasID returns the next value of the alias sequence
+func (s *Storage) NextAliasID() (int64, error) {
+	const info = "storage.postgres.NextAliasID"
+	var id int64
+	err := s.DB.QueryRow(context.Background(), `SELECT nextval('alias_seq')`).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	return id, nil
+}
+
+// GetURL returns the target of alias, storage.ErrURLExpired if the link has expired but is not purged yet.
+// Links in the trash are not found, links that can't redirect right away give the errors of storage.Link.Target.
 func (s *Storage) GetURL(alias string) (string, error) {
 	const info = "storage.postgres.GetURL"
-	var url string
-	stmt := `SELECT url FROM url WHERE alias = $1`
-	err := s.DB.QueryRow(context.Background(), stmt, alias).Scan(&url)
+	link, err := s.GetLink(alias)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return "", fmt.Errorf("%s: No rows found for the given alias", info)
-		}
 		return "", fmt.Errorf("%s: %w", info, err)
 	}
+	url, err := link.Target(time.Now())
+	if err != nil {
+		// the fallback url of links outside their activation window comes along with the error
+		return url, fmt.Errorf("%s: %s, %w", info, alias, err)
+	}
 	return url, nil
 }
 
-// DeleteURL deletes a URL identified by the alias and creator from the database.
+// Passthrough returns what redirects of alias forward to its target, see storage.Passthrough.
+func (s *Storage) Passthrough(alias string) (storage.Passthrough, error) {
+	const info = "storage.postgres.Passthrough"
+	link, err := s.GetLink(alias)
+	if err != nil {
+		return storage.Passthrough{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link.Passthrough, nil
+}
+
+// ConsumeClick takes one of the clicks left of a click-limited link and returns its target,
+// storage.ErrURLExhausted if none are left. The decrement is a single conditional update, so
+// concurrent redirects never go over the limit. Links without a limit just return their target.
+func (s *Storage) ConsumeClick(alias string) (string, error) {
+	const info = "storage.postgres.ConsumeClick"
+	var link storage.Link
+	domain, name := storage.SplitKey(alias)
+	stmt := `UPDATE url SET clicks_left = clicks_left - 1
+	WHERE domain = $1 AND alias = $2 AND deleted_at IS NULL AND clicks_left > 0 AND (expires_at IS NULL OR expires_at > now())
+	RETURNING url, schedule`
+	err := s.DB.QueryRow(context.Background(), stmt, domain, name).Scan(&link.URL, &link.Schedule)
+	if err == nil {
+		return link.Destination(time.Now()), nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+
+	// nothing to take, find out why
+	link, err = s.GetLink(alias)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	if link.Expired(time.Now()) {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLExpired)
+	}
+	if link.Limited() {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLExhausted)
+	}
+	return link.Destination(time.Now()), nil
+}
+
+// GetLink returns the whole link row of alias, expired or not. Links in the trash are not found.
+func (s *Storage) GetLink(alias string) (storage.Link, error) {
+	const info = "storage.postgres.GetLink"
+	var link storage.Link
+	domain, name := storage.SplitKey(alias)
+	stmt := `SELECT id, alias, url, creator, createdAt, expires_at, COALESCE(password_hash, ''), clicks_left,
+		not_before, not_after, COALESCE(fallback_url, ''), schedule, tags, COALESCE(collection_id::text, ''), domain,
+		forward_query, forward_path
+	FROM url WHERE domain = $1 AND alias = $2 AND deleted_at IS NULL`
+	err := s.DB.QueryRow(context.Background(), stmt, domain, name).
+		Scan(&link.ID, &link.Alias, &link.URL, &link.Creator, &link.CreatedAt, &link.ExpiresAt, &link.Password, &link.ClicksLeft,
+			&link.NotBefore, &link.NotAfter, &link.FallbackURL, &link.Schedule, &link.Tags, &link.CollectionID, &link.Domain,
+			&link.Passthrough.Query, &link.Passthrough.Path)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLNotFound)
+		}
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link, nil
+}
+
+// LinkByTarget returns the oldest live link of creator whose destination normalizes to the same
+// url as target, storage.ErrURLNotFound if there is none. Protected, click-limited and scheduled
+// links are left out, they can't stand in for a plain one, and so are links of custom domains and
+// links that forward the query string or path.
+func (s *Storage) LinkByTarget(creator, target string) (storage.Link, error) {
+	const info = "storage.postgres.LinkByTarget"
+	var link storage.Link
+	stmt := `SELECT id, alias, url, creator, createdAt, expires_at FROM url
+	WHERE creator = $1 AND url_normalized = $2 AND deleted_at IS NULL AND password_hash IS NULL AND clicks_left IS NULL
+	AND not_before IS NULL AND not_after IS NULL AND schedule IS NULL AND domain = '' AND NOT forward_query AND NOT forward_path
+	AND (expires_at IS NULL OR expires_at > now())
+	ORDER BY createdAt, id LIMIT 1`
+	err := s.DB.QueryRow(context.Background(), stmt, creator, storage.NormalizeURL(target)).
+		Scan(&link.ID, &link.Alias, &link.URL, &link.Creator, &link.CreatedAt, &link.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Link{}, fmt.Errorf("%s: %s, %w", info, target, storage.ErrURLNotFound)
+		}
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link, nil
+}
+
+// PurgeExpired removes links that expired before the given moment, moving them to url_archive
+// when archive is set. Their clicks are dropped, so the next link of an alias starts without stats.
+// It returns the number of removed links.
+func (s *Storage) PurgeExpired(ctx context.Context, before time.Time, archive bool) (int64, error) {
+	const info = "storage.postgres.PurgeExpired"
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = dropClicks(ctx, tx, `u.expires_at < $1`, before); err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	stmt := `DELETE FROM url WHERE expires_at < $1`
+	if archive {
+		stmt = `WITH expired AS (
+		DELETE FROM url WHERE expires_at < $1
+		RETURNING id, alias, url, creator, createdAt, expires_at, domain)
+	INSERT INTO url_archive(id, alias, url, creator, createdAt, expires_at, domain)
+	SELECT id, alias, url, creator, createdAt, expires_at, domain FROM expired`
+	}
+	result, err := tx.Exec(ctx, stmt, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	return result.RowsAffected(), tx.Commit(ctx)
+}
+
+// DeleteURL moves the link identified by the alias and creator to the trash. The alias stays taken
+// until PurgeDeleted removes the link.
 func (s *Storage) DeleteURL(alias, creator string) (bool, error) {
 	const info = "storage.postgres.DeleteURL"
 
@@ -135,8 +328,9 @@ func (s *Storage) DeleteURL(alias, creator string) (bool, error) {
 	}
 
 	// Prepare and execute the delete statement
-	stmt := `DELETE FROM url WHERE alias = $1 AND creator = $2`
-	result, err := s.DB.Exec(context.Background(), stmt, alias, creator)
+	domain, name := storage.SplitKey(alias)
+	stmt := `UPDATE url SET deleted_at = now() WHERE domain = $1 AND alias = $2 AND creator = $3 AND deleted_at IS NULL`
+	result, err := s.DB.Exec(context.Background(), stmt, domain, name, creator)
 	if err != nil {
 		return false, fmt.Errorf("%s: failed to execute delete statement: %w", info, err)
 	}
@@ -149,13 +343,56 @@ func (s *Storage) DeleteURL(alias, creator string) (bool, error) {
 	return true, nil
 }
 
+// RestoreURL takes the link identified by the alias and creator out of the trash,
+// storage.ErrAliasNotFound if the creator has no such link there.
+func (s *Storage) RestoreURL(alias, creator string) (storage.Link, error) {
+	const info = "storage.postgres.RestoreURL"
+	var link storage.Link
+	domain, name := storage.SplitKey(alias)
+	stmt := `UPDATE url SET deleted_at = NULL
+	WHERE domain = $1 AND alias = $2 AND creator = $3 AND deleted_at IS NOT NULL
+	RETURNING id, alias, url, creator, createdAt, expires_at, domain`
+	err := s.DB.QueryRow(context.Background(), stmt, domain, name, creator).
+		Scan(&link.ID, &link.Alias, &link.URL, &link.Creator, &link.CreatedAt, &link.ExpiresAt, &link.Domain)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrAliasNotFound)
+		}
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link, nil
+}
+
+// PurgeDeleted removes links that went to the trash before the given moment, freeing their aliases.
+// Their clicks go with them, so the next link of an alias starts without stats. It returns the
+// number of removed links.
+func (s *Storage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	const info = "storage.postgres.PurgeDeleted"
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err = dropClicks(ctx, tx, `u.deleted_at < $1`, before); err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	result, err := tx.Exec(ctx, `DELETE FROM url WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	return result.RowsAffected(), tx.Commit(ctx)
+}
+
 // CaseDifferent checks if the alias exists in a case-sensitive manner.
 func (s *Storage) CaseDifferent(alias string) (bool, error) {
 	const info = "storage.postgres.CaseDifferent"
-	stmt := `SELECT alias FROM url WHERE alias = $1`
+	domain, name := storage.SplitKey(alias)
+	stmt := `SELECT alias FROM url WHERE domain = $1 AND alias = $2 AND deleted_at IS NULL`
 
 	var foundAlias string
-	err := s.DB.QueryRow(context.Background(), stmt, alias).Scan(&foundAlias)
+	err := s.DB.QueryRow(context.Background(), stmt, domain, name).Scan(&foundAlias)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return false, nil // Alias does not exist, not an error
@@ -164,9 +401,17 @@ func (s *Storage) CaseDifferent(alias string) (bool, error) {
 	}
 
 	// Perform case-sensitive comparison
-	if alias == foundAlias {
+	if name == foundAlias {
 		return true, nil
 	}
 
 	return false, nil
 }
+
+// uniqueViolation is the SQLSTATE of unique constraint violations
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
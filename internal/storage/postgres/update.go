@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
+	"strings"
+	"url_shortener/internal/storage"
+)
+
+// UpdateURL changes mutable fields of the link identified by alias and creator and returns the updated link.
+func (s *Storage) UpdateURL(alias, creator string, upd storage.LinkUpdate) (storage.Link, error) {
+	const info = "storage.postgres.UpdateURL"
+
+	ok, err := s.CaseDifferent(alias)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	if !ok {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrCaseMismatch)
+	}
+
+	domain, name := storage.SplitKey(alias)
+	args := []any{domain, name, creator}
+	var sets []string
+	set := func(column string, v any) {
+		args = append(args, v)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if upd.URL != nil {
+		set("url", *upd.URL)
+		set("url_normalized", storage.NormalizeURL(*upd.URL))
+	}
+	if upd.ClearExpiresAt {
+		sets = append(sets, "expires_at = NULL")
+	} else if upd.ExpiresAt != nil {
+		set("expires_at", *upd.ExpiresAt)
+	}
+	if upd.Tags != nil {
+		set("tags", storage.NormalizeTags(*upd.Tags))
+	}
+	if upd.CollectionID != nil {
+		if err := checkCollection(context.Background(), s.DB, *upd.CollectionID, creator); err != nil {
+			return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+		}
+		args = append(args, *upd.CollectionID)
+		sets = append(sets, fmt.Sprintf("collection_id = NULLIF($%d, '')::uuid", len(args)))
+	}
+	if upd.ForwardQuery != nil {
+		set("forward_query", *upd.ForwardQuery)
+	}
+	if upd.ForwardPath != nil {
+		set("forward_path", *upd.ForwardPath)
+	}
+	if len(sets) == 0 {
+		// nothing to change, still check ownership and return the link
+		sets = append(sets, "url = url")
+	}
+
+	stmt := fmt.Sprintf(`UPDATE url SET %s WHERE domain = $1 AND alias = $2 AND creator = $3 AND deleted_at IS NULL
+	RETURNING id, alias, url, creator, createdAt, expires_at, tags, COALESCE(collection_id::text, ''), domain,
+		forward_query, forward_path`, strings.Join(sets, ", "))
+	var link storage.Link
+	err = s.DB.QueryRow(context.Background(), stmt, args...).
+		Scan(&link.ID, &link.Alias, &link.URL, &link.Creator, &link.CreatedAt, &link.ExpiresAt, &link.Tags, &link.CollectionID, &link.Domain,
+			&link.Passthrough.Query, &link.Passthrough.Path)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return storage.Link{}, fmt.Errorf("%s: no rows found for alias %s and creator %s, %w", info, alias, creator, storage.ErrAliasNotFound)
+		}
+		return storage.Link{}, fmt.Errorf("%s: failed to execute update statement: %w", info, err)
+	}
+	return link, nil
+}
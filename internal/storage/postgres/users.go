@@ -2,15 +2,24 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5"
 
 	"url_shortener/httpServer/handlers/login"
 )
 
 func (s *Storage) GetUserByUsername(username string) (login.User, error) {
+	const info = "internal.storage.postgres.users.GetUserByUsername"
 	var user login.User
 	row := s.DB.QueryRow(context.Background(), `SELECT id, username, password FROM users WHERE username = $1`, username)
 	err := row.Scan(&user.ID, &user.Username, &user.Password)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// login handler treats sql.ErrNoRows as "invalid username or password"
+			return login.User{}, fmt.Errorf("%s: %w", info, sql.ErrNoRows)
+		}
 		return login.User{}, err
 	}
 
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ScheduledURL switches the destination of a link to URL from At on.
+type ScheduledURL struct {
+	At  time.Time `json:"at"`
+	URL string    `json:"url"`
+}
+
+// Schedule is the list of destination changes of a link ordered by At. It is stored as json,
+// an empty schedule as NULL.
+type Schedule []ScheduledURL
+
+// Sorted returns a copy of the schedule ordered by At.
+func (s Schedule) Sorted() Schedule {
+	if len(s) == 0 {
+		return nil
+	}
+	sorted := make(Schedule, len(s))
+	copy(sorted, s)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].At.Before(sorted[j].At) })
+	return sorted
+}
+
+func (s Schedule) Value() (driver.Value, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (s *Schedule) Scan(src any) error {
+	var raw []byte
+	switch v := src.(type) {
+	case nil:
+		*s = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("can't scan %T into a schedule", src)
+	}
+	return json.Unmarshal(raw, s)
+}
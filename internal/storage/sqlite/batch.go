@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"url_shortener/internal/storage"
+)
+
+// SaveURLs saves all links in one transaction or none of them. The failing link is reported as a
+// *storage.ItemError, wrapping storage.ErrURLExists when its alias is taken.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link) ([]string, error) {
+	const info = "storage.sqlite.SaveURLs"
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO url(id, url, url_normalized, alias, creator, expires_at, password_hash, clicks_left, not_before, not_after, fallback_url, schedule,
+		tags, collection_id, domain, forward_query, forward_path)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), ?, ?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer stmt.Close()
+
+	ids := make([]string, len(links))
+	for i, link := range links {
+		if err := checkCollection(tx, link.CollectionID, link.Creator); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		if err := checkDomain(tx, link.Domain, link.Creator); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		ids[i] = uuid.New().String()
+		passwordHash, err := storage.HashPassword(link.Password)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+		_, err = stmt.ExecContext(ctx, ids[i], link.URL, storage.NormalizeURL(link.URL), link.Alias, link.Creator, utc(link.ExpiresAt), passwordHash, link.ClicksLeft,
+			utc(link.NotBefore), utc(link.NotAfter), link.FallbackURL, link.Schedule, tags(link.Tags), link.CollectionID, link.Domain,
+			link.Passthrough.Query, link.Passthrough.Path)
+		if err != nil {
+			if isUniqueViolation(err) {
+				err = fmt.Errorf("%s, %w", link.Key(), storage.ErrURLExists)
+			}
+			return nil, fmt.Errorf("%s: %w", info, &storage.ItemError{Index: i, Err: err})
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return ids, nil
+}
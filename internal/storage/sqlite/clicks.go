@@ -0,0 +1,133 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// dayLayout is how click_rollups_daily_dimensions stores days
+const dayLayout = "2006-01-02"
+
+// SaveClicks inserts click events and adds them to the rollup tables in a single transaction.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const info = "storage.sqlite.SaveClicks"
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO clicks(alias, clicked_at, referrer, user_agent, ip)
+	VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("%s: %w", info, err)
+	}
+	defer stmt.Close()
+
+	for _, c := range clicks {
+		_, err = stmt.ExecContext(ctx, c.Alias, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.IP)
+		if err != nil {
+			return fmt.Errorf("%s: failed to insert click: %w", info, err)
+		}
+	}
+
+	hourly, dimensions := storage.RollupClicks(clicks)
+	for _, h := range hourly {
+		_, err = tx.ExecContext(ctx, `INSERT INTO click_rollups_hourly(alias, bucket, clicks) VALUES (?, ?, ?)
+		ON CONFLICT (alias, bucket) DO UPDATE SET clicks = clicks + excluded.clicks`,
+			h.Alias, h.Hour.Unix(), h.Clicks)
+		if err != nil {
+			return fmt.Errorf("%s: failed to update rollups: %w", info, err)
+		}
+	}
+	for _, d := range dimensions {
+		_, err = tx.ExecContext(ctx, `INSERT INTO click_rollups_daily_dimensions(alias, day, dimension, value, clicks) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (alias, day, dimension, value) DO UPDATE SET clicks = clicks + excluded.clicks`,
+			d.Alias, d.Day.Format(dayLayout), d.Dimension, d.Value, d.Clicks)
+		if err != nil {
+			return fmt.Errorf("%s: failed to update rollups: %w", info, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ClickStats aggregates clicks on alias in [from, to) from the rollup tables. Only the unique
+// visitors count reads raw click events. Top lists are computed over whole UTC days.
+func (s *Storage) ClickStats(ctx context.Context, alias string, from, to time.Time, top int) (storage.ClickStats, error) {
+	const info = "storage.sqlite.ClickStats"
+	var stats storage.ClickStats
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT bucket, clicks FROM click_rollups_hourly
+	WHERE alias = ? AND bucket >= ? AND bucket < ? ORDER BY bucket`, alias, from.Unix(), to.Unix())
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket int64
+		var b storage.ClickBucket
+		if err = rows.Scan(&bucket, &b.Clicks); err != nil {
+			return stats, fmt.Errorf("%s: %w", info, err)
+		}
+		b.Start = time.Unix(bucket, 0).UTC()
+		stats.Hourly = append(stats.Hourly, b)
+		stats.Total += b.Clicks
+	}
+	if err = rows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	err = s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT DISTINCT ip, user_agent FROM clicks
+	WHERE alias = ? AND clicked_at >= ? AND clicked_at < ?)`, alias, from.UTC(), to.UTC()).Scan(&stats.UniqueVisitors)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	firstDay, lastDay := storage.DayRange(from, to)
+	if stats.TopReferrers, err = s.topValues(ctx, alias, storage.DimensionReferrer, firstDay, lastDay, top); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	if stats.TopUserAgents, err = s.topValues(ctx, alias, storage.DimensionUserAgent, firstDay, lastDay, top); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	return stats, nil
+}
+
+func (s *Storage) topValues(ctx context.Context, alias, dimension string, firstDay, lastDay time.Time, top int) ([]storage.ValueCount, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT value, SUM(clicks) FROM click_rollups_daily_dimensions
+	WHERE alias = ? AND dimension = ? AND day >= ? AND day <= ?
+	GROUP BY value ORDER BY 2 DESC, value LIMIT ?`,
+		alias, dimension, firstDay.Format(dayLayout), lastDay.Format(dayLayout), top)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []storage.ValueCount
+	for rows.Next() {
+		var v storage.ValueCount
+		if err := rows.Scan(&v.Value, &v.Clicks); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// dropClicks deletes the clicks and rollups of the links where matches, url aliased as u. Purges
+// call it before deleting the links, so the next link to take a freed alias starts without stats.
+func dropClicks(ctx context.Context, tx *sql.Tx, where string, args ...any) error {
+	for _, table := range []string{"clicks", "click_rollups_hourly", "click_rollups_daily_dimensions"} {
+		stmt := fmt.Sprintf(`DELETE FROM %s WHERE alias IN (SELECT %s FROM url u WHERE %s)`, table, linkKey, where)
+		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("failed to drop %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// CreateCollection saves a new collection and returns it with its id, storage.ErrCollectionExists
+// if the owner has one of the same name.
+func (s *Storage) CreateCollection(c storage.Collection) (storage.Collection, error) {
+	const info = "storage.sqlite.CreateCollection"
+	c.ID = uuid.New().String()
+	err := s.DB.QueryRow(`INSERT INTO collections(id, owner, name, description) VALUES (?, ?, ?, ?)
+	RETURNING createdAt`, c.ID, c.Owner, c.Name, c.Description).Scan(&c.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, c.Name, storage.ErrCollectionExists)
+		}
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return c, nil
+}
+
+// ListCollections returns the collections of owner ordered by name, with the number of their live links.
+func (s *Storage) ListCollections(owner string) ([]storage.CollectionSummary, error) {
+	const info = "storage.sqlite.ListCollections"
+	rows, err := s.DB.Query(`SELECT c.id, c.owner, c.name, c.description, c.createdAt,
+		(SELECT COUNT(*) FROM url u WHERE u.collection_id = c.id AND u.deleted_at IS NULL)
+	FROM collections c WHERE c.owner = ? ORDER BY c.name`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer rows.Close()
+
+	var collections []storage.CollectionSummary
+	for rows.Next() {
+		var c storage.CollectionSummary
+		if err := rows.Scan(&c.ID, &c.Owner, &c.Name, &c.Description, &c.CreatedAt, &c.Links); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, err)
+		}
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return collections, nil
+}
+
+// GetCollection returns the collection id of owner, storage.ErrCollectionNotFound if owner has no such collection.
+func (s *Storage) GetCollection(id, owner string) (storage.Collection, error) {
+	const info = "storage.sqlite.GetCollection"
+	var c storage.Collection
+	err := s.DB.QueryRow(`SELECT id, owner, name, description, createdAt FROM collections WHERE id = ? AND owner = ?`, id, owner).
+		Scan(&c.ID, &c.Owner, &c.Name, &c.Description, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+		}
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return c, nil
+}
+
+// UpdateCollection changes the name or description of the collection id of owner and returns it.
+func (s *Storage) UpdateCollection(id, owner string, upd storage.CollectionUpdate) (storage.Collection, error) {
+	const info = "storage.sqlite.UpdateCollection"
+
+	sets := []string{"name = name"}
+	var args []any
+	if upd.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *upd.Name)
+	}
+	if upd.Description != nil {
+		sets = append(sets, "description = ?")
+		args = append(args, *upd.Description)
+	}
+	args = append(args, id, owner)
+
+	var c storage.Collection
+	err := s.DB.QueryRow(fmt.Sprintf(`UPDATE collections SET %s WHERE id = ? AND owner = ?
+	RETURNING id, owner, name, description, createdAt`, strings.Join(sets, ", ")), args...).
+		Scan(&c.ID, &c.Owner, &c.Name, &c.Description, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+		}
+		if isUniqueViolation(err) {
+			return storage.Collection{}, fmt.Errorf("%s: %s, %w", info, *upd.Name, storage.ErrCollectionExists)
+		}
+		return storage.Collection{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return c, nil
+}
+
+// DeleteCollection deletes the collection id of owner, its links stay without a collection.
+func (s *Storage) DeleteCollection(id, owner string) (bool, error) {
+	const info = "storage.sqlite.DeleteCollection"
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM collections WHERE id = ? AND owner = ?`, id, owner)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if rows == 0 {
+		return false, fmt.Errorf("%s: %s, %w", info, id, storage.ErrCollectionNotFound)
+	}
+	// url.collection_id has no foreign key to clear it
+	if _, err = tx.Exec(`UPDATE url SET collection_id = NULL WHERE collection_id = ?`, id); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	return true, nil
+}
+
+// CollectionStats aggregates clicks on the live links of collection id in [from, to) from the hourly rollups.
+func (s *Storage) CollectionStats(ctx context.Context, id string, from, to time.Time, top int) (storage.CollectionStats, error) {
+	const info = "storage.sqlite.CollectionStats"
+	var stats storage.CollectionStats
+
+	err := s.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM url WHERE collection_id = ? AND deleted_at IS NULL`, id).
+		Scan(&stats.Links)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	rows, err := s.DB.QueryContext(ctx, `SELECT r.bucket, SUM(r.clicks) FROM click_rollups_hourly r
+	JOIN url u ON r.alias = `+linkKey+`
+	WHERE u.collection_id = ? AND u.deleted_at IS NULL AND r.bucket >= ? AND r.bucket < ?
+	GROUP BY r.bucket ORDER BY r.bucket`, id, from.Unix(), to.Unix())
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket int64
+		var b storage.ClickBucket
+		if err = rows.Scan(&bucket, &b.Clicks); err != nil {
+			return stats, fmt.Errorf("%s: %w", info, err)
+		}
+		b.Start = time.Unix(bucket, 0).UTC()
+		stats.Hourly = append(stats.Hourly, b)
+		stats.Total += b.Clicks
+	}
+	if err = rows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+
+	topRows, err := s.DB.QueryContext(ctx, `SELECT r.alias, SUM(r.clicks) FROM click_rollups_hourly r
+	JOIN url u ON r.alias = `+linkKey+`
+	WHERE u.collection_id = ? AND u.deleted_at IS NULL AND r.bucket >= ? AND r.bucket < ?
+	GROUP BY r.alias ORDER BY 2 DESC, r.alias LIMIT ?`, id, from.Unix(), to.Unix(), top)
+	if err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	defer topRows.Close()
+	for topRows.Next() {
+		var v storage.ValueCount
+		if err = topRows.Scan(&v.Value, &v.Clicks); err != nil {
+			return stats, fmt.Errorf("%s: %w", info, err)
+		}
+		stats.TopLinks = append(stats.TopLinks, v)
+	}
+	if err = topRows.Err(); err != nil {
+		return stats, fmt.Errorf("%s: %w", info, err)
+	}
+	return stats, nil
+}
+
+// rowQuerier is the database or a transaction.
+type rowQuerier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// checkCollection makes sure links of creator can be put in collection id, storage.ErrCollectionNotFound
+// if it belongs to someone else. An empty id is no collection and always passes.
+func checkCollection(q rowQuerier, id, creator string) error {
+	if id == "" {
+		return nil
+	}
+	var ok bool
+	err := q.QueryRow(`SELECT EXISTS(SELECT 1 FROM collections WHERE id = ? AND owner = ?)`, id, creator).Scan(&ok)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s, %w", id, storage.ErrCollectionNotFound)
+	}
+	return nil
+}
+
+// tags stores link tags as a json array, queries look into it with json_each.
+type tags []string
+
+func (t tags) Value() (driver.Value, error) {
+	raw, err := json.Marshal(storage.NormalizeTags(t))
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
+
+func (t *tags) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	default:
+		return fmt.Errorf("can't scan %T into tags", src)
+	}
+}
@@ -0,0 +1,175 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+	"url_shortener/internal/storage"
+)
+
+// linkKey is storage.Key of the url row u, the alias clicks of the link are rolled up under.
+const linkKey = `CASE WHEN u.domain = '' THEN u.alias ELSE u.domain || '/' || u.alias END`
+
+// CreateDomain claims a custom host for the owner, unverified. Unverified claims of other users
+// without links are taken over, storage.ErrDomainExists if the host is taken otherwise.
+func (s *Storage) CreateDomain(d storage.Domain) (storage.Domain, error) {
+	const info = "storage.sqlite.CreateDomain"
+	d.Host = storage.NormalizeHost(d.Host)
+	err := s.DB.QueryRow(`INSERT INTO domains(host, owner) VALUES (?, ?)
+	ON CONFLICT(host) DO UPDATE SET owner = excluded.owner, createdAt = CURRENT_TIMESTAMP
+	WHERE domains.owner <> excluded.owner AND `+claimable+`
+	RETURNING createdAt`, d.Host, d.Owner).
+		Scan(&d.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Domain{}, fmt.Errorf("%s: %s, %w", info, d.Host, storage.ErrDomainExists)
+		}
+		return storage.Domain{}, fmt.Errorf("%s: %w", info, err)
+	}
+	d.VerifiedAt = nil
+	return d, nil
+}
+
+// VerifyDomain marks host verified for owner, once owner proved control of it. A host that is not
+// claimed yet or claimed but unverified by someone else without links becomes owner's,
+// storage.ErrDomainExists if another user holds it.
+func (s *Storage) VerifyDomain(host, owner string) (storage.Domain, error) {
+	const info = "storage.sqlite.VerifyDomain"
+	d := storage.Domain{Host: storage.NormalizeHost(host), Owner: owner}
+	var verifiedAt sql.NullTime
+	err := s.DB.QueryRow(`INSERT INTO domains(host, owner, verified_at) VALUES (?, ?, ?)
+	ON CONFLICT(host) DO UPDATE SET owner = excluded.owner,
+		createdAt = CASE WHEN domains.owner = excluded.owner THEN domains.createdAt ELSE CURRENT_TIMESTAMP END,
+		verified_at = COALESCE(domains.verified_at, excluded.verified_at)
+	WHERE domains.owner = excluded.owner OR `+claimable+`
+	RETURNING createdAt, verified_at`, d.Host, d.Owner, time.Now().UTC()).
+		Scan(&d.CreatedAt, &verifiedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Domain{}, fmt.Errorf("%s: %s, %w", info, d.Host, storage.ErrDomainExists)
+		}
+		return storage.Domain{}, fmt.Errorf("%s: %w", info, err)
+	}
+	d.VerifiedAt = &verifiedAt.Time
+	return d, nil
+}
+
+// claimable holds for a domains row another user may take over: unverified and without links,
+// which only domains registered before verification existed can have.
+const claimable = `domains.verified_at IS NULL AND NOT EXISTS(SELECT 1 FROM url WHERE url.domain = domains.host)`
+
+// ListDomains returns the domains of owner ordered by host.
+func (s *Storage) ListDomains(owner string) ([]storage.Domain, error) {
+	const info = "storage.sqlite.ListDomains"
+	rows, err := s.DB.Query(`SELECT host, owner, createdAt, verified_at FROM domains WHERE owner = ? ORDER BY host`, owner)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer rows.Close()
+
+	var domains []storage.Domain
+	for rows.Next() {
+		var d storage.Domain
+		var verifiedAt sql.NullTime
+		if err := rows.Scan(&d.Host, &d.Owner, &d.CreatedAt, &verifiedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, err)
+		}
+		if verifiedAt.Valid {
+			d.VerifiedAt = &verifiedAt.Time
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return domains, nil
+}
+
+// DeleteDomain removes the domain host of owner. Domains with links, trashed ones included, are kept
+// with storage.ErrDomainInUse: deleting it would hand their aliases over to the default domain.
+func (s *Storage) DeleteDomain(host, owner string) (bool, error) {
+	const info = "storage.sqlite.DeleteDomain"
+	host = storage.NormalizeHost(host)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	var inUse bool
+	if err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM url WHERE domain = ?)`, host).Scan(&inUse); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	result, err := tx.Exec(`DELETE FROM domains WHERE host = ? AND owner = ? AND NOT ?`, host, owner, inUse)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if rows == 0 {
+		if _, err = ownDomain(tx, host, owner); err != nil {
+			return false, fmt.Errorf("%s: %w", info, err)
+		}
+		return false, fmt.Errorf("%s: %s, %w", info, host, storage.ErrDomainInUse)
+	}
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	return true, nil
+}
+
+// DomainHosts returns the hosts of all verified domains.
+func (s *Storage) DomainHosts(ctx context.Context) ([]string, error) {
+	const info = "storage.sqlite.DomainHosts"
+	rows, err := s.DB.QueryContext(ctx, `SELECT host FROM domains WHERE verified_at IS NOT NULL`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer rows.Close()
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, err)
+		}
+		hosts = append(hosts, host)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return hosts, nil
+}
+
+// checkDomain makes sure links of creator can be put on domain, storage.ErrDomainNotFound if it is
+// not registered or belongs to someone else, storage.ErrDomainNotVerified if it is not verified yet.
+// The default domain always passes.
+func checkDomain(q rowQuerier, domain, creator string) error {
+	if domain == "" {
+		return nil
+	}
+	verified, err := ownDomain(q, domain, creator)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		return fmt.Errorf("%s, %w", domain, storage.ErrDomainNotVerified)
+	}
+	return nil
+}
+
+// ownDomain reports whether the domain of owner is verified, storage.ErrDomainNotFound if owner
+// has no such domain.
+func ownDomain(q rowQuerier, domain, owner string) (bool, error) {
+	var verified bool
+	err := q.QueryRow(`SELECT verified_at IS NOT NULL FROM domains WHERE host = ? AND owner = ?`, domain, owner).Scan(&verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("%s, %w", domain, storage.ErrDomainNotFound)
+	}
+	return verified, err
+}
@@ -0,0 +1,109 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"modernc.org/sqlite"
+	"strings"
+	"url_shortener/internal/storage"
+)
+
+func init() {
+	// url_host(url) gives queries the same host extraction as storage.TargetHost
+	sqlite.MustRegisterDeterministicScalarFunction("url_host", 1,
+		func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			target, _ := args[0].(string)
+			return storage.TargetHost(target), nil
+		})
+}
+
+// ListURLs returns a page of the creator's links with their click counts taken from the hourly rollups.
+func (s *Storage) ListURLs(ctx context.Context, q storage.ListQuery) ([]storage.LinkSummary, error) {
+	const info = "storage.sqlite.ListURLs"
+
+	args := []any{q.Creator}
+	filters := []string{"u.creator = ?", "u.deleted_at IS NULL"}
+	if q.Deleted {
+		filters[1] = "u.deleted_at IS NOT NULL"
+	}
+	if q.Domain != "" {
+		filters = append(filters, `url_host(u.url) LIKE '%' || ? || '%'`)
+		args = append(args, strings.ToLower(q.Domain))
+	}
+	for _, tag := range q.Tags {
+		filters = append(filters, "EXISTS (SELECT 1 FROM json_each(u.tags) WHERE value = ?)")
+		args = append(args, tag)
+	}
+	if q.Collection != "" {
+		filters = append(filters, "u.collection_id = ?")
+		args = append(args, q.Collection)
+	}
+	// createdAt is stored with second precision, datetime() compares it with time parameters as text
+	if q.CreatedFrom != nil {
+		filters = append(filters, "datetime(u.createdAt) >= datetime(?)")
+		args = append(args, q.CreatedFrom.UTC())
+	}
+	if q.CreatedTo != nil {
+		filters = append(filters, "datetime(u.createdAt) < datetime(?)")
+		args = append(args, q.CreatedTo.UTC())
+	}
+
+	sortColumn, order, cmp := "datetime(createdAt)", "ASC", ">"
+	if q.SortBy == storage.SortClicks {
+		sortColumn = "clicks"
+	}
+	if q.Desc {
+		order, cmp = "DESC", "<"
+	}
+
+	page := ""
+	if q.After != nil {
+		if q.SortBy == storage.SortClicks {
+			page = fmt.Sprintf("WHERE (clicks, id) %s (?, ?)", cmp)
+			args = append(args, q.After.Clicks, q.After.ID)
+		} else {
+			page = fmt.Sprintf("WHERE (datetime(createdAt), id) %s (datetime(?), ?)", cmp)
+			args = append(args, q.After.CreatedAt.UTC(), q.After.ID)
+		}
+	}
+	args = append(args, q.Limit)
+
+	stmt := fmt.Sprintf(`SELECT id, alias, url, creator, createdAt, expires_at, deleted_at, tags, collection_id, domain, forward_query, forward_path, password_hash, clicks FROM (
+		SELECT u.id, u.alias, u.url, u.creator, u.createdAt, u.expires_at, u.deleted_at, u.tags,
+			COALESCE(u.collection_id, '') AS collection_id, u.domain, u.forward_query, u.forward_path,
+			COALESCE(u.password_hash, '') AS password_hash,
+			COALESCE((SELECT SUM(r.clicks) FROM click_rollups_hourly r WHERE r.alias = `+linkKey+`), 0) AS clicks
+		FROM url u WHERE %s
+	) links %s
+	ORDER BY %s %s, id %s LIMIT ?`,
+		strings.Join(filters, " AND "), page, sortColumn, order, order)
+
+	rows, err := s.DB.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	defer rows.Close()
+
+	var links []storage.LinkSummary
+	for rows.Next() {
+		var l storage.LinkSummary
+		var expiresAt, deletedAt sql.NullTime
+		if err := rows.Scan(&l.ID, &l.Alias, &l.URL, &l.Creator, &l.CreatedAt, &expiresAt, &deletedAt, (*tags)(&l.Tags), &l.CollectionID, &l.Domain,
+			&l.Passthrough.Query, &l.Passthrough.Path, &l.Password, &l.Clicks); err != nil {
+			return nil, fmt.Errorf("%s: %w", info, err)
+		}
+		if expiresAt.Valid {
+			l.ExpiresAt = &expiresAt.Time
+		}
+		if deletedAt.Valid {
+			l.DeletedAt = &deletedAt.Time
+		}
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", info, err)
+	}
+	return links, nil
+}
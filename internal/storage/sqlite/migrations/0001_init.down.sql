@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS url;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,14 @@
+ALTER TABLE url ADD COLUMN expires_at TIMESTAMP;
+
+CREATE INDEX idx_url_expires_at ON url(expires_at) WHERE expires_at IS NOT NULL;
+
+-- expired links are moved here by the sweeper when archiving is enabled
+CREATE TABLE url_archive (
+    id TEXT PRIMARY KEY,
+    alias TEXT NOT NULL,
+    url TEXT NOT NULL,
+    creator TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP,
+    archived_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
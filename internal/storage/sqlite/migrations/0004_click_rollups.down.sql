@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS click_rollups_daily_dimensions;
+DROP TABLE IF EXISTS click_rollups_hourly;
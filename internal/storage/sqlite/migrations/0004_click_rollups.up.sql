@@ -0,0 +1,29 @@
+-- precomputed click counts, kept up to date by SaveClicks; bucket is the unix time of the
+-- UTC hour, day is the UTC date as YYYY-MM-DD
+CREATE TABLE click_rollups_hourly (
+    alias TEXT NOT NULL,
+    bucket INTEGER NOT NULL,
+    clicks INTEGER NOT NULL,
+    PRIMARY KEY (alias, bucket)
+);
+
+CREATE TABLE click_rollups_daily_dimensions (
+    alias TEXT NOT NULL,
+    day TEXT NOT NULL,
+    dimension TEXT NOT NULL,                 -- referrer, user_agent
+    value TEXT NOT NULL,
+    clicks INTEGER NOT NULL,
+    PRIMARY KEY (alias, day, dimension, value)
+);
+
+INSERT INTO click_rollups_hourly(alias, bucket, clicks)
+SELECT alias, CAST(strftime('%s', clicked_at) AS INTEGER) / 3600 * 3600, COUNT(*)
+FROM clicks GROUP BY 1, 2;
+
+INSERT INTO click_rollups_daily_dimensions(alias, day, dimension, value, clicks)
+SELECT alias, date(clicked_at), 'referrer', referrer, COUNT(*)
+FROM clicks GROUP BY 1, 2, 4;
+
+INSERT INTO click_rollups_daily_dimensions(alias, day, dimension, value, clicks)
+SELECT alias, date(clicked_at), 'user_agent', user_agent, COUNT(*)
+FROM clicks GROUP BY 1, 2, 4;
@@ -0,0 +1,5 @@
+-- source of sequential aliases, see random.Sequence; AUTOINCREMENT never hands out an id twice,
+-- even after the rows are deleted
+CREATE TABLE alias_seq (
+    id INTEGER PRIMARY KEY AUTOINCREMENT
+);
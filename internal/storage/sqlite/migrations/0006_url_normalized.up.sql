@@ -0,0 +1,7 @@
+-- normalized destination, see storage.NormalizeURL; rows from before this migration get the raw
+-- url, which still matches exact duplicates
+ALTER TABLE url ADD COLUMN url_normalized TEXT;
+
+UPDATE url SET url_normalized = url;
+
+CREATE INDEX idx_url_creator_normalized ON url(creator, url_normalized);
@@ -0,0 +1,2 @@
+-- redirects left for links created with max_clicks, NULL for links without a limit
+ALTER TABLE url ADD COLUMN clicks_left INTEGER;
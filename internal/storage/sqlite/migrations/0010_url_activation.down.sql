@@ -0,0 +1,4 @@
+ALTER TABLE url DROP COLUMN schedule;
+ALTER TABLE url DROP COLUMN fallback_url;
+ALTER TABLE url DROP COLUMN not_after;
+ALTER TABLE url DROP COLUMN not_before;
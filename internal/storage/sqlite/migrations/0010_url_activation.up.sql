@@ -0,0 +1,6 @@
+-- links redirect only between not_before and not_after, sending visitors to fallback_url outside that window
+ALTER TABLE url ADD COLUMN not_before TIMESTAMP;
+ALTER TABLE url ADD COLUMN not_after TIMESTAMP;
+ALTER TABLE url ADD COLUMN fallback_url TEXT;
+-- [{"at": ..., "url": ...}] ordered by at, the destination switches to url from at on
+ALTER TABLE url ADD COLUMN schedule TEXT;
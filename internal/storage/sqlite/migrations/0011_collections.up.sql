@@ -0,0 +1,16 @@
+-- named groups of links of a user, a link belongs to at most one collection
+CREATE TABLE collections (
+    id TEXT PRIMARY KEY,
+    owner TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (owner, name)
+);
+
+-- no foreign key: sqlite can't drop a column that has one, DeleteCollection clears it instead
+ALTER TABLE url ADD COLUMN collection_id TEXT;
+-- json array of free-form labels, lower case, sorted and without duplicates
+ALTER TABLE url ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+
+CREATE INDEX idx_url_collection_id ON url(collection_id) WHERE collection_id IS NOT NULL;
@@ -0,0 +1,39 @@
+-- aliases are global again, links of custom domains would clash with the default one
+CREATE TABLE url_new (
+    id TEXT PRIMARY KEY,
+    alias TEXT NOT NULL UNIQUE,
+    url TEXT NOT NULL,
+    creator TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    url_normalized TEXT,
+    deleted_at TIMESTAMP,
+    password_hash TEXT,
+    clicks_left INTEGER,
+    not_before TIMESTAMP,
+    not_after TIMESTAMP,
+    fallback_url TEXT,
+    schedule TEXT,
+    collection_id TEXT,
+    tags TEXT NOT NULL DEFAULT '[]',
+    FOREIGN KEY (creator) REFERENCES users(id) ON DELETE CASCADE
+);
+
+INSERT INTO url_new(id, alias, url, creator, createdAt, expires_at, url_normalized, deleted_at, password_hash,
+    clicks_left, not_before, not_after, fallback_url, schedule, collection_id, tags)
+SELECT id, alias, url, creator, createdAt, expires_at, url_normalized, deleted_at, password_hash,
+    clicks_left, not_before, not_after, fallback_url, schedule, collection_id, tags
+FROM url WHERE domain = '';
+
+DROP TABLE url;
+ALTER TABLE url_new RENAME TO url;
+
+CREATE INDEX idx_alias ON url(alias);
+CREATE INDEX idx_url_expires_at ON url(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX idx_url_creator_normalized ON url(creator, url_normalized);
+CREATE INDEX idx_url_deleted_at ON url(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_url_collection_id ON url(collection_id) WHERE collection_id IS NOT NULL;
+
+ALTER TABLE url_archive DROP COLUMN domain;
+
+DROP TABLE domains;
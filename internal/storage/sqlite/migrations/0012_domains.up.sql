@@ -0,0 +1,47 @@
+-- custom hosts serving their own aliases, stored lower case without a port
+CREATE TABLE domains (
+    host TEXT PRIMARY KEY,
+    owner TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- sqlite can't drop the unique constraint on alias, the table is rebuilt with one on (domain, alias);
+-- '' is the default domain, any host that is not registered
+CREATE TABLE url_new (
+    id TEXT PRIMARY KEY,
+    alias TEXT NOT NULL,
+    url TEXT NOT NULL,
+    creator TEXT NOT NULL,
+    createdAt TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    url_normalized TEXT,
+    deleted_at TIMESTAMP,
+    password_hash TEXT,
+    clicks_left INTEGER,
+    not_before TIMESTAMP,
+    not_after TIMESTAMP,
+    fallback_url TEXT,
+    schedule TEXT,
+    collection_id TEXT,
+    tags TEXT NOT NULL DEFAULT '[]',
+    domain TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (creator) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (domain, alias)
+);
+
+INSERT INTO url_new(id, alias, url, creator, createdAt, expires_at, url_normalized, deleted_at, password_hash,
+    clicks_left, not_before, not_after, fallback_url, schedule, collection_id, tags)
+SELECT id, alias, url, creator, createdAt, expires_at, url_normalized, deleted_at, password_hash,
+    clicks_left, not_before, not_after, fallback_url, schedule, collection_id, tags
+FROM url;
+
+DROP TABLE url;
+ALTER TABLE url_new RENAME TO url;
+
+CREATE INDEX idx_alias ON url(alias);
+CREATE INDEX idx_url_expires_at ON url(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX idx_url_creator_normalized ON url(creator, url_normalized);
+CREATE INDEX idx_url_deleted_at ON url(deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX idx_url_collection_id ON url(collection_id) WHERE collection_id IS NOT NULL;
+
+ALTER TABLE url_archive ADD COLUMN domain TEXT NOT NULL DEFAULT '';
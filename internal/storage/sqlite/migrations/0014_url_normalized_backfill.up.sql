@@ -0,0 +1,4 @@
+-- 0006 gave older rows their raw url as url_normalized, so dedup did not find them. The data step
+-- of this migration normalizes every row in Go, see storage.NormalizeURL; this only covers rows
+-- that somehow have none.
+UPDATE url SET url_normalized = url WHERE url_normalized IS NULL;
@@ -0,0 +1,465 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"io/fs"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"time"
+	"url_shortener/httpServer/handlers/login"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+type Storage struct {
+	DB *sql.DB
+}
+
+// NewStorage opens (or creates) the sqlite database file at storagePath. The schema is managed by Migrator.
+func NewStorage(ctx context.Context, storagePath string) (*Storage, error) {
+	const op = "storage.sqlite.NewStorage"
+
+	// _time_format=sqlite writes times in a layout that sqlite date functions can parse
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_time_format=sqlite", storagePath))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	// sqlite allows a single writer, serialize access instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{DB: db}, nil
+}
+
+// Close closes the database file.
+func (s *Storage) Close() error {
+	return s.DB.Close()
+}
+
+// Migrator returns a migrator over the embedded sqlite migrations. sqlite allows a single
+// writer, so no extra lock is needed.
+func (s *Storage) Migrator() (*migrate.Migrator, func() error, error) {
+	migrations, err := fs.Sub(migrationsFS, "migrations")
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := migrate.New(s.DB, migrations, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.Data(14, normalizeURLs); err != nil {
+		return nil, nil, err
+	}
+	// the migrator shares s.DB, it goes away with the storage
+	return m, func() error { return nil }, nil
+}
+
+// normalizeURLs is the data step of migration 0014: url_normalized of every row is set to
+// storage.NormalizeURL of its url, rows from before 0006 only had their raw url.
+func normalizeURLs(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, url, COALESCE(url_normalized, '') FROM url`)
+	if err != nil {
+		return err
+	}
+	// read everything first, the rows can't be updated while the query is open
+	stale := make(map[string]string)
+	for rows.Next() {
+		var id, target, normalized string
+		if err := rows.Scan(&id, &target, &normalized); err != nil {
+			rows.Close()
+			return err
+		}
+		if n := storage.NormalizeURL(target); n != normalized {
+			stale[id] = n
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE url SET url_normalized = ? WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for id, normalized := range stale {
+		if _, err := stmt.ExecContext(ctx, normalized, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) CreateUser(user login.User) error {
+	const info = "internal.storage.sqlite.CreateUser"
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("%s: failed to hash pass: %w", info, err)
+	}
+	stmt := `INSERT INTO users(id, username, password)
+	VALUES (?, ?, ?);`
+
+	_, err = s.DB.Exec(stmt, user.ID, user.Username, hashedPassword)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %s, %w", info, user.Username, storage.ErrUserExists)
+		}
+		return fmt.Errorf("%s: failed to insert new user: %w", info, err)
+	}
+	return nil
+}
+
+func (s *Storage) GetUserByUsername(username string) (login.User, error) {
+	var user login.User
+	row := s.DB.QueryRow(`SELECT id, username, password FROM users WHERE username = ?`, username)
+	err := row.Scan(&user.ID, &user.Username, &user.Password)
+	if err != nil {
+		return login.User{}, err
+	}
+
+	return user, nil
+}
+
+// SaveURL - save link in database, unique constraint violations are reported as storage.ErrURLExists
+func (s *Storage) SaveURL(link storage.Link) (string, error) {
+	const info = "storage.sqlite.SaveURL"
+	id := uuid.New().String()
+	passwordHash, err := storage.HashPassword(link.Password)
+	if err != nil {
+		return "", fmt.Errorf("%s: failed to hash pass: %w", info, err)
+	}
+	// the collection or domain can't be deleted between the check and the insert
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	if err := checkCollection(tx, link.CollectionID, link.Creator); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	if err := checkDomain(tx, link.Domain, link.Creator); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	stmt := `INSERT INTO url(id, url, url_normalized, alias, creator, expires_at, password_hash, clicks_left,
+		not_before, not_after, fallback_url, schedule, tags, collection_id, domain, forward_query, forward_path)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, NULLIF(?, ''), ?, ?, ?);`
+	_, err = tx.Exec(stmt, id, link.URL, storage.NormalizeURL(link.URL), link.Alias, link.Creator, utc(link.ExpiresAt), passwordHash, link.ClicksLeft,
+		utc(link.NotBefore), utc(link.NotAfter), link.FallbackURL, link.Schedule, tags(link.Tags), link.CollectionID, link.Domain,
+		link.Passthrough.Query, link.Passthrough.Path)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return "", fmt.Errorf("%s: %s, %w", info, link.Key(), storage.ErrURLExists)
+		}
+		return "", fmt.Errorf("%s: failed to insert entry: %w", info, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	return id, nil
+}
+
+// NextAliasID returns the next value of the alias sequence. Only the latest row is kept in
+// alias_seq, AUTOINCREMENT remembers the high-water mark on its own.
+func (s *Storage) NextAliasID() (int64, error) {
+	const info = "storage.sqlite.NextAliasID"
+	res, err := s.DB.Exec(`INSERT INTO alias_seq DEFAULT VALUES`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	if _, err := s.DB.Exec(`DELETE FROM alias_seq WHERE id < ?`, id); err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	return id, nil
+}
+
+// GetURL returns the target of alias, storage.ErrURLExpired if the link has expired but is not purged yet.
+// Links in the trash are not found, links that can't redirect right away give the errors of storage.Link.Target.
+func (s *Storage) GetURL(alias string) (string, error) {
+	const info = "storage.sqlite.GetURL"
+	link, err := s.GetLink(alias)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	url, err := link.Target(time.Now())
+	if err != nil {
+		// the fallback url of links outside their activation window comes along with the error
+		return url, fmt.Errorf("%s: %s, %w", info, alias, err)
+	}
+	return url, nil
+}
+
+// Passthrough returns what redirects of alias forward to its target, see storage.Passthrough.
+func (s *Storage) Passthrough(alias string) (storage.Passthrough, error) {
+	const info = "storage.sqlite.Passthrough"
+	link, err := s.GetLink(alias)
+	if err != nil {
+		return storage.Passthrough{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link.Passthrough, nil
+}
+
+// ConsumeClick takes one of the clicks left of a click-limited link and returns its target,
+// storage.ErrURLExhausted if none are left. The decrement is a single conditional update, so
+// concurrent redirects never go over the limit. Links without a limit just return their target.
+func (s *Storage) ConsumeClick(alias string) (string, error) {
+	const info = "storage.sqlite.ConsumeClick"
+	var link storage.Link
+	domain, name := storage.SplitKey(alias)
+	stmt := `UPDATE url SET clicks_left = clicks_left - 1
+	WHERE domain = ? AND alias = ? AND deleted_at IS NULL AND clicks_left > 0 AND (expires_at IS NULL OR datetime(expires_at) > datetime(?))
+	RETURNING url, schedule`
+	err := s.DB.QueryRow(stmt, domain, name, time.Now().UTC()).Scan(&link.URL, &link.Schedule)
+	if err == nil {
+		return link.Destination(time.Now()), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+
+	// nothing to take, find out why
+	link, err = s.GetLink(alias)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", info, err)
+	}
+	if link.Expired(time.Now()) {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLExpired)
+	}
+	if link.Limited() {
+		return "", fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLExhausted)
+	}
+	return link.Destination(time.Now()), nil
+}
+
+// GetLink returns the whole link row of alias, expired or not. Links in the trash are not found.
+func (s *Storage) GetLink(alias string) (storage.Link, error) {
+	const info = "storage.sqlite.GetLink"
+	var link storage.Link
+	domain, name := storage.SplitKey(alias)
+	var expiresAt, notBefore, notAfter sql.NullTime
+	var clicksLeft sql.NullInt64
+	stmt := `SELECT id, alias, url, creator, createdAt, expires_at, COALESCE(password_hash, ''), clicks_left,
+		not_before, not_after, COALESCE(fallback_url, ''), schedule, tags, COALESCE(collection_id, ''), domain,
+		forward_query, forward_path
+	FROM url WHERE domain = ? AND alias = ? AND deleted_at IS NULL`
+	err := s.DB.QueryRow(stmt, domain, name).
+		Scan(&link.ID, &link.Alias, &link.URL, &link.Creator, &link.CreatedAt, &expiresAt, &link.Password, &clicksLeft,
+			&notBefore, &notAfter, &link.FallbackURL, &link.Schedule, (*tags)(&link.Tags), &link.CollectionID, &link.Domain,
+			&link.Passthrough.Query, &link.Passthrough.Path)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrURLNotFound)
+		}
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	if clicksLeft.Valid {
+		link.ClicksLeft = &clicksLeft.Int64
+	}
+	if notBefore.Valid {
+		link.NotBefore = &notBefore.Time
+	}
+	if notAfter.Valid {
+		link.NotAfter = &notAfter.Time
+	}
+	return link, nil
+}
+
+// LinkByTarget returns the oldest live link of creator whose destination normalizes to the same
+// url as target, storage.ErrURLNotFound if there is none. Protected, click-limited and scheduled
+// links are left out, they can't stand in for a plain one, and so are links of custom domains and
+// links that forward the query string or path.
+func (s *Storage) LinkByTarget(creator, target string) (storage.Link, error) {
+	const info = "storage.sqlite.LinkByTarget"
+	var link storage.Link
+	var expiresAt sql.NullTime
+	stmt := `SELECT id, alias, url, creator, createdAt, expires_at FROM url
+	WHERE creator = ? AND url_normalized = ? AND deleted_at IS NULL AND password_hash IS NULL AND clicks_left IS NULL
+	AND not_before IS NULL AND not_after IS NULL AND schedule IS NULL AND domain = '' AND NOT forward_query AND NOT forward_path
+	AND (expires_at IS NULL OR datetime(expires_at) > datetime(?))
+	ORDER BY createdAt, id LIMIT 1`
+	err := s.DB.QueryRow(stmt, creator, storage.NormalizeURL(target), time.Now().UTC()).
+		Scan(&link.ID, &link.Alias, &link.URL, &link.Creator, &link.CreatedAt, &expiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, fmt.Errorf("%s: %s, %w", info, target, storage.ErrURLNotFound)
+		}
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	return link, nil
+}
+
+// PurgeExpired removes links that expired before the given moment, moving them to url_archive
+// when archive is set. Their clicks are dropped, so the next link of an alias starts without stats.
+// It returns the number of removed links.
+func (s *Storage) PurgeExpired(ctx context.Context, before time.Time, archive bool) (int64, error) {
+	const info = "storage.sqlite.PurgeExpired"
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	if archive {
+		_, err = tx.ExecContext(ctx, `INSERT INTO url_archive(id, alias, url, creator, createdAt, expires_at, domain)
+		SELECT id, alias, url, creator, createdAt, expires_at, domain FROM url WHERE expires_at < ?`, before.UTC())
+		if err != nil {
+			return 0, fmt.Errorf("%s: failed to archive: %w", info, err)
+		}
+	}
+	if err = dropClicks(ctx, tx, `u.expires_at < ?`, before.UTC()); err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM url WHERE expires_at < ?`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	return purged, tx.Commit()
+}
+
+// DeleteURL moves the link identified by the alias and creator to the trash. The alias stays taken
+// until PurgeDeleted removes the link.
+func (s *Storage) DeleteURL(alias, creator string) (bool, error) {
+	const info = "storage.sqlite.DeleteURL"
+
+	// Verify the alias exists and is case-sensitive
+	ok, err := s.CaseDifferent(alias)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if !ok {
+		return false, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrCaseMismatch)
+	}
+
+	domain, name := storage.SplitKey(alias)
+	stmt := `UPDATE url SET deleted_at = ? WHERE domain = ? AND alias = ? AND creator = ? AND deleted_at IS NULL`
+	result, err := s.DB.Exec(stmt, time.Now().UTC(), domain, name, creator)
+	if err != nil {
+		return false, fmt.Errorf("%s: failed to execute delete statement: %w", info, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", info, err)
+	}
+	if rows == 0 {
+		return false, fmt.Errorf("%s: no rows found for alias %s and creator %s, %w", info, alias, creator, storage.ErrAliasNotFound)
+	}
+
+	return true, nil
+}
+
+// RestoreURL takes the link identified by the alias and creator out of the trash,
+// storage.ErrAliasNotFound if the creator has no such link there.
+func (s *Storage) RestoreURL(alias, creator string) (storage.Link, error) {
+	const info = "storage.sqlite.RestoreURL"
+	domain, name := storage.SplitKey(alias)
+	stmt := `UPDATE url SET deleted_at = NULL WHERE domain = ? AND alias = ? AND creator = ? AND deleted_at IS NOT NULL`
+	result, err := s.DB.Exec(stmt, domain, name, creator)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	if rows == 0 {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrAliasNotFound)
+	}
+
+	link, err := s.GetLink(alias)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link, nil
+}
+
+// PurgeDeleted removes links that went to the trash before the given moment, freeing their aliases.
+// Their clicks go with them, so the next link of an alias starts without stats. It returns the
+// number of removed links.
+func (s *Storage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	const info = "storage.sqlite.PurgeDeleted"
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	defer tx.Rollback()
+
+	if err = dropClicks(ctx, tx, `datetime(u.deleted_at) < datetime(?)`, before.UTC()); err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM url WHERE datetime(deleted_at) < datetime(?)`, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", info, err)
+	}
+	return purged, tx.Commit()
+}
+
+// CaseDifferent checks if the alias exists in a case-sensitive manner.
+func (s *Storage) CaseDifferent(alias string) (bool, error) {
+	const info = "storage.sqlite.CaseDifferent"
+	domain, name := storage.SplitKey(alias)
+	stmt := `SELECT alias FROM url WHERE domain = ? AND alias = ? AND deleted_at IS NULL`
+
+	var foundAlias string
+	err := s.DB.QueryRow(stmt, domain, name).Scan(&foundAlias)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil // Alias does not exist, not an error
+		}
+		return false, fmt.Errorf("%s: query failed: %w", info, err)
+	}
+
+	return name == foundAlias, nil
+}
+
+// utc stores timestamps in UTC so that they compare correctly as text
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
@@ -0,0 +1,645 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+	"url_shortener/httpServer/handlers/login"
+	"url_shortener/internal/storage"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStorage(t *testing.T) *Storage {
+	s, err := NewStorage(context.Background(), filepath.Join(t.TempDir(), "storage.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+
+	migrator, closeMigrator, err := s.Migrator()
+	require.NoError(t, err)
+	defer closeMigrator()
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	return s
+}
+
+func TestStorage(t *testing.T) {
+	s := newTestStorage(t)
+
+	user := login.User{ID: "1", Username: "user", Password: "password123"}
+	require.NoError(t, s.CreateUser(user))
+	require.ErrorIs(t, s.CreateUser(user), storage.ErrUserExists)
+
+	got, err := s.GetUserByUsername("user")
+	require.NoError(t, err)
+	require.Equal(t, "1", got.ID)
+
+	_, err = s.GetUserByUsername("missing")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	_, err = s.SaveURL(storage.Link{URL: "https://google.com", Alias: "google", Creator: user.ID})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(storage.Link{URL: "https://bing.com", Alias: "google", Creator: user.ID})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	url, err := s.GetURL("google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url)
+
+	_, err = s.GetURL("missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = s.DeleteURL("Google", user.ID)
+	require.ErrorIs(t, err, storage.ErrCaseMismatch)
+
+	_, err = s.DeleteURL("google", "someone else")
+	require.ErrorIs(t, err, storage.ErrAliasNotFound)
+
+	ok, err := s.DeleteURL("google", user.ID)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestStorage_Expiration(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	now := time.Now()
+	expired := now.Add(-2 * time.Hour)
+	future := now.Add(time.Hour)
+	_, err := s.SaveURL(storage.Link{URL: "https://google.com", Alias: "expired", Creator: "1", ExpiresAt: &expired})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{URL: "https://google.com", Alias: "future", Creator: "1", ExpiresAt: &future})
+	require.NoError(t, err)
+
+	_, err = s.GetURL("expired")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+	_, err = s.GetURL("future")
+	require.NoError(t, err)
+
+	purged, err := s.PurgeExpired(context.Background(), now.Add(-time.Hour), true)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, purged)
+
+	var archived int
+	require.NoError(t, s.DB.QueryRow(`SELECT COUNT(*) FROM url_archive WHERE alias = 'expired'`).Scan(&archived))
+	require.Equal(t, 1, archived)
+
+	_, err = s.GetURL("expired")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_SaveClicks(t *testing.T) {
+	s := newTestStorage(t)
+
+	err := s.SaveClicks(context.Background(), []storage.Click{
+		{Alias: "google", ClickedAt: time.Now(), Referrer: "https://bing.com", UserAgent: "curl", IP: "10.0.0.0"},
+		{Alias: "google", ClickedAt: time.Now()},
+	})
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, s.DB.QueryRow(`SELECT COUNT(*) FROM clicks WHERE alias = 'google'`).Scan(&count))
+	require.Equal(t, 2, count)
+}
+
+func TestStorage_ClickStats(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	clickedAt := time.Date(2024, 1, 3, 10, 15, 0, 0, time.UTC)
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{
+		{Alias: "google", ClickedAt: clickedAt, Referrer: "https://bing.com", UserAgent: "curl", IP: "10.0.0.0"},
+		{Alias: "google", ClickedAt: clickedAt.Add(time.Minute), Referrer: "https://bing.com", UserAgent: "curl", IP: "10.0.0.0"},
+	}))
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{
+		{Alias: "google", ClickedAt: clickedAt.Add(26 * time.Hour), UserAgent: "firefox", IP: "10.0.1.0"},
+		{Alias: "bing", ClickedAt: clickedAt},
+	}))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stats, err := s.ClickStats(ctx, "google", from, from.AddDate(0, 0, 7), 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, stats.Total)
+	require.EqualValues(t, 2, stats.UniqueVisitors)
+	require.Equal(t, []storage.ClickBucket{
+		{Start: time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC), Clicks: 2},
+		{Start: time.Date(2024, 1, 4, 12, 0, 0, 0, time.UTC), Clicks: 1},
+	}, stats.Hourly)
+	require.Equal(t, storage.ValueCount{Value: "https://bing.com", Clicks: 2}, stats.TopReferrers[0])
+	require.Len(t, stats.TopUserAgents, 2)
+}
+
+func TestStorage_ListURLs(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+	require.NoError(t, s.CreateUser(login.User{ID: "2", Username: "other", Password: "password123"}))
+
+	for _, link := range []storage.Link{
+		{Alias: "a", URL: "https://google.com/search", Creator: "1"},
+		{Alias: "b", URL: "https://docs.google.com", Creator: "1"},
+		{Alias: "c", URL: "https://bing.com", Creator: "1"},
+		{Alias: "d", URL: "https://google.com", Creator: "2"},
+	} {
+		_, err := s.SaveURL(link)
+		require.NoError(t, err)
+	}
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{
+		{Alias: "b", ClickedAt: time.Now()}, {Alias: "b", ClickedAt: time.Now()}, {Alias: "c", ClickedAt: time.Now()},
+	}))
+
+	page, err := s.ListURLs(ctx, storage.ListQuery{Creator: "1", SortBy: storage.SortClicks, Desc: true, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, "b", page[0].Alias)
+	require.EqualValues(t, 2, page[0].Clicks)
+	require.Equal(t, "c", page[1].Alias)
+
+	after := page[1].Cursor()
+	page, err = s.ListURLs(ctx, storage.ListQuery{Creator: "1", SortBy: storage.SortClicks, Desc: true, Limit: 2, After: &after})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, "a", page[0].Alias)
+
+	// all links share the same createdAt second, the id breaks ties
+	page, err = s.ListURLs(ctx, storage.ListQuery{Creator: "1", SortBy: storage.SortCreatedAt, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	after = page[0].Cursor()
+	rest, err := s.ListURLs(ctx, storage.ListQuery{Creator: "1", SortBy: storage.SortCreatedAt, Limit: 10, After: &after})
+	require.NoError(t, err)
+	require.Len(t, rest, 2)
+
+	page, err = s.ListURLs(ctx, storage.ListQuery{Creator: "1", Domain: "Google", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+
+	future := time.Now().Add(time.Hour)
+	page, err = s.ListURLs(ctx, storage.ListQuery{Creator: "1", CreatedFrom: &future, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, page)
+}
+
+func TestStorage_UpdateURL(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	expiresAt := time.Now().Add(time.Hour)
+	_, err := s.SaveURL(storage.Link{URL: "https://google.com", Alias: "google", Creator: "1", ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+
+	target := "https://bing.com"
+	link, err := s.UpdateURL("google", "1", storage.LinkUpdate{URL: &target, ClearExpiresAt: true})
+	require.NoError(t, err)
+	require.Equal(t, target, link.URL)
+	require.Nil(t, link.ExpiresAt)
+
+	on := true
+	link, err = s.UpdateURL("google", "1", storage.LinkUpdate{ForwardPath: &on})
+	require.NoError(t, err)
+	require.Equal(t, storage.Passthrough{Path: true}, link.Passthrough)
+	page, err := s.ListURLs(context.Background(), storage.ListQuery{Creator: "1", Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, storage.Passthrough{Path: true}, page[0].Passthrough)
+
+	_, err = s.UpdateURL("google", "2", storage.LinkUpdate{URL: &target})
+	require.ErrorIs(t, err, storage.ErrAliasNotFound)
+
+	_, err = s.UpdateURL("Google", "1", storage.LinkUpdate{URL: &target})
+	require.ErrorIs(t, err, storage.ErrCaseMismatch)
+}
+
+func TestStorage_Trash(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+	ctx := context.Background()
+
+	_, err := s.SaveURL(storage.Link{URL: "https://google.com", Alias: "google", Creator: "1"})
+	require.NoError(t, err)
+	_, err = s.DeleteURL("google", "1")
+	require.NoError(t, err)
+
+	_, err = s.GetURL("google")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = s.UpdateURL("google", "1", storage.LinkUpdate{})
+	require.ErrorIs(t, err, storage.ErrCaseMismatch)
+	_, err = s.SaveURL(storage.Link{URL: "https://bing.com", Alias: "google", Creator: "1"})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	trash, err := s.ListURLs(ctx, storage.ListQuery{Creator: "1", Deleted: true, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	require.NotNil(t, trash[0].DeletedAt)
+	live, err := s.ListURLs(ctx, storage.ListQuery{Creator: "1", Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, live)
+
+	link, err := s.RestoreURL("google", "1")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", link.URL)
+	_, err = s.RestoreURL("google", "1")
+	require.ErrorIs(t, err, storage.ErrAliasNotFound)
+
+	clickedAt := time.Now().UTC().Add(-time.Minute)
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{
+		{Alias: "google", ClickedAt: clickedAt, Referrer: "https://bing.com", UserAgent: "curl"},
+		{Alias: "bing", ClickedAt: clickedAt},
+	}))
+	_, err = s.DeleteURL("google", "1")
+	require.NoError(t, err)
+	purged, err := s.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, purged)
+	purged, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 1, purged)
+
+	_, err = s.SaveURL(storage.Link{URL: "https://bing.com", Alias: "google", Creator: "1"})
+	require.NoError(t, err)
+
+	// the new link of the alias doesn't inherit the stats of the purged one
+	stats, err := s.ClickStats(ctx, "google", clickedAt.Add(-time.Hour), time.Now().Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.Zero(t, stats.Total)
+	require.Empty(t, stats.Hourly)
+	require.Empty(t, stats.TopReferrers)
+	live, err = s.ListURLs(ctx, storage.ListQuery{Creator: "1", Limit: 10})
+	require.NoError(t, err)
+	require.Zero(t, live[0].Clicks)
+	stats, err = s.ClickStats(ctx, "bing", clickedAt.Add(-time.Hour), time.Now().Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Total, "clicks of other aliases stay")
+}
+
+func TestStorage_Password(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	_, err := s.SaveURL(storage.Link{URL: "https://google.com", Alias: "protected", Creator: "1", Password: "secret"})
+	require.NoError(t, err)
+	_, err = s.SaveURLs(context.Background(), []storage.Link{{URL: "https://google.com", Alias: "open", Creator: "1"}})
+	require.NoError(t, err)
+
+	_, err = s.GetURL("protected")
+	require.ErrorIs(t, err, storage.ErrURLProtected)
+	link, err := s.GetLink("protected")
+	require.NoError(t, err)
+	require.NotEqual(t, "secret", link.Password, "passwords are stored hashed")
+	require.True(t, link.CheckPassword("secret"))
+	require.False(t, link.CheckPassword("guess"))
+
+	link, err = s.GetLink("open")
+	require.NoError(t, err)
+	require.False(t, link.Protected())
+
+	// deduplication never hands out a protected link
+	link, err = s.LinkByTarget("1", "https://google.com")
+	require.NoError(t, err)
+	require.Equal(t, "open", link.Alias)
+}
+
+func TestStorage_ConsumeClick(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	once := int64(1)
+	_, err := s.SaveURL(storage.Link{URL: "https://google.com", Alias: "once", Creator: "1", ClicksLeft: &once})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{URL: "https://google.com", Alias: "open", Creator: "1"})
+	require.NoError(t, err)
+
+	_, err = s.GetURL("once")
+	require.ErrorIs(t, err, storage.ErrURLLimited)
+
+	url, err := s.ConsumeClick("once")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url)
+	_, err = s.ConsumeClick("once")
+	require.ErrorIs(t, err, storage.ErrURLExhausted)
+	_, err = s.GetURL("once")
+	require.ErrorIs(t, err, storage.ErrURLExhausted)
+
+	url, err = s.ConsumeClick("open")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url)
+	_, err = s.ConsumeClick("missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_Activation(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	later := now.Add(time.Hour)
+	schedule := storage.Schedule{{At: now.Add(-time.Minute), URL: "https://example.com/b"}}
+	_, err := s.SaveURL(storage.Link{URL: "https://example.com/a", Alias: "launch", Creator: "1",
+		NotBefore: &later, FallbackURL: "https://example.com/soon"})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/a", Alias: "moved", Creator: "1", Schedule: schedule})
+	require.NoError(t, err)
+
+	url, err := s.GetURL("launch")
+	require.ErrorIs(t, err, storage.ErrURLNotStarted)
+	require.Equal(t, "https://example.com/soon", url)
+
+	link, err := s.GetLink("launch")
+	require.NoError(t, err)
+	require.True(t, later.Equal(*link.NotBefore))
+	require.Nil(t, link.NotAfter)
+	require.Equal(t, "https://example.com/soon", link.FallbackURL)
+
+	url, err = s.GetURL("moved")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/b", url)
+	link, err = s.GetLink("moved")
+	require.NoError(t, err)
+	require.Len(t, link.Schedule, 1)
+	require.True(t, schedule[0].At.Equal(link.Schedule[0].At))
+
+	// scheduled links can't stand in for a plain one
+	_, err = s.LinkByTarget("1", "https://example.com/a")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_Passthrough(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	_, err := s.SaveURL(storage.Link{URL: "https://example.com/manual/", Alias: "docs", Creator: "1",
+		Passthrough: storage.Passthrough{Query: true, Path: true}})
+	require.NoError(t, err)
+	_, err = s.SaveURLs(context.Background(), []storage.Link{{URL: "https://example.com/blog", Alias: "blog", Creator: "1",
+		Passthrough: storage.Passthrough{Query: true}}})
+	require.NoError(t, err)
+
+	p, err := s.Passthrough("docs")
+	require.NoError(t, err)
+	require.Equal(t, storage.Passthrough{Query: true, Path: true}, p)
+	link, err := s.GetLink("blog")
+	require.NoError(t, err)
+	require.Equal(t, storage.Passthrough{Query: true}, link.Passthrough)
+	_, err = s.Passthrough("missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	// forwarding links can't stand in for a plain one
+	_, err = s.LinkByTarget("1", "https://example.com/manual/")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_Collections(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+	require.NoError(t, s.CreateUser(login.User{ID: "2", Username: "other", Password: "password123"}))
+
+	spring, err := s.CreateCollection(storage.Collection{Owner: "1", Name: "spring"})
+	require.NoError(t, err)
+	_, err = s.CreateCollection(storage.Collection{Owner: "1", Name: "spring"})
+	require.ErrorIs(t, err, storage.ErrCollectionExists)
+	_, err = s.CreateCollection(storage.Collection{Owner: "2", Name: "spring"})
+	require.NoError(t, err, "names are unique per owner")
+
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/a", Alias: "a", Creator: "1",
+		Tags: []string{"Sale", "email", "sale"}, CollectionID: spring.ID})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/b", Alias: "b", Creator: "1", Tags: []string{"sale"}})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/c", Alias: "c", Creator: "2", CollectionID: spring.ID})
+	require.ErrorIs(t, err, storage.ErrCollectionNotFound, "collections of other users can't be used")
+
+	link, err := s.GetLink("a")
+	require.NoError(t, err)
+	require.Equal(t, []string{"email", "sale"}, link.Tags)
+	require.Equal(t, spring.ID, link.CollectionID)
+
+	list := func(q storage.ListQuery) []string {
+		q.Creator, q.Limit = "1", 10
+		links, err := s.ListURLs(ctx, q)
+		require.NoError(t, err)
+		var aliases []string
+		for _, l := range links {
+			aliases = append(aliases, l.Alias)
+		}
+		return aliases
+	}
+	require.ElementsMatch(t, []string{"a", "b"}, list(storage.ListQuery{Tags: []string{"sale"}}))
+	require.Equal(t, []string{"a"}, list(storage.ListQuery{Tags: []string{"sale", "email"}}))
+	require.Equal(t, []string{"a"}, list(storage.ListQuery{Collection: spring.ID}))
+
+	collectionID, tags := spring.ID, []string{"launch"}
+	_, err = s.UpdateURL("b", "1", storage.LinkUpdate{CollectionID: &collectionID, Tags: &tags})
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, list(storage.ListQuery{Tags: []string{"launch"}}))
+
+	hour := time.Now().UTC().Truncate(time.Hour)
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{
+		{Alias: "a", ClickedAt: hour}, {Alias: "b", ClickedAt: hour}, {Alias: "b", ClickedAt: hour},
+	}))
+	stats, err := s.CollectionStats(ctx, spring.ID, hour.Add(-time.Hour), hour.Add(time.Hour), 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, stats.Links)
+	require.EqualValues(t, 3, stats.Total)
+	require.Equal(t, []storage.ValueCount{{Value: "b", Clicks: 2}, {Value: "a", Clicks: 1}}, stats.TopLinks)
+
+	collections, err := s.ListCollections("1")
+	require.NoError(t, err)
+	require.Len(t, collections, 1)
+	require.EqualValues(t, 2, collections[0].Links)
+
+	name := "summer"
+	renamed, err := s.UpdateCollection(spring.ID, "1", storage.CollectionUpdate{Name: &name})
+	require.NoError(t, err)
+	require.Equal(t, "summer", renamed.Name)
+	_, err = s.UpdateCollection(spring.ID, "2", storage.CollectionUpdate{Name: &name})
+	require.ErrorIs(t, err, storage.ErrCollectionNotFound)
+
+	_, err = s.DeleteCollection(spring.ID, "1")
+	require.NoError(t, err)
+	_, err = s.GetCollection(spring.ID, "1")
+	require.ErrorIs(t, err, storage.ErrCollectionNotFound)
+	link, err = s.GetLink("a")
+	require.NoError(t, err, "links outlive their collection")
+	require.Empty(t, link.CollectionID)
+}
+
+func TestStorage_Domains(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+	require.NoError(t, s.CreateUser(login.User{ID: "2", Username: "other", Password: "password123"}))
+
+	d, err := s.CreateDomain(storage.Domain{Host: "Go.Acme.com", Owner: "2"})
+	require.NoError(t, err)
+	require.Equal(t, "go.acme.com", d.Host)
+	require.False(t, d.Verified())
+	_, err = s.CreateDomain(storage.Domain{Host: "go.acme.com", Owner: "2"})
+	require.ErrorIs(t, err, storage.ErrDomainExists)
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/x", Alias: "x", Creator: "2", Domain: "go.acme.com"})
+	require.ErrorIs(t, err, storage.ErrDomainNotVerified)
+	hosts, err := s.DomainHosts(ctx)
+	require.NoError(t, err)
+	require.Empty(t, hosts, "unverified domains serve nothing")
+
+	d, err = s.CreateDomain(storage.Domain{Host: "go.acme.com", Owner: "1"})
+	require.NoError(t, err, "unverified claims are taken over")
+	require.Equal(t, "1", d.Owner)
+	d, err = s.VerifyDomain("go.acme.com", "1")
+	require.NoError(t, err)
+	require.True(t, d.Verified())
+	_, err = s.VerifyDomain("go.acme.com", "2")
+	require.ErrorIs(t, err, storage.ErrDomainExists)
+	_, err = s.CreateDomain(storage.Domain{Host: "go.acme.com", Owner: "2"})
+	require.ErrorIs(t, err, storage.ErrDomainExists)
+	domains, err := s.ListDomains("1")
+	require.NoError(t, err)
+	require.Len(t, domains, 1)
+	require.True(t, domains[0].Verified())
+	hosts, err = s.DomainHosts(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"go.acme.com"}, hosts)
+
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/sale", Alias: "sale", Creator: "1"})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{URL: "https://acme.com/sale", Alias: "sale", Creator: "1", Domain: "go.acme.com"})
+	require.NoError(t, err, "aliases are unique per domain")
+	_, err = s.SaveURL(storage.Link{URL: "https://acme.com/other", Alias: "sale", Creator: "1", Domain: "go.acme.com"})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+	_, err = s.SaveURL(storage.Link{URL: "https://example.com/x", Alias: "x", Creator: "2", Domain: "go.acme.com"})
+	require.ErrorIs(t, err, storage.ErrDomainNotFound, "domains of other users can't be used")
+
+	url, err := s.GetURL("sale")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/sale", url)
+	key := storage.Key("go.acme.com", "sale")
+	url, err = s.GetURL(key)
+	require.NoError(t, err)
+	require.Equal(t, "https://acme.com/sale", url)
+
+	newURL := "https://acme.com/new"
+	link, err := s.UpdateURL(key, "1", storage.LinkUpdate{URL: &newURL})
+	require.NoError(t, err)
+	require.Equal(t, "go.acme.com", link.Domain)
+	url, err = s.GetURL("sale")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/sale", url, "the default domain link is untouched")
+
+	require.NoError(t, s.SaveClicks(ctx, []storage.Click{{Alias: key, ClickedAt: time.Now()}}))
+	links, err := s.ListURLs(ctx, storage.ListQuery{Creator: "1", Limit: 10, SortBy: storage.SortClicks, Desc: true})
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+	require.Equal(t, key, links[0].Key())
+	require.EqualValues(t, 1, links[0].Clicks)
+	require.Zero(t, links[1].Clicks)
+
+	_, err = s.DeleteDomain("go.acme.com", "2")
+	require.ErrorIs(t, err, storage.ErrDomainNotFound)
+	_, err = s.DeleteURL(key, "1")
+	require.NoError(t, err)
+	_, err = s.DeleteDomain("go.acme.com", "1")
+	require.ErrorIs(t, err, storage.ErrDomainInUse, "trashed links still hold the domain")
+	_, err = s.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	_, err = s.DeleteDomain("go.acme.com", "1")
+	require.NoError(t, err)
+
+	hosts, err = s.DomainHosts(ctx)
+	require.NoError(t, err)
+	require.Empty(t, hosts)
+}
+
+func TestStorage_NextAliasID(t *testing.T) {
+	s := newTestStorage(t)
+
+	for want := int64(1); want <= 3; want++ {
+		id, err := s.NextAliasID()
+		require.NoError(t, err)
+		require.Equal(t, want, id)
+	}
+}
+
+func TestStorage_LinkByTarget(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	past := time.Now().Add(-time.Hour)
+	_, err := s.SaveURL(storage.Link{Alias: "expired", URL: "https://example.com/a", Creator: "1", ExpiresAt: &past})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{Alias: "live", URL: "HTTPS://Example.com:443/a#top", Creator: "1"})
+	require.NoError(t, err)
+
+	link, err := s.LinkByTarget("1", "https://example.com/a")
+	require.NoError(t, err)
+	require.Equal(t, "live", link.Alias)
+
+	_, err = s.LinkByTarget("2", "https://example.com/a")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	target := "https://example.com/b"
+	_, err = s.UpdateURL("live", "1", storage.LinkUpdate{URL: &target})
+	require.NoError(t, err)
+	_, err = s.LinkByTarget("1", "https://example.com/a")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	link, err = s.LinkByTarget("1", "https://EXAMPLE.com/b")
+	require.NoError(t, err)
+	require.Equal(t, "live", link.Alias)
+}
+
+func TestStorage_SaveURLs(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+
+	ids, err := s.SaveURLs(context.Background(), []storage.Link{
+		{Alias: "a", URL: "https://a.com", Creator: "1"},
+		{Alias: "b", URL: "https://b.com", Creator: "1"},
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 2)
+
+	_, err = s.SaveURLs(context.Background(), []storage.Link{
+		{Alias: "c", URL: "https://c.com", Creator: "1"},
+		{Alias: "a", URL: "https://a.com", Creator: "1"},
+	})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+	var itemErr *storage.ItemError
+	require.ErrorAs(t, err, &itemErr)
+	require.Equal(t, 1, itemErr.Index)
+
+	// the whole batch was rolled back
+	_, err = s.GetLink("c")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_NormalizedBackfill(t *testing.T) {
+	s := newTestStorage(t)
+	require.NoError(t, s.CreateUser(login.User{ID: "1", Username: "user", Password: "password123"}))
+	ctx := context.Background()
+
+	migrator, closeMigrator, err := s.Migrator()
+	require.NoError(t, err)
+	defer closeMigrator()
+	// roll back to right before the 0014 backfill, whatever came after it
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	_, err = migrator.Down(ctx, int(version-13))
+	require.NoError(t, err)
+
+	// a link from before 0006 carries its raw url
+	_, err = s.SaveURL(storage.Link{URL: "HTTPS://Example.com:443/a?b=2&a=1", Alias: "old", Creator: "1"})
+	require.NoError(t, err)
+	_, err = s.DB.Exec(`UPDATE url SET url_normalized = url`)
+	require.NoError(t, err)
+	_, err = s.LinkByTarget("1", "https://example.com/a?a=1&b=2")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	link, err := s.LinkByTarget("1", "https://example.com/a?a=1&b=2")
+	require.NoError(t, err)
+	require.Equal(t, "old", link.Alias)
+}
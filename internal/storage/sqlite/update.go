@@ -0,0 +1,77 @@
+package sqlite
+
+import (
+	"fmt"
+	"strings"
+	"url_shortener/internal/storage"
+)
+
+// UpdateURL changes mutable fields of the link identified by alias and creator and returns the updated link.
+func (s *Storage) UpdateURL(alias, creator string, upd storage.LinkUpdate) (storage.Link, error) {
+	const info = "storage.sqlite.UpdateURL"
+
+	ok, err := s.CaseDifferent(alias)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	if !ok {
+		return storage.Link{}, fmt.Errorf("%s: %s, %w", info, alias, storage.ErrCaseMismatch)
+	}
+
+	var sets []string
+	var args []any
+	if upd.URL != nil {
+		sets = append(sets, "url = ?", "url_normalized = ?")
+		args = append(args, *upd.URL, storage.NormalizeURL(*upd.URL))
+	}
+	if upd.ClearExpiresAt {
+		sets = append(sets, "expires_at = NULL")
+	} else if upd.ExpiresAt != nil {
+		sets = append(sets, "expires_at = ?")
+		args = append(args, upd.ExpiresAt.UTC())
+	}
+	if upd.Tags != nil {
+		sets = append(sets, "tags = ?")
+		args = append(args, tags(*upd.Tags))
+	}
+	if upd.CollectionID != nil {
+		if err := checkCollection(s.DB, *upd.CollectionID, creator); err != nil {
+			return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+		}
+		sets = append(sets, "collection_id = NULLIF(?, '')")
+		args = append(args, *upd.CollectionID)
+	}
+	if upd.ForwardQuery != nil {
+		sets = append(sets, "forward_query = ?")
+		args = append(args, *upd.ForwardQuery)
+	}
+	if upd.ForwardPath != nil {
+		sets = append(sets, "forward_path = ?")
+		args = append(args, *upd.ForwardPath)
+	}
+	if len(sets) == 0 {
+		// nothing to change, still check ownership and return the link
+		sets = append(sets, "url = url")
+	}
+
+	domain, name := storage.SplitKey(alias)
+	args = append(args, domain, name, creator)
+
+	result, err := s.DB.Exec(fmt.Sprintf(`UPDATE url SET %s WHERE domain = ? AND alias = ? AND creator = ? AND deleted_at IS NULL`, strings.Join(sets, ", ")), args...)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: failed to execute update statement: %w", info, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	if rows == 0 {
+		return storage.Link{}, fmt.Errorf("%s: no rows found for alias %s and creator %s, %w", info, alias, creator, storage.ErrAliasNotFound)
+	}
+
+	link, err := s.GetLink(alias)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", info, err)
+	}
+	return link, nil
+}
@@ -6,3 +6,16 @@ var ErrURLNotFound = errors.New("url not found")
 var ErrURLExists = errors.New("url exists")
 var ErrCaseMismatch = errors.New("case mismatch")
 var ErrAliasNotFound = errors.New("alias not found")
+var ErrUserExists = errors.New("user exists")
+var ErrURLExpired = errors.New("url expired")
+var ErrURLProtected = errors.New("url is password protected")
+var ErrURLLimited = errors.New("url is click limited")
+var ErrURLExhausted = errors.New("url click limit reached")
+var ErrURLNotStarted = errors.New("url is not active yet")
+var ErrURLEnded = errors.New("url is no longer active")
+var ErrCollectionNotFound = errors.New("collection not found")
+var ErrCollectionExists = errors.New("collection exists")
+var ErrDomainNotFound = errors.New("domain not found")
+var ErrDomainExists = errors.New("domain exists")
+var ErrDomainInUse = errors.New("domain has links")
+var ErrDomainNotVerified = errors.New("domain not verified")
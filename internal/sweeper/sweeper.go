@@ -0,0 +1,78 @@
+package sweeper
+
+import (
+	"context"
+	"log/slog"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/sl"
+)
+
+// ExpiredPurger removes links that expired before the given moment.
+type ExpiredPurger interface {
+	PurgeExpired(ctx context.Context, before time.Time, archive bool) (int64, error)
+}
+
+// DeletedPurger removes links that went to the trash before the given moment.
+type DeletedPurger interface {
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+type Purger interface {
+	ExpiredPurger
+	DeletedPurger
+}
+
+// Sweeper periodically purges (or archives) links whose expiration passed more than a grace period ago,
+// and links that have been in the trash longer than the retention window.
+type Sweeper struct {
+	log    *slog.Logger
+	purger Purger
+	cfg    config.Expiration
+	trash  config.Trash
+}
+
+func New(log *slog.Logger, purger Purger, cfg config.Expiration, trash config.Trash) *Sweeper {
+	return &Sweeper{
+		log:    log.With(slog.String("info", "sweeper")),
+		purger: purger,
+		cfg:    cfg,
+		trash:  trash,
+	}
+}
+
+// Run sweeps right away and then every SweepInterval until ctx is done.
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.SweepInterval)
+	defer ticker.Stop()
+
+	for {
+		s.Sweep(ctx, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep purges links that expired before now minus the grace period and links deleted before now
+// minus the trash retention.
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) {
+	purged, err := s.purger.PurgeExpired(ctx, now.Add(-s.cfg.GracePeriod), s.cfg.Archive)
+	if err != nil {
+		s.log.Error("failed to purge expired urls", sl.Err(err))
+	} else if purged > 0 {
+		s.log.Info("expired urls purged", slog.Int64("count", purged), slog.Bool("archived", s.cfg.Archive))
+	}
+
+	purged, err = s.purger.PurgeDeleted(ctx, now.Add(-s.trash.Retention))
+	if err != nil {
+		s.log.Error("failed to purge deleted urls", sl.Err(err))
+		return
+	}
+	if purged > 0 {
+		s.log.Info("deleted urls purged", slog.Int64("count", purged))
+	}
+}
@@ -0,0 +1,61 @@
+package sweeper
+
+import (
+	"context"
+	"testing"
+	"time"
+	"url_shortener/internal/config"
+	"url_shortener/internal/lib/logger/handlers/slogdiscard"
+	"url_shortener/internal/storage"
+	"url_shortener/internal/storage/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweeper_Sweep(t *testing.T) {
+	now := time.Now()
+	s := memory.NewStorage()
+
+	expired := now.Add(-2 * time.Hour)
+	inGrace := now.Add(-30 * time.Minute)
+	_, err := s.SaveURL(storage.Link{Alias: "expired", URL: "https://google.com", ExpiresAt: &expired})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{Alias: "in_grace", URL: "https://google.com", ExpiresAt: &inGrace})
+	require.NoError(t, err)
+	_, err = s.SaveURL(storage.Link{Alias: "forever", URL: "https://google.com"})
+	require.NoError(t, err)
+
+	New(slogdiscard.NewDiscardLogger(), s, config.Expiration{GracePeriod: time.Hour}, config.Trash{Retention: time.Hour}).Sweep(context.Background(), now)
+
+	_, err = s.GetURL("expired")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = s.GetURL("in_grace")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+	_, err = s.GetURL("forever")
+	require.NoError(t, err)
+}
+
+func TestSweeper_SweepTrash(t *testing.T) {
+	now := time.Now()
+	s := memory.NewStorage()
+	sweeper := New(slogdiscard.NewDiscardLogger(), s, config.Expiration{GracePeriod: time.Hour}, config.Trash{Retention: time.Hour})
+
+	_, err := s.SaveURL(storage.Link{Alias: "deleted", URL: "https://google.com", Creator: "user"})
+	require.NoError(t, err)
+	_, err = s.DeleteURL("deleted", "user")
+	require.NoError(t, err)
+
+	// still within the retention window
+	sweeper.Sweep(context.Background(), now)
+	_, err = s.RestoreURL("deleted", "user")
+	require.NoError(t, err)
+
+	_, err = s.DeleteURL("deleted", "user")
+	require.NoError(t, err)
+	sweeper.Sweep(context.Background(), now.Add(2*time.Hour))
+
+	_, err = s.RestoreURL("deleted", "user")
+	require.ErrorIs(t, err, storage.ErrAliasNotFound)
+	_, err = s.SaveURL(storage.Link{Alias: "deleted", URL: "https://google.com", Creator: "user"})
+	require.NoError(t, err, "purged aliases are free again")
+}